@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/spf13/cobra"
+
+	"github.com/nicoxiang/geektime-downloader/internal/batch"
+	"github.com/nicoxiang/geektime-downloader/internal/config"
+	"github.com/nicoxiang/geektime-downloader/internal/course"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/logger"
+	"github.com/nicoxiang/geektime-downloader/internal/ui"
+)
+
+var batchJobFile string
+
+func init() {
+	batchCmd.Flags().StringVar(&batchJobFile, "file", "", "任务文件路径, 支持 yaml 和 json 格式")
+
+	_ = batchCmd.MarkFlagRequired("file")
+
+	rootCmd.AddCommand(batchCmd)
+}
+
+var batchCmd = &cobra.Command{
+	Use:   "batch",
+	Short: "Download many products listed in a job file",
+	Example: `  geektime-downloader batch --gcid "gcid" --gcess "gcess" --file jobs.yaml
+
+  # jobs.yaml
+  jobs:
+    - type: column
+      id: 100056701
+      output: 3
+    - type: column
+      id: 100020801
+      articles: 1000,1002-1010
+    - type: university
+      id: 419
+      quality: hd`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jobFile, err := batch.LoadJobFile(batchJobFile)
+		if err != nil {
+			return withExitCode(exitUsage, err)
+		}
+
+		sp := spinner.New(spinner.CharSets[4], 100*time.Millisecond)
+		var results []batch.Result
+		for i, job := range jobFile.Jobs {
+			if cmd.Context().Err() != nil {
+				break
+			}
+			fmt.Printf("[%d/%d] 正在处理 %s %d\n", i+1, len(jobFile.Jobs), job.Type, job.ID)
+			results = append(results, runJob(cmd.Context(), job, sp))
+		}
+
+		fmt.Println()
+		batch.PrintSummary(os.Stdout, results)
+
+		if err := cmd.Context().Err(); err != nil {
+			return err
+		}
+		if failed := batch.Count(results, batch.StatusFailed); failed > 0 {
+			return fmt.Errorf("%d 项任务下载失败", failed)
+		}
+		return nil
+	},
+}
+
+// runJob downloads one job entry with its own config overrides
+func runJob(ctx context.Context, job batch.Job, sp *spinner.Spinner) batch.Result {
+	result := batch.Result{Job: job}
+
+	jobCfg := job.Apply(cfg)
+	if err := config.ValidateConfig(&jobCfg); err != nil {
+		result.Status, result.Reason = batch.StatusFailed, err.Error()
+		return result
+	}
+	productType, ok := ui.FindProductTypeOption(jobCfg.IsEnterprise, job.Type)
+	if !ok {
+		result.Status = batch.StatusFailed
+		result.Reason = fmt.Sprintf("type must be one of %s", strings.Join(ui.ProductTypeNames(jobCfg.IsEnterprise), ", "))
+		return result
+	}
+	selector, err := parseArticleSelector(job.Articles)
+	if err != nil {
+		result.Status, result.Reason = batch.StatusFailed, err.Error()
+		return result
+	}
+
+	d := course.NewCourseDownloader(ctx, &jobCfg, geektimeClient, sp)
+	result.Title, err = downloadProduct(d, productType, job.ID, selector)
+	switch {
+	case err == nil:
+		result.Status = batch.StatusSucceeded
+	case errors.Is(err, course.ErrNotPurchased), errors.Is(err, course.ErrInvalidProductID):
+		result.Status, result.Reason = batch.StatusSkipped, err.Error()
+	default:
+		logger.Errorf(err, "Failed to download job, type: %s, id: %d", job.Type, job.ID)
+		result.Status, result.Reason = batch.StatusFailed, err.Error()
+	}
+	return result
+}
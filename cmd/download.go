@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/spf13/cobra"
+
+	"github.com/nicoxiang/geektime-downloader/internal/course"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/logger"
+	"github.com/nicoxiang/geektime-downloader/internal/ui"
+)
+
+var (
+	downloadProductType string
+	downloadProductID   int
+	downloadArticles    string
+)
+
+func init() {
+	downloadCmd.Flags().StringVarP(&downloadProductType, "type", "t", "column", "产品类型(column普通课程,daily每日一课,opencourse公开课,qconplus大厂案例,university训练营,other其他)")
+	downloadCmd.Flags().IntVar(&downloadProductID, "id", 0, "课程 ID")
+	downloadCmd.Flags().StringVar(&downloadArticles, "articles", "", "只下载指定的文章 ID, 多个用逗号分隔, 支持范围, 例如 100001,100005-100010")
+
+	_ = downloadCmd.MarkFlagRequired("id")
+
+	rootCmd.AddCommand(downloadCmd)
+}
+
+var downloadCmd = &cobra.Command{
+	Use:   "download",
+	Short: "Download a product without interactive prompts",
+	Example: `  geektime-downloader download --gcid "gcid" --gcess "gcess" --type column --id 100056701
+  geektime-downloader download --gcid "gcid" --gcess "gcess" --type column --id 100056701 --articles 227271,227300-227310`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		productType, ok := ui.FindProductTypeOption(cfg.IsEnterprise, downloadProductType)
+		if !ok {
+			return withExitCode(exitUsage, fmt.Errorf("argument 'type' is not valid, must be one of %s",
+				strings.Join(ui.ProductTypeNames(cfg.IsEnterprise), ", ")))
+		}
+		selector, err := parseArticleSelector(downloadArticles)
+		if err != nil {
+			return withExitCode(exitUsage, err)
+		}
+
+		d := course.NewCourseDownloader(cmd.Context(), &cfg, geektimeClient, spinner.New(spinner.CharSets[4], 100*time.Millisecond))
+		_, err = downloadProduct(d, productType, downloadProductID, selector)
+		if err != nil {
+			logger.Errorf(err, "Failed to download product, type: %s, id: %d", productType.Name, downloadProductID)
+		}
+		return err
+	},
+}
+
+// downloadProduct downloads the whole product, or only selected articles if selector is not empty,
+// returns the product title.
+func downloadProduct(d *course.CourseDownloader, productType ui.ProductTypeSelectOption, productID int, selector articleSelector) (string, error) {
+	if !productType.NeedSelectArticle {
+		productInfo, err := course.LoadSingleVideoProduct(geektimeClient, productType, productID)
+		if err != nil {
+			return "", err
+		}
+		return productInfo.Data.Info.Title, d.DownloadSingleVideoProduct(productInfo.Data.Info.Title,
+			productInfo.Data.Info.Article.ID,
+			productType.SourceType)
+	}
+
+	c, err := course.LoadCourse(geektimeClient, productType, productID)
+	if err != nil {
+		return "", err
+	}
+	if selector.empty() {
+		return c.Title, d.DownloadAll(c, productType)
+	}
+
+	for _, a := range c.Articles {
+		if !selector.match(a.AID) {
+			continue
+		}
+		// 训练营目前只支持下载视频类文章
+		if productType.IsUniversity() {
+			detail, err := geektimeClient.UniversityClassArticleDetail(c.ID, a.AID)
+			if err != nil {
+				return c.Title, err
+			}
+			if detail.Data.VideoID == "" {
+				fmt.Fprintf(os.Stderr, "训练营暂时只支持下载视频, 跳过 %s\n", a.Title)
+				continue
+			}
+		}
+		if err := d.DownloadArticle(c, productType, a, false); err != nil {
+			return c.Title, err
+		}
+	}
+	return c.Title, nil
+}
+
+// articleRange is an inclusive article ID range
+type articleRange struct {
+	from, to int
+}
+
+// articleSelector selects articles by article ID
+type articleSelector []articleRange
+
+// parseArticleSelector parses comma separated article IDs and ID ranges, e.g. 1,3,5-8
+func parseArticleSelector(s string) (articleSelector, error) {
+	var selector articleSelector
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			return nil, fmt.Errorf("argument 'articles' is not valid: %s", part)
+		}
+		end := start
+		if isRange {
+			end, err = strconv.Atoi(strings.TrimSpace(to))
+			if err != nil || end < start {
+				return nil, fmt.Errorf("argument 'articles' is not valid: %s", part)
+			}
+		}
+		selector = append(selector, articleRange{start, end})
+	}
+	return selector, nil
+}
+
+func (s articleSelector) empty() bool {
+	return len(s) == 0
+}
+
+func (s articleSelector) match(aid int) bool {
+	for _, r := range s {
+		if aid >= r.from && aid <= r.to {
+			return true
+		}
+	}
+	return false
+}
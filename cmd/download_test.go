@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/nicoxiang/geektime-downloader/internal/course"
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+)
+
+func TestParseArticleSelector(t *testing.T) {
+	selector, err := parseArticleSelector(" 100001, 100005-100007 ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for aid, want := range map[int]bool{100001: true, 100002: false, 100005: true, 100007: true, 100008: false} {
+		if got := selector.match(aid); got != want {
+			t.Errorf("match(%d) = %v, want %v", aid, got, want)
+		}
+	}
+
+	for _, s := range []string{"abc", "5-3", "1-x"} {
+		if _, err := parseArticleSelector(s); err == nil {
+			t.Errorf("parseArticleSelector(%q) returns no error", s)
+		}
+	}
+	if selector, _ := parseArticleSelector(""); !selector.empty() {
+		t.Error("empty selector is not empty")
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{nil, exitOK},
+		{errors.New("x"), exitError},
+		{fmt.Errorf("wrapped: %w", context.Canceled), exitInterrupted},
+		{fmt.Errorf("wrapped: %w", geektime.ErrAuthFailed), exitAuthFailed},
+		{geektime.ErrGeekTimeRateLimit, exitRateLimit},
+		{course.ErrNotPurchased, exitNotPurchased},
+		{fmt.Errorf("%w: 1 项", course.ErrPartialFailure), exitPartialFailure},
+		{withExitCode(exitUsage, errors.New("x")), exitUsage},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.code {
+			t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.code)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/manifoldco/promptui"
+
+	"github.com/nicoxiang/geektime-downloader/internal/course"
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+)
+
+// process exit codes, used by scripts to tell what happened
+const (
+	exitOK = iota
+	exitError
+	exitUsage
+	exitAuthFailed
+	exitRateLimit
+	exitNotPurchased
+	exitInvalidProductID
+	exitPartialFailure
+
+	exitInterrupted = 130
+)
+
+// exitCodeError carries the process exit code of an error
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitCodeError) Unwrap() error {
+	return e.err
+}
+
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitCodeError{code: code, err: err}
+}
+
+// exitCode maps error returned by command to process exit code
+func exitCode(err error) int {
+	var ece *exitCodeError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &ece):
+		return ece.code
+	case errors.Is(err, context.Canceled), errors.Is(err, promptui.ErrInterrupt):
+		return exitInterrupted
+	case errors.Is(err, geektime.ErrAuthFailed):
+		return exitAuthFailed
+	case errors.Is(err, geektime.ErrGeekTimeRateLimit):
+		return exitRateLimit
+	case errors.Is(err, course.ErrNotPurchased):
+		return exitNotPurchased
+	case errors.Is(err, course.ErrInvalidProductID):
+		return exitInvalidProductID
+	case errors.Is(err, course.ErrPartialFailure):
+		return exitPartialFailure
+	default:
+		return exitError
+	}
+}
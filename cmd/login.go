@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/chzyer/readline"
+	"github.com/spf13/cobra"
+
+	"github.com/nicoxiang/geektime-downloader/internal/config"
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/logger"
+	"github.com/nicoxiang/geektime-downloader/internal/ui"
+)
+
+// maxLoginAttempts is the max times of re-input password when password is wrong
+const maxLoginAttempts = 3
+
+var (
+	loginPhone string
+	logout     bool
+)
+
+func init() {
+	loginCmd.Flags().StringVar(&loginPhone, "phone", "", "极客时间登录手机号")
+	loginCmd.Flags().BoolVar(&logout, "logout", false, "删除已保存的登录信息")
+
+	rootCmd.AddCommand(loginCmd)
+}
+
+var loginCmd = &cobra.Command{
+	Use:          "login",
+	Short:        "Login with phone and password, and save cookies for later runs",
+	SilenceUsage: true,
+	// login does not need cookies, so skip cookies loading and config validation in root command
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := config.Load(cmd.Root().PersistentFlags(), &cfg); err != nil {
+			return withExitCode(exitUsage, err)
+		}
+		logger.Init(cfg.LogLevel)
+		setupNetwork(cmd.Context())
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if logout {
+			if err := config.RemoveCookies(); err != nil {
+				return err
+			}
+			fmt.Println("已删除保存的登录信息")
+			return nil
+		}
+		if _, err := login(loginPhone); err != nil {
+			return err
+		}
+		fmt.Printf("登录成功, 登录信息已保存至 %s\n", config.CookieFilePath())
+		return nil
+	},
+}
+
+// login asks password and calls geektime login api, then saves cookies to cookie file.
+// If phone is empty, asks phone as well.
+func login(phone string) ([]*http.Cookie, error) {
+	var err error
+	if phone == "" {
+		if phone, err = ui.PhoneInput(); err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		password, err := ui.PasswordInput()
+		if err != nil {
+			return nil, err
+		}
+
+		cookies, err := newGeektimeClient(nil).Login(phone, password)
+		switch {
+		case err == nil:
+			if err := config.SaveCookies(cookies); err != nil {
+				return nil, fmt.Errorf("保存登录信息失败: %w", err)
+			}
+			return cookies, nil
+		case errors.Is(err, geektime.ErrWrongPassword) && attempt < maxLoginAttempts:
+			fmt.Fprintf(os.Stderr, "密码错误, 请重新输入 (%d/%d)\n", attempt, maxLoginAttempts)
+		case errors.Is(err, geektime.ErrWrongPassword), errors.Is(err, geektime.ErrTooManyLoginAttemptTimes):
+			return nil, withExitCode(exitAuthFailed, err)
+		default:
+			logger.Errorf(err, "Failed to login")
+			return nil, err
+		}
+	}
+}
+
+// useSavedCookies loads cookies saved by login command if gcid and gcess are not set,
+// and checks if they are still valid. Asks user to login again if login is expired.
+func useSavedCookies() error {
+	// replayed responses don't need login
+	if cfg.Gcid != "" || cfg.Gcess != "" || cfg.Replay != "" {
+		return nil
+	}
+	cookies, err := config.LoadCookies()
+	if errors.Is(err, os.ErrNotExist) {
+		// not login yet, leave it to config validation
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取登录信息 %s 失败: %w", config.CookieFilePath(), err)
+	}
+
+	if err := newGeektimeClient(cookies).Auth(); err != nil {
+		if !errors.Is(err, geektime.ErrAuthFailed) {
+			return err
+		}
+		if !readline.DefaultIsTerminal() {
+			return withExitCode(exitAuthFailed, err)
+		}
+		fmt.Fprintln(os.Stderr, err.Error())
+		if cookies, err = login(""); err != nil {
+			return err
+		}
+	}
+
+	config.ApplySavedCookies(&cfg, cookies)
+	return nil
+}
@@ -2,38 +2,66 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/nicoxiang/geektime-downloader/internal/config"
 	"github.com/nicoxiang/geektime-downloader/internal/fsm"
 	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+	"github.com/nicoxiang/geektime-downloader/internal/pdf"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/downloader"
 	"github.com/nicoxiang/geektime-downloader/internal/pkg/logger"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/ratelimit"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/tape"
 )
 
 var (
 	geektimeClient *geektime.Client
 	cfg            config.AppConfig
+	// clientOptions are options of every geektime client, set up by setupNetwork
+	clientOptions []geektime.Option
 )
 
 func init() {
 	userHomeDir, _ := os.UserHomeDir()
 	defaultDownloadFolder := filepath.Join(userHomeDir, config.GeektimeDownloaderFolder)
 
-	rootCmd.Flags().StringVar(&cfg.Gcid, "gcid", "", "极客时间 cookie 值 gcid")
-	rootCmd.Flags().StringVar(&cfg.Gcess, "gcess", "", "极客时间 cookie 值 gcess")
-	rootCmd.Flags().StringVarP(&cfg.DownloadFolder, "folder", "f", defaultDownloadFolder, "专栏和视频课的下载目标位置")
-	rootCmd.Flags().StringVarP(&cfg.Quality, "quality", "q", "sd", "下载视频清晰度(ld标清,sd高清,hd超清)")
-	rootCmd.Flags().IntVar(&cfg.DownloadComments, "comments", 1, "是否下载评论(0不下载,1下载首页评论,2下载所有评论)")
-	rootCmd.Flags().IntVar(&cfg.ColumnOutputType, "output", 1, "专栏的输出内容(1pdf,2markdown,4audio)可自由组合")
-	rootCmd.Flags().IntVar(&cfg.PrintPDFWaitSeconds, "print-pdf-wait", 5, "Chrome生成PDF前的等待页面加载时间, 单位为秒, 默认5秒")
-	rootCmd.Flags().IntVar(&cfg.PrintPDFTimeoutSeconds, "print-pdf-timeout", 60, "Chrome生成PDF的超时时间, 单位为秒, 默认60秒")
-	rootCmd.Flags().IntVar(&cfg.Interval, "interval", 1, "下载资源的间隔时间, 单位为秒, 默认1秒")
-	rootCmd.Flags().BoolVar(&cfg.IsEnterprise, "enterprise", false, "是否下载企业版极客时间资源")
-	rootCmd.Flags().StringVar(&cfg.LogLevel, "log-level", "info", "日志记录级别(debug, info, warn, error, none)")
+	rootCmd.PersistentFlags().StringVar(&cfg.Gcid, "gcid", "", "极客时间 cookie 值 gcid")
+	rootCmd.PersistentFlags().StringVar(&cfg.Gcess, "gcess", "", "极客时间 cookie 值 gcess")
+	rootCmd.PersistentFlags().StringVarP(&cfg.DownloadFolder, "folder", "f", defaultDownloadFolder, "专栏和视频课的下载目标位置")
+	rootCmd.PersistentFlags().StringVarP(&cfg.Quality, "quality", "q", "sd", "下载视频清晰度(ld标清,sd高清,hd超清), 不存在时自动选择最接近的清晰度; 也可以指定顺序如hd>sd>ld, 或best, smallest按高度选择, best:bitrate, smallest:size按码率或大小选择")
+	rootCmd.PersistentFlags().StringVar(&cfg.VideoFormat, "video-format", "ts", "视频保存格式(ts, mp4, both同时保存两种格式)")
+	rootCmd.PersistentFlags().IntVar(&cfg.DownloadComments, "comments", 1, "是否下载评论(0不下载,1下载首页评论,2下载所有评论)")
+	rootCmd.PersistentFlags().IntVar(&cfg.ColumnOutputType, "output", 1, "专栏的输出内容(1pdf,2markdown,4audio,8epub,16html)可自由组合")
+	rootCmd.PersistentFlags().StringVar(&cfg.PDFEngine, "pdf-engine", "chrome", "生成PDF的方式(chrome使用本地Chrome打印文章页面, native不依赖Chrome直接由文章内容生成)")
+	rootCmd.PersistentFlags().BoolVar(&cfg.MergePDF, "merge-pdf", false, "下载专栏后将所有文章的PDF按目录顺序合并为一个带书签和页码的PDF")
+	rootCmd.PersistentFlags().BoolVar(&cfg.DeleteArticlePDF, "delete-article-pdf", false, "合并PDF后删除每篇文章单独的PDF, 需要同时使用merge-pdf")
+	rootCmd.PersistentFlags().BoolVar(&cfg.Audiobook, "audiobook", false, "下载专栏后将所有文章的音频按目录顺序合并为一个带章节的有声书MP3, 需要output包含audio")
+	rootCmd.PersistentFlags().IntVar(&cfg.PrintPDFWaitSeconds, "print-pdf-wait", 5, "Chrome生成PDF前的等待页面加载时间, 单位为秒, 默认5秒")
+	rootCmd.PersistentFlags().IntVar(&cfg.PrintPDFTimeoutSeconds, "print-pdf-timeout", 60, "Chrome生成PDF的超时时间, 单位为秒, 默认60秒")
+	rootCmd.PersistentFlags().IntVar(&cfg.Interval, "interval", 1, "下载资源的间隔时间, 单位为秒, 默认1秒")
+	rootCmd.PersistentFlags().BoolVar(&cfg.KeepGoing, "keep-going", false, "下载整个专栏时某篇文章失败不中断, 其余文章下载完后重试一次失败的文章, 仍失败的记录到专栏目录的failures.json")
+	rootCmd.PersistentFlags().IntVar(&cfg.APIRate, "api-rate", 60, "每分钟最多请求极客时间接口的次数, 0为不限制")
+	rootCmd.PersistentFlags().IntVar(&cfg.PageRate, "page-rate", 20, "每分钟最多用Chrome打开文章页面的次数, 0为不限制")
+	rootCmd.PersistentFlags().IntVar(&cfg.CDNRate, "cdn-rate", 600, "每分钟最多下载图片、音频、视频分片等资源的次数, 0为不限制")
+	rootCmd.PersistentFlags().IntVar(&cfg.RateLimitRetries, "rate-limit-retries", 5, "触发限流后自动等待并重试的最大次数, 等待时间从1分钟开始逐次翻倍, 0为直接退出")
+	rootCmd.PersistentFlags().IntVar(&cfg.SegmentConcurrency, "segment-concurrency", 4, "视频同时下载的分片数")
+	rootCmd.PersistentFlags().BoolVar(&cfg.Subtitles, "subtitles", true, "是否下载视频字幕, 保存为srt和vtt文件")
+	rootCmd.PersistentFlags().BoolVar(&cfg.EmbedSubtitles, "embed-subtitles", false, "是否将字幕内嵌到mp4视频中, 需要video-format为mp4或both")
+	rootCmd.PersistentFlags().IntVar(&cfg.ArticleConcurrency, "article-concurrency", 2, "下载专栏时同时处理的文章数, 也是Chrome生成PDF时同时打开的标签页数")
+	rootCmd.PersistentFlags().IntVar(&cfg.RangeConcurrency, "range-concurrency", 0, "单个文件分段下载的并发数, 0为自动(CPU核数的一半)")
+	rootCmd.PersistentFlags().BoolVar(&cfg.IsEnterprise, "enterprise", false, "是否下载企业版极客时间资源")
+	rootCmd.PersistentFlags().StringVar(&cfg.LogLevel, "log-level", "info", "日志记录级别(debug, info, warn, error, none)")
+	rootCmd.PersistentFlags().StringVar(&cfg.Record, "record", "", "将接口和资源的响应去除登录信息后保存到指定目录, 用于复现问题")
+	rootCmd.PersistentFlags().StringVar(&cfg.Replay, "replay", "", "使用record保存的响应代替网络请求, 离线运行下载流程")
+	rootCmd.PersistentFlags().String(config.ConfigFlag, config.DefaultConfigFilePath(), "配置文件路径")
+	rootCmd.PersistentFlags().String(config.ProfileFlag, "", "使用配置文件中的指定 profile")
 
 	rootCmd.MarkFlagsRequiredTogether("gcid", "gcess")
 }
@@ -42,20 +70,65 @@ var rootCmd = &cobra.Command{
 	Use:          "geektime-downloader",
 	Short:        "Geektime-downloader is used to download geek time lessons",
 	SilenceUsage: true,
-	PreRunE: func(cmd *cobra.Command, args []string) error {
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := config.Load(cmd.Root().PersistentFlags(), &cfg); err != nil {
+			return withExitCode(exitUsage, err)
+		}
 		logger.Init(cfg.LogLevel)
-		return config.ValidateConfig(&cfg)
+		setupNetwork(cmd.Context())
+		if err := useSavedCookies(); err != nil {
+			return err
+		}
+		if err := config.ValidateConfig(&cfg); err != nil {
+			return withExitCode(exitUsage, err)
+		}
+		readCookies := config.ReadCookiesFromInput(&cfg)
+		geektimeClient = newGeektimeClient(readCookies)
+		return nil
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		readCookies := config.ReadCookiesFromInput(&cfg)
-
-		geektimeClient = geektime.NewClient(readCookies)
-
 		runner := fsm.NewFSMRunner(cmd.Context(), &cfg, geektimeClient)
 		return runner.Run()
 	},
 }
 
+// setupNetwork sets up rate limits and transport of geektime clients, Chrome page loads and CDN downloads.
+// All of them share one backoff, so they pause together on rate limit.
+func setupNetwork(ctx context.Context) {
+	backoff := ratelimit.NewBackoff(cfg.RateLimitRetries)
+	backoff.OnWait = func(wait time.Duration, retry int) {
+		logger.Warnf("Hit GeekTime rate limit, retry %d/%d after %s", retry, backoff.Retries, wait)
+		fmt.Fprintf(os.Stderr, "\n已触发限流, %s 后自动重试(%d/%d)\n", wait, retry, backoff.Retries)
+	}
+	cdnLimiter := ratelimit.PerMinute(cfg.CDNRate)
+	pdf.SetRateLimit(ratelimit.PerMinute(cfg.PageRate), backoff)
+	downloader.SetRateLimit(cdnLimiter)
+
+	clientOptions = []geektime.Option{
+		geektime.WithContext(ctx),
+		geektime.WithAPILimiter(ratelimit.PerMinute(cfg.APIRate)),
+		geektime.WithCDNLimiter(cdnLimiter),
+		geektime.WithBackoff(backoff),
+	}
+
+	var transport http.RoundTripper
+	switch {
+	case cfg.Replay != "":
+		transport = tape.Replay(cfg.Replay)
+	case cfg.Record != "":
+		transport = tape.Record(cfg.Record, http.DefaultTransport)
+	}
+	if transport != nil {
+		clientOptions = append(clientOptions, geektime.WithTransport(transport))
+		downloader.SetTransport(transport)
+	}
+}
+
+// newGeektimeClient returns geektime client with cookies, see setupNetwork
+func newGeektimeClient(cookies []*http.Cookie) *geektime.Client {
+	return geektime.NewClient(cookies, clientOptions...)
+}
+
 // Execute ...
 func Execute() {
 	ctx := context.Background()
@@ -76,6 +149,6 @@ func Execute() {
 	}()
 
 	if err := rootCmd.ExecuteContext(ctx); err != nil {
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
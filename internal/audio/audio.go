@@ -9,6 +9,7 @@ import (
 	"github.com/nicoxiang/geektime-downloader/internal/pkg/downloader"
 	"github.com/nicoxiang/geektime-downloader/internal/pkg/filenamify"
 	"github.com/nicoxiang/geektime-downloader/internal/pkg/logger"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/mp3"
 )
 
 const (
@@ -16,23 +17,34 @@ const (
 	MP3Extension = ".mp3"
 )
 
-// DownloadAudio ...
-func DownloadAudio(ctx context.Context, downloadAudioURL, dir, title string) error {
+// DownloadAudio downloads article audio and writes tag into it, so players show and sort it by column and track.
+// It returns ErrNoAudio if article has no audio. origin is the Origin header of requests.
+func DownloadAudio(ctx context.Context, origin, downloadAudioURL, dir, title string, tag mp3.Tag) error {
 	logger.Infof("Begin download article audio, title: %s", title)
 	if downloadAudioURL == "" {
-		return nil
+		return ErrNoAudio
 	}
 	audioFileName := filepath.Join(dir, filenamify.Filenamify(title)+MP3Extension)
 
 	headers := make(map[string]string, 2)
-	headers[geektime.Origin] = geektime.DefaultBaseURL
+	headers[geektime.Origin] = origin
 	headers[geektime.UserAgent] = geektime.DefaultUserAgent
 
 	_, err := downloader.DownloadFileConcurrently(ctx, audioFileName, downloadAudioURL, headers, 1)
 	if err != nil {
 		logger.Errorf(err, "Failed to download article audio, title: %s", title)
 		_ = os.Remove(audioFileName)
+		return err
+	}
+	// audio without tag still plays, so tag failure is not a download failure
+	if err := mp3.WriteTag(audioFileName, tag); err != nil {
+		logger.Warnf("Failed to write article audio tag, title: %s, err: %v", title, err)
 	}
 	logger.Infof("Finish download article audio, title: %s", title)
-	return err
+	return nil
+}
+
+// ArticleAudioPath returns the audio file path of article in column dir
+func ArticleAudioPath(columnDir string, article geektime.Article) string {
+	return filepath.Join(columnDir, filenamify.Filenamify(article.Title)+MP3Extension)
 }
@@ -0,0 +1,92 @@
+package audio
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/logger"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/mp3"
+)
+
+// ErrNoAudio means article has no audio, or no article of column has audio file
+var ErrNoAudio = errors.New("no audio")
+
+// MergeColumnAudio joins audio files of column articles in column order into one MP3 audiobook at dst,
+// with a chapter for every article. Title, album, artist and cover of audiobook are taken from tag.
+// Articles without audio file are left out, it returns the merged articles.
+func MergeColumnAudio(course geektime.Course, columnDir, dst string, tag mp3.Tag) ([]geektime.Article, error) {
+	logger.Infof("Begin merge column audio, columnID: %d, audioFileName: %s", course.ID, dst)
+
+	// chapters are written in tag before audio, so durations are read in first pass
+	// and frames in second pass, only one article audio is in memory at a time
+	var merged []geektime.Article
+	var start time.Duration
+	tag.Chapters = nil
+	for _, article := range course.Articles {
+		a, err := readArticleAudio(columnDir, article)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		merged = append(merged, article)
+		tag.Chapters = append(tag.Chapters, mp3.Chapter{Title: article.Title, Start: start, End: start + a.Duration})
+		start += a.Duration
+	}
+	if len(merged) == 0 {
+		return nil, ErrNoAudio
+	}
+
+	// write to temp file first so that a broken file never has the final name
+	tmp := dst + ".tmp"
+	if err := writeAudiobook(tmp, columnDir, tag, merged); err != nil {
+		_ = os.Remove(tmp)
+		return nil, err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return nil, err
+	}
+	logger.Infof("Finish merge column audio, columnID: %d, chapters: %d, duration: %s", course.ID, len(merged), start)
+	return merged, nil
+}
+
+func writeAudiobook(path, columnDir string, tag mp3.Tag, articles []geektime.Article) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(tag.Bytes())
+	for _, article := range articles {
+		if err != nil {
+			break
+		}
+		var a mp3.Audio
+		if a, err = readArticleAudio(columnDir, article); err == nil {
+			_, err = f.Write(a.Frames)
+		}
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// readArticleAudio reads audio frames of article audio file
+func readArticleAudio(columnDir string, article geektime.Article) (mp3.Audio, error) {
+	data, err := os.ReadFile(ArticleAudioPath(columnDir, article))
+	if os.IsNotExist(err) {
+		logger.Warnf("Article audio not found when merging column audio, articleID: %d, title: %s", article.AID, article.Title)
+		return mp3.Audio{}, err
+	}
+	if err != nil {
+		return mp3.Audio{}, err
+	}
+	a, err := mp3.ReadAudio(data)
+	if err != nil {
+		logger.Errorf(err, "Failed to read article audio, articleID: %d, title: %s", article.AID, article.Title)
+	}
+	return a, err
+}
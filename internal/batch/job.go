@@ -0,0 +1,71 @@
+package batch
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/nicoxiang/geektime-downloader/internal/config"
+)
+
+// Job is one entry of the job file
+type Job struct {
+	// Type is product type name, see ui.ProductTypeOptions
+	Type string `yaml:"type" json:"type"`
+	// ID is product id
+	ID int `yaml:"id" json:"id"`
+	// Articles optional article IDs or ID ranges, e.g. 1,3,5-8
+	Articles string `yaml:"articles,omitempty" json:"articles,omitempty"`
+
+	// per entry overrides of global config, nil means use global config
+	Quality          *string `yaml:"quality,omitempty" json:"quality,omitempty"`
+	ColumnOutputType *int    `yaml:"output,omitempty" json:"output,omitempty"`
+	DownloadComments *int    `yaml:"comments,omitempty" json:"comments,omitempty"`
+	IsEnterprise     *bool   `yaml:"enterprise,omitempty" json:"enterprise,omitempty"`
+}
+
+// JobFile is the batch job manifest, json is accepted as well since it's a subset of yaml
+type JobFile struct {
+	Jobs []Job `yaml:"jobs" json:"jobs"`
+}
+
+// LoadJobFile reads and parses job file
+func LoadJobFile(path string) (JobFile, error) {
+	var f JobFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return f, err
+	}
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return f, fmt.Errorf("parse job file %s failed: %w", path, err)
+	}
+	if len(f.Jobs) == 0 {
+		return f, fmt.Errorf("job file %s has no jobs", path)
+	}
+	return f, nil
+}
+
+// jobFileSource is the setting source of job overrides
+const jobFileSource = "job file"
+
+// Apply returns a copy of global config with job overrides applied
+func (j Job) Apply(cfg config.AppConfig) config.AppConfig {
+	if j.Quality != nil {
+		cfg.Quality = *j.Quality
+		cfg.SetSource("quality", jobFileSource)
+	}
+	if j.ColumnOutputType != nil {
+		cfg.ColumnOutputType = *j.ColumnOutputType
+		cfg.SetSource("output", jobFileSource)
+	}
+	if j.DownloadComments != nil {
+		cfg.DownloadComments = *j.DownloadComments
+		cfg.SetSource("comments", jobFileSource)
+	}
+	if j.IsEnterprise != nil {
+		cfg.IsEnterprise = *j.IsEnterprise
+		cfg.SetSource("enterprise", jobFileSource)
+	}
+	return cfg
+}
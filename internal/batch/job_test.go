@@ -0,0 +1,68 @@
+package batch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nicoxiang/geektime-downloader/internal/config"
+)
+
+func TestLoadJobFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"yaml", `
+jobs:
+  - type: column
+    id: 100
+    articles: 1,3-5
+    quality: hd
+    output: 3
+  - type: daily
+    id: 200
+`},
+		{"json", `{"jobs": [
+  {"type": "column", "id": 100, "articles": "1,3-5", "quality": "hd", "output": 3},
+  {"type": "daily", "id": 200}
+]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "jobs."+tt.name)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			f, err := LoadJobFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(f.Jobs) != 2 || f.Jobs[0].ID != 100 || f.Jobs[0].Articles != "1,3-5" || f.Jobs[1].Type != "daily" {
+				t.Fatalf("jobs = %+v", f.Jobs)
+			}
+
+			global := config.AppConfig{Quality: "sd", ColumnOutputType: 1}
+			cfg := f.Jobs[0].Apply(global)
+			if cfg.Quality != "hd" || cfg.ColumnOutputType != 3 || cfg.Source("quality") != jobFileSource {
+				t.Errorf("quality = %s set by %s, output = %d", cfg.Quality, cfg.Source("quality"), cfg.ColumnOutputType)
+			}
+			if global.Quality != "sd" || global.Source("quality") == jobFileSource {
+				t.Error("global config is changed by job overrides")
+			}
+			if cfg := f.Jobs[1].Apply(global); cfg.Quality != "sd" || cfg.ColumnOutputType != 1 {
+				t.Errorf("job without overrides: quality = %s, output = %d", cfg.Quality, cfg.ColumnOutputType)
+			}
+		})
+	}
+}
+
+func TestLoadJobFile_NoJobs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.yaml")
+	if err := os.WriteFile(path, []byte("jobs: []\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadJobFile(path); err == nil {
+		t.Error("job file without jobs is loaded")
+	}
+}
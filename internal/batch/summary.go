@@ -0,0 +1,54 @@
+package batch
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// Status of a finished job
+type Status string
+
+const (
+	// StatusSucceeded job downloaded successfully
+	StatusSucceeded Status = "succeeded"
+	// StatusSkipped job not downloaded, e.g. not purchased
+	StatusSkipped Status = "skipped"
+	// StatusFailed job download failed
+	StatusFailed Status = "failed"
+)
+
+// Result of one job
+type Result struct {
+	Job    Job
+	Title  string
+	Status Status
+	Reason string
+}
+
+// Count returns the number of results in status s
+func Count(results []Result, s Status) int {
+	n := 0
+	for _, r := range results {
+		if r.Status == s {
+			n++
+		}
+	}
+	return n
+}
+
+// PrintSummary prints results as a table
+func PrintSummary(w io.Writer, results []Result) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "#\tTYPE\tID\tTITLE\tSTATUS\tREASON")
+	for i, r := range results {
+		_, _ = fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\t%s\n", i+1, r.Job.Type, r.Job.ID, r.Title, r.Status, r.Reason)
+	}
+	_ = tw.Flush()
+	_, _ = fmt.Fprintf(w, "共 %d 项, 成功 %d, 跳过 %d, 失败 %d\n",
+		len(results),
+		Count(results, StatusSucceeded),
+		Count(results, StatusSkipped),
+		Count(results, StatusFailed),
+	)
+}
@@ -17,13 +17,52 @@ type AppConfig struct {
 	Gcess                  string
 	DownloadFolder         string
 	Quality                string
+	VideoFormat            string
 	DownloadComments       int
 	ColumnOutputType       int
+	PDFEngine              string
+	MergePDF               bool
+	DeleteArticlePDF       bool
+	Audiobook              bool
 	PrintPDFWaitSeconds    int
 	PrintPDFTimeoutSeconds int
 	Interval               int
+	KeepGoing              bool
+	APIRate                int
+	PageRate               int
+	CDNRate                int
+	RateLimitRetries       int
+	SegmentConcurrency     int
+	ArticleConcurrency     int
+	RangeConcurrency       int
+	Subtitles              bool
+	EmbedSubtitles         bool
 	IsEnterprise           bool
 	LogLevel               string
+	Record                 string
+	Replay                 string
+
+	// sources records where each setting comes from, keyed by flag name
+	sources map[string]string
+}
+
+// SetSource records where the setting named by flag name comes from.
+// The map is copied so config copies made before keep their own sources.
+func (cfg *AppConfig) SetSource(name, source string) {
+	sources := make(map[string]string, len(cfg.sources)+1)
+	for k, v := range cfg.sources {
+		sources[k] = v
+	}
+	sources[name] = source
+	cfg.sources = sources
+}
+
+// Source returns where the setting named by flag name comes from
+func (cfg *AppConfig) Source(name string) string {
+	if s, ok := cfg.sources[name]; ok {
+		return s
+	}
+	return sourceDefault
 }
 
 func ReadCookiesFromInput(cfg *AppConfig) []*http.Cookie {
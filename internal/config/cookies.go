@@ -0,0 +1,106 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+)
+
+const (
+	// CookieFileName is the saved login cookie file name under user config dir
+	CookieFileName = "cookies.json"
+
+	// sourceCookieFile is the setting source of cookies saved by login command
+	sourceCookieFile = "cookie file %s"
+)
+
+// savedCookies is the structure of cookie file
+type savedCookies struct {
+	Gcid    string    `json:"gcid"`
+	Gcess   string    `json:"gcess"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+// CookieFilePath returns saved login cookie file path under user config dir
+func CookieFilePath() string {
+	userConfigDir, _ := os.UserConfigDir()
+	return filepath.Join(userConfigDir, GeektimeDownloaderFolder, CookieFileName)
+}
+
+// SaveCookies saves login cookies to cookie file, which is only readable by current user
+func SaveCookies(cookies []*http.Cookie) error {
+	var s savedCookies
+	for _, c := range cookies {
+		switch c.Name {
+		case geektime.GCID:
+			s.Gcid = c.Value
+		case geektime.GCESS:
+			s.Gcess = c.Value
+		}
+	}
+	s.SavedAt = time.Now()
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := CookieFilePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	// write to temp file then rename, so a broken write never replace a good cookie file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	// WriteFile do not change permission of existing file
+	if err := os.Chmod(tmp, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadCookies loads login cookies from cookie file, returns os.ErrNotExist if not login yet
+func LoadCookies() ([]*http.Cookie, error) {
+	data, err := os.ReadFile(CookieFilePath())
+	if err != nil {
+		return nil, err
+	}
+	var s savedCookies
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if s.Gcid == "" || s.Gcess == "" {
+		return nil, os.ErrNotExist
+	}
+	return ReadCookiesFromInput(&AppConfig{Gcid: s.Gcid, Gcess: s.Gcess}), nil
+}
+
+// RemoveCookies removes saved login cookie file
+func RemoveCookies() error {
+	err := os.Remove(CookieFilePath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// ApplySavedCookies sets gcid and gcess in cfg from cookies saved by login command
+func ApplySavedCookies(cfg *AppConfig, cookies []*http.Cookie) {
+	source := fmt.Sprintf(sourceCookieFile, CookieFilePath())
+	for _, c := range cookies {
+		switch c.Name {
+		case geektime.GCID:
+			cfg.Gcid = c.Value
+			cfg.SetSource("gcid", source)
+		case geektime.GCESS:
+			cfg.Gcess = c.Value
+			cfg.SetSource("gcess", source)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+)
+
+func TestSaveCookies(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("AppData", dir)
+
+	if _, err := LoadCookies(); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("LoadCookies before login returns %v", err)
+	}
+
+	err := SaveCookies([]*http.Cookie{
+		{Name: geektime.GCID, Value: "id"},
+		{Name: geektime.GCESS, Value: "ess"},
+		{Name: "other", Value: "x"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(CookieFilePath())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0o600 {
+			t.Errorf("cookie file mode = %v, want 0600", info.Mode().Perm())
+		}
+	}
+
+	cookies, err := LoadCookies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg AppConfig
+	ApplySavedCookies(&cfg, cookies)
+	if cfg.Gcid != "id" || cfg.Gcess != "ess" {
+		t.Errorf("gcid = %q, gcess = %q", cfg.Gcid, cfg.Gcess)
+	}
+	if cfg.Source("gcid") == sourceDefault {
+		t.Error("source of saved gcid is not recorded")
+	}
+
+	if err := RemoveCookies(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCookies(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadCookies after logout returns %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ConfigFileName is the config file name under user config dir
+	ConfigFileName = "config.yaml"
+	// EnvPrefix is the prefix of environment variables which override settings
+	EnvPrefix = "GEEKTIME_"
+
+	// ConfigFlag is the flag name of config file path
+	ConfigFlag = "config"
+	// ProfileFlag is the flag name of selected profile
+	ProfileFlag = "profile"
+)
+
+// setting sources, from lowest to highest precedence
+const (
+	sourceDefault = "default"
+	sourceFile    = "config file %s"
+	sourceProfile = "profile '%s' in %s"
+	sourceEnv     = "env %s"
+	sourceFlag    = "flag --%s"
+)
+
+// File is the structure of config file, settings are keyed by flag name, e.g.
+//
+//	profile: personal
+//	folder: /data/geektime
+//	quality: hd
+//	profiles:
+//	  personal:
+//	    gcid: xxx
+//	    gcess: xxx
+//	  enterprise:
+//	    enterprise: true
+//	    gcid: xxx
+//	    gcess: xxx
+type File struct {
+	// Profile is the profile used when --profile is not set
+	Profile  string                            `yaml:"profile"`
+	Settings map[string]interface{}            `yaml:",inline"`
+	Profiles map[string]map[string]interface{} `yaml:"profiles"`
+}
+
+// DefaultConfigFilePath returns config file path under user config dir
+func DefaultConfigFilePath() string {
+	userConfigDir, _ := os.UserConfigDir()
+	return filepath.Join(userConfigDir, GeektimeDownloaderFolder, ConfigFileName)
+}
+
+// EnvName returns environment variable name of flag, e.g. print-pdf-wait -> GEEKTIME_PRINT_PDF_WAIT
+func EnvName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// Load fills flags not set in command line from environment variables, selected profile
+// and config file in order, and records where every setting comes from.
+// Precedence is flag > env > profile > config file > default.
+func Load(fs *pflag.FlagSet, cfg *AppConfig) error {
+	path, pathSource := lookupMeta(fs, ConfigFlag)
+	file, err := readFile(path, pathSource != sourceDefault)
+	if err != nil {
+		return err
+	}
+
+	profileName, _ := lookupMeta(fs, ProfileFlag)
+	if profileName == "" {
+		profileName = file.Profile
+	}
+	var profile map[string]interface{}
+	if profileName != "" {
+		var ok bool
+		if profile, ok = file.Profiles[profileName]; !ok {
+			return fmt.Errorf("profile '%s' not found in %s", profileName, path)
+		}
+	}
+
+	if err := checkUnknownSettings(fs, file.Settings, fmt.Sprintf(sourceFile, path)); err != nil {
+		return err
+	}
+	if err := checkUnknownSettings(fs, profile, fmt.Sprintf(sourceProfile, profileName, path)); err != nil {
+		return err
+	}
+
+	var setErr error
+	fs.VisitAll(func(f *pflag.Flag) {
+		if setErr != nil || isMetaFlag(f.Name) {
+			return
+		}
+		if f.Changed {
+			cfg.SetSource(f.Name, fmt.Sprintf(sourceFlag, f.Name))
+			return
+		}
+		if v, ok := os.LookupEnv(EnvName(f.Name)); ok {
+			setErr = setFlag(fs, f.Name, v, fmt.Sprintf(sourceEnv, EnvName(f.Name)), cfg)
+			return
+		}
+		if v, ok := profile[f.Name]; ok {
+			setErr = setFlag(fs, f.Name, v, fmt.Sprintf(sourceProfile, profileName, path), cfg)
+			return
+		}
+		if v, ok := file.Settings[f.Name]; ok {
+			setErr = setFlag(fs, f.Name, v, fmt.Sprintf(sourceFile, path), cfg)
+		}
+	})
+	return setErr
+}
+
+// lookupMeta returns value of config or profile flag, which can only be set by flag or env
+func lookupMeta(fs *pflag.FlagSet, name string) (string, string) {
+	f := fs.Lookup(name)
+	if f != nil && f.Changed {
+		return f.Value.String(), fmt.Sprintf(sourceFlag, name)
+	}
+	if v, ok := os.LookupEnv(EnvName(name)); ok {
+		return v, fmt.Sprintf(sourceEnv, EnvName(name))
+	}
+	if f != nil {
+		return f.Value.String(), sourceDefault
+	}
+	return "", sourceDefault
+}
+
+func isMetaFlag(name string) bool {
+	return name == ConfigFlag || name == ProfileFlag || name == "help"
+}
+
+// readFile reads config file, missing file is only an error if the path is specified explicitly
+func readFile(path string, mustExist bool) (File, error) {
+	var file File
+	if path == "" {
+		return file, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !mustExist {
+			return file, nil
+		}
+		return file, err
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return file, fmt.Errorf("parse config file %s failed: %w", path, err)
+	}
+	return file, nil
+}
+
+func checkUnknownSettings(fs *pflag.FlagSet, settings map[string]interface{}, source string) error {
+	var unknown []string
+	for k := range settings {
+		if fs.Lookup(k) == nil || isMetaFlag(k) {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown settings %s in %s", strings.Join(unknown, ", "), source)
+	}
+	return nil
+}
+
+func setFlag(fs *pflag.FlagSet, name string, value interface{}, source string, cfg *AppConfig) error {
+	if err := fs.Set(name, fmt.Sprint(value)); err != nil {
+		return fmt.Errorf("argument '%s' is not valid, set by %s: %w", name, source, err)
+	}
+	cfg.SetSource(name, source)
+	return nil
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+const testConfigFile = `
+profile: personal
+gcid: file-gcid
+quality: hd
+interval: 3
+profiles:
+  personal:
+    gcid: profile-gcid
+    interval: 20
+  enterprise:
+    enterprise: true
+`
+
+func newTestFlagSet(cfg *AppConfig) *pflag.FlagSet {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.String(ConfigFlag, "", "")
+	fs.String(ProfileFlag, "", "")
+	fs.StringVar(&cfg.Gcid, "gcid", "", "")
+	fs.StringVar(&cfg.Quality, "quality", "sd", "")
+	fs.IntVar(&cfg.Interval, "interval", 1, "")
+	fs.BoolVar(&cfg.IsEnterprise, "enterprise", false, "")
+	return fs
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ConfigFileName)
+	if err := os.WriteFile(path, []byte(testConfigFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		args       []string
+		env        map[string]string
+		gcid       string
+		gcidSource string
+		quality    string
+		interval   int
+		enterprise bool
+	}{
+		{
+			name:       "profile over file",
+			gcid:       "profile-gcid",
+			gcidSource: "profile 'personal' in " + path,
+			quality:    "hd",
+			interval:   20,
+		},
+		{
+			name:       "env over profile",
+			env:        map[string]string{"GEEKTIME_GCID": "env-gcid"},
+			gcid:       "env-gcid",
+			gcidSource: "env GEEKTIME_GCID",
+			quality:    "hd",
+			interval:   20,
+		},
+		{
+			name:       "flag over env",
+			args:       []string{"--gcid", "flag-gcid"},
+			env:        map[string]string{"GEEKTIME_GCID": "env-gcid"},
+			gcid:       "flag-gcid",
+			gcidSource: "flag --gcid",
+			quality:    "hd",
+			interval:   20,
+		},
+		{
+			name:       "profile selected by env",
+			env:        map[string]string{"GEEKTIME_PROFILE": "enterprise"},
+			gcid:       "file-gcid",
+			gcidSource: "config file " + path,
+			quality:    "hd",
+			interval:   3,
+			enterprise: true,
+		},
+		{
+			name:       "default without config file",
+			args:       []string{"--config", ""},
+			gcid:       "",
+			gcidSource: sourceDefault,
+			quality:    "sd",
+			interval:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GEEKTIME_GCID", "")
+			os.Unsetenv("GEEKTIME_GCID")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			var cfg AppConfig
+			fs := newTestFlagSet(&cfg)
+			if err := fs.Parse(append([]string{"--config", path}, tt.args...)); err != nil {
+				t.Fatal(err)
+			}
+			if err := Load(fs, &cfg); err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Gcid != tt.gcid || cfg.Source("gcid") != tt.gcidSource {
+				t.Errorf("gcid = %q set by %q, want %q set by %q", cfg.Gcid, cfg.Source("gcid"), tt.gcid, tt.gcidSource)
+			}
+			if cfg.Quality != tt.quality || cfg.Interval != tt.interval || cfg.IsEnterprise != tt.enterprise {
+				t.Errorf("quality = %s, interval = %d, enterprise = %v", cfg.Quality, cfg.Interval, cfg.IsEnterprise)
+			}
+		})
+	}
+}
+
+func TestLoad_InvalidArgumentNamesSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ConfigFileName)
+	if err := os.WriteFile(path, []byte(testConfigFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var cfg AppConfig
+	fs := newTestFlagSet(&cfg)
+	if err := fs.Parse([]string{"--config", path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(fs, &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	err := validateTiming(&cfg)
+	if err == nil {
+		t.Fatal("interval 20 from profile is valid")
+	}
+	want := "set by profile 'personal' in " + path
+	if !strings.Contains(err.Error(), "'interval'") || !strings.Contains(err.Error(), want) {
+		t.Errorf("error = %q, want it names interval and %q", err, want)
+	}
+}
+
+func TestLoad_UnknownSetting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ConfigFileName)
+	if err := os.WriteFile(path, []byte("qualty: hd\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var cfg AppConfig
+	fs := newTestFlagSet(&cfg)
+	if err := fs.Parse([]string{"--config", path}); err != nil {
+		t.Fatal(err)
+	}
+	err := Load(fs, &cfg)
+	if err == nil || !strings.Contains(err.Error(), "qualty") {
+		t.Errorf("error = %v, want unknown setting qualty", err)
+	}
+}
@@ -1,6 +1,10 @@
 package config
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/quality"
+)
 
 // ValidateConfig validates the application configuration.
 func ValidateConfig(cfg *AppConfig) error {
@@ -13,21 +17,47 @@ func ValidateConfig(cfg *AppConfig) error {
 	if err := validateQuality(cfg); err != nil {
 		return err
 	}
+	if err := validateVideoFormat(cfg); err != nil {
+		return err
+	}
+	if err := validateSubtitles(cfg); err != nil {
+		return err
+	}
 	if err := validateColumnOutputType(cfg); err != nil {
 		return err
 	}
+	if err := validatePDFEngine(cfg); err != nil {
+		return err
+	}
+	if err := validateMergePDF(cfg); err != nil {
+		return err
+	}
+	if err := validateAudiobook(cfg); err != nil {
+		return err
+	}
 	if err := validateLogLevel(cfg); err != nil {
 		return err
 	}
 	if err := validateTiming(cfg); err != nil {
 		return err
 	}
+	if err := validateRateLimit(cfg); err != nil {
+		return err
+	}
+	if err := validateTape(cfg); err != nil {
+		return err
+	}
 	return nil
 }
 
 func validateCookies(cfg *AppConfig) error {
+	// replayed responses don't check cookies
+	if cfg.Replay != "" {
+		return nil
+	}
 	if cfg.Gcid == "" || cfg.Gcess == "" {
-		return fmt.Errorf("arguments 'gcid' and 'gcess' are required and cannot be empty")
+		return fmt.Errorf("arguments 'gcid' and 'gcess' are required and cannot be empty, or run login command first, gcid set by %s, gcess set by %s",
+			cfg.Source("gcid"), cfg.Source("gcess"))
 	}
 	return nil
 }
@@ -44,25 +74,61 @@ func validateComments(cfg *AppConfig) error {
 	}
 
 	if !isValidCommentFlag {
-		return fmt.Errorf("argument 'comments' is not valid, must be one of 0, 1, 2")
+		return invalidArgument(cfg, "comments", "is not valid, must be one of 0, 1, 2")
 	}
 
 	return nil
 }
 
 func validateQuality(cfg *AppConfig) error {
-	validQuality := []string{"ld", "sd", "hd"}
+	if _, err := quality.Parse(cfg.Quality); err != nil {
+		return invalidArgument(cfg, "quality", fmt.Sprintf("is not valid, %s", err))
+	}
 
-	isValidQualityFlag := false
-	for _, v := range validQuality {
-		if cfg.Quality == v {
-			isValidQualityFlag = true
-			break
+	return nil
+}
+
+func validateVideoFormat(cfg *AppConfig) error {
+	validVideoFormats := []string{"ts", "mp4", "both"}
+
+	for _, v := range validVideoFormats {
+		if cfg.VideoFormat == v {
+			return nil
 		}
 	}
+	return invalidArgument(cfg, "video-format", "is not valid, must be one of ts, mp4, both")
+}
+
+func validatePDFEngine(cfg *AppConfig) error {
+	if cfg.PDFEngine != "chrome" && cfg.PDFEngine != "native" {
+		return invalidArgument(cfg, "pdf-engine", "is not valid, must be one of chrome, native")
+	}
 
-	if !isValidQualityFlag {
-		return fmt.Errorf("argument 'quality' is not valid, must be one of ld, sd, hd")
+	return nil
+}
+
+func validateMergePDF(cfg *AppConfig) error {
+	if cfg.MergePDF && cfg.ColumnOutputType&1 == 0 {
+		return invalidArgument(cfg, "merge-pdf", "requires pdf in output")
+	}
+	if cfg.DeleteArticlePDF && !cfg.MergePDF {
+		return invalidArgument(cfg, "delete-article-pdf", "requires merge-pdf")
+	}
+
+	return nil
+}
+
+func validateAudiobook(cfg *AppConfig) error {
+	if cfg.Audiobook && cfg.ColumnOutputType&4 == 0 {
+		return invalidArgument(cfg, "audiobook", "requires audio in output")
+	}
+
+	return nil
+}
+
+func validateSubtitles(cfg *AppConfig) error {
+	if cfg.EmbedSubtitles && cfg.VideoFormat == "ts" {
+		return invalidArgument(cfg, "embed-subtitles", "requires video-format mp4 or both")
 	}
 
 	return nil
@@ -80,15 +146,15 @@ func validateLogLevel(cfg *AppConfig) error {
 	}
 
 	if !isValidLogLevel {
-		return fmt.Errorf("argument 'log-level' is not valid, must be one of debug, info, warn, error, none")
+		return invalidArgument(cfg, "log-level", "is not valid, must be one of debug, info, warn, error, none")
 	}
 
 	return nil
 }
 
 func validateColumnOutputType(cfg *AppConfig) error {
-	if cfg.ColumnOutputType <= 0 || cfg.ColumnOutputType >= 8 {
-		return fmt.Errorf("argument 'output' is not valid, must be between 1 and 7")
+	if cfg.ColumnOutputType <= 0 || cfg.ColumnOutputType >= 32 {
+		return invalidArgument(cfg, "output", "is not valid, must be between 1 and 31")
 	}
 
 	return nil
@@ -96,16 +162,67 @@ func validateColumnOutputType(cfg *AppConfig) error {
 
 func validateTiming(cfg *AppConfig) error {
 	if cfg.Interval < 0 || cfg.Interval > 10 {
-		return fmt.Errorf("argument 'interval' must be between 0 and 10")
+		return invalidArgument(cfg, "interval", "must be between 0 and 10")
+	}
+
+	if cfg.SegmentConcurrency < 1 || cfg.SegmentConcurrency > 16 {
+		return invalidArgument(cfg, "segment-concurrency", "must be between 1 and 16")
+	}
+
+	if cfg.ArticleConcurrency < 1 || cfg.ArticleConcurrency > 8 {
+		return invalidArgument(cfg, "article-concurrency", "must be between 1 and 8")
+	}
+
+	if cfg.RangeConcurrency < 0 || cfg.RangeConcurrency > 16 {
+		return invalidArgument(cfg, "range-concurrency", "must be between 0 and 16")
 	}
 
 	if cfg.PrintPDFWaitSeconds < 0 || cfg.PrintPDFWaitSeconds > 60 {
-		return fmt.Errorf("argument 'print-pdf-wait' must be between 0 and 60")
+		return invalidArgument(cfg, "print-pdf-wait", "must be between 0 and 60")
 	}
 
 	if cfg.PrintPDFTimeoutSeconds <= 0 || cfg.PrintPDFTimeoutSeconds > 120 {
-		return fmt.Errorf("argument 'print-pdf-timeout' must be between 1 and 120")
+		return invalidArgument(cfg, "print-pdf-timeout", "must be between 1 and 120")
+	}
+
+	return nil
+}
+
+func validateRateLimit(cfg *AppConfig) error {
+	if cfg.APIRate < 0 {
+		return invalidArgument(cfg, "api-rate", "must not be negative")
+	}
+
+	if cfg.PageRate < 0 {
+		return invalidArgument(cfg, "page-rate", "must not be negative")
+	}
+
+	if cfg.CDNRate < 0 {
+		return invalidArgument(cfg, "cdn-rate", "must not be negative")
+	}
+
+	if cfg.RateLimitRetries < 0 || cfg.RateLimitRetries > 10 {
+		return invalidArgument(cfg, "rate-limit-retries", "must be between 0 and 10")
+	}
+
+	return nil
+}
+
+func validateTape(cfg *AppConfig) error {
+	if cfg.Record != "" && cfg.Replay != "" {
+		return invalidArgument(cfg, "replay", "cannot be used with record")
+	}
+
+	// chrome loads article pages by itself, they can't be replayed
+	if cfg.Replay != "" && cfg.ColumnOutputType&1 != 0 && cfg.PDFEngine != "native" {
+		return invalidArgument(cfg, "replay", "requires pdf-engine native when output contains pdf")
 	}
 
 	return nil
 }
+
+// invalidArgument returns validation error of the setting named by flag name,
+// tells user where the invalid value comes from.
+func invalidArgument(cfg *AppConfig, name, reason string) error {
+	return fmt.Errorf("argument '%s' %s, set by %s", name, reason, cfg.Source(name))
+}
@@ -2,13 +2,17 @@ package course
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/briandowns/spinner"
@@ -16,12 +20,15 @@ import (
 
 	"github.com/nicoxiang/geektime-downloader/internal/audio"
 	"github.com/nicoxiang/geektime-downloader/internal/config"
+	"github.com/nicoxiang/geektime-downloader/internal/epub"
 	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+	"github.com/nicoxiang/geektime-downloader/internal/geektime/response"
 	"github.com/nicoxiang/geektime-downloader/internal/markdown"
 	"github.com/nicoxiang/geektime-downloader/internal/pdf"
 	"github.com/nicoxiang/geektime-downloader/internal/pkg/filenamify"
-	"github.com/nicoxiang/geektime-downloader/internal/pkg/files"
 	"github.com/nicoxiang/geektime-downloader/internal/pkg/logger"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/mp3"
+	"github.com/nicoxiang/geektime-downloader/internal/site"
 	"github.com/nicoxiang/geektime-downloader/internal/ui"
 	"github.com/nicoxiang/geektime-downloader/internal/video"
 )
@@ -30,8 +37,16 @@ const (
 	outputPDF   = 1 << 0 // 1
 	outputMD    = 1 << 1 // 2
 	outputAudio = 1 << 2 // 4
+	outputEPUB  = 1 << 3 // 8
+	outputHTML  = 1 << 4 // 16
 )
 
+// commentsExtension is the extension of article comments json file
+const commentsExtension = ".comments.json"
+
+// columnJournalID is the journal id of outputs of whole column, no article has id 0
+const columnJournalID = 0
+
 type CourseDownloader struct {
 	ctx                context.Context
 	cfg                *config.AppConfig
@@ -39,10 +54,22 @@ type CourseDownloader struct {
 	concurrency        int
 	waitRand           *rand.Rand
 	downloadingSpinner *spinner.Spinner
+
+	journalsMu sync.Mutex
+	journals   map[string]*journal
+}
+
+// articleOutput is one output file of article
+type articleOutput struct {
+	name     string
+	fullPath string
 }
 
 func NewCourseDownloader(ctx context.Context, cfg *config.AppConfig, geektimeClient *geektime.Client, sp *spinner.Spinner) *CourseDownloader {
-	concurrency := int(math.Ceil(float64(runtime.NumCPU()) / 2.0))
+	concurrency := cfg.RangeConcurrency
+	if concurrency <= 0 {
+		concurrency = int(math.Ceil(float64(runtime.NumCPU()) / 2.0))
+	}
 	if concurrency <= 0 {
 		concurrency = 1
 	}
@@ -53,36 +80,99 @@ func NewCourseDownloader(ctx context.Context, cfg *config.AppConfig, geektimeCli
 		concurrency:        concurrency,
 		waitRand:           rand.New(rand.NewSource(time.Now().UnixNano())),
 		downloadingSpinner: sp,
+		journals:           make(map[string]*journal),
 	}
 }
 
 // DownloadAll manages the bulk download process for all articles in a selected product (course).
-// Returns an error if any step in the download process fails.
+// Returns an error if any step in the download process fails. In keep going mode, failed articles
+// are retried once after others, and the ones still failing are written to failures.json of column dir,
+// ErrPartialFailure is returned in this case.
 func (d *CourseDownloader) DownloadAll(course geektime.Course, productType ui.ProductTypeSelectOption) error {
 	columnDir, err := d.mkDownloadColumnDir(course.Title)
 	if err != nil {
 		return err
 	}
+	failures := &failureCollector{enabled: d.cfg.KeepGoing}
+	column := geektime.Article{AID: columnJournalID, Title: course.Title}
 
 	if geektime.IsTextCourse(course) {
 		fmt.Printf("正在下载专栏 《%s》 中的所有文章\n", course.Title)
 		total := len(course.Articles)
 		var downloaded int
 
+		mergePDF := d.cfg.MergePDF && d.cfg.ColumnOutputType&outputPDF != 0
+		var pdfStale bool
+		if mergePDF {
+			if pdfStale, err = d.prepareColumnPDF(course, columnDir); err != nil {
+				return err
+			}
+		}
+
+		download := func(article geektime.Article) error {
+			return d.downloadTextArticle(course, article, columnDir, false)
+		}
+		var articles []geektime.Article
 		for _, article := range course.Articles {
-			skip := d.skipDownloadTextArticle(article, columnDir, false)
-			if !skip {
-				logger.Infof("Begin download article, articleID: %d, articleTitle: %s", article.AID, article.Title)
-				if err := d.downloadTextArticle(article, columnDir, false); err != nil {
-					return err
-				}
-				d.waitRandomTime()
+			if d.skipDownloadTextArticle(article, columnDir, false) {
+				increaseDownloadedTextArticleCount(total, &downloaded)
+				continue
+			}
+			articles = append(articles, article)
+		}
+		err := d.downloadTextArticles(course, articles, columnDir, func(article geektime.Article, err error) error {
+			if err != nil && !skipNotPurchasedArticle(article, err) && !failures.add(article, err) {
+				return err
 			}
 			increaseDownloadedTextArticleCount(total, &downloaded)
+			return nil
+		})
+		if err != nil {
+			return failures.abort(columnDir, err)
+		}
+		if err := failures.retry(download); err != nil {
+			return failures.abort(columnDir, err)
+		}
+
+		// column outputs are made of downloaded articles even if some articles failed,
+		// so they are made after failed articles are retried
+		var columnOutputs []func() error
+		if d.cfg.ColumnOutputType&outputMD != 0 {
+			columnOutputs = append(columnOutputs, func() error {
+				if err := markdown.WriteIndex(course, columnDir); err != nil {
+					return &outputError{journalOutputMarkdown, err}
+				}
+				return nil
+			})
+		}
+		if d.cfg.ColumnOutputType&outputHTML != 0 {
+			columnOutputs = append(columnOutputs, func() error {
+				if err := site.Build(course, filepath.Join(columnDir, site.DirName)); err != nil {
+					return &outputError{journalOutputHTML, err}
+				}
+				return nil
+			})
+		}
+		if mergePDF && pdfStale {
+			columnOutputs = append(columnOutputs, func() error {
+				return d.mergeColumnPDF(course, columnDir)
+			})
+		}
+		if d.cfg.Audiobook && d.cfg.ColumnOutputType&outputAudio != 0 {
+			columnOutputs = append(columnOutputs, func() error {
+				return d.mergeColumnAudio(course, columnDir)
+			})
+		}
+		if d.cfg.ColumnOutputType&outputEPUB != 0 {
+			columnOutputs = append(columnOutputs, func() error {
+				return d.downloadColumnEPUB(course, columnDir)
+			})
+		}
+		if err := failures.runColumnOutputs(column, columnOutputs); err != nil {
+			return failures.abort(columnDir, err)
 		}
 	} else {
-		for _, article := range course.Articles {
-			skip := d.skipDownloadVideoArticle(article, columnDir, false)
+		download := func(article geektime.Article) error {
 			// 训练营特殊处理，训练营只能从文章详情中获取当前文章是否是视频，训练营目前只支持下载视频类文章，
 			// 下载所有时如果是文本类，直接跳过
 			if productType.IsUniversity() {
@@ -91,19 +181,28 @@ func (d *CourseDownloader) DownloadAll(course geektime.Course, productType ui.Pr
 					return err
 				}
 				if universityArticleDetail.Data.VideoID == "" {
-					skip = true
+					return nil
 				}
 			}
-			if !skip {
-				if err := d.downloadVideoArticle(course, productType, article, columnDir); err != nil {
-					return err
-				}
-				d.waitRandomTime()
+			return d.downloadVideoArticle(course, productType, article, columnDir)
+		}
+		for _, article := range course.Articles {
+			if d.skipDownloadVideoArticle(article, columnDir, false) {
+				continue
+			}
+			if err := download(article); err != nil && !skipNotPurchasedArticle(article, err) && !failures.add(article, err) {
+				return failures.abort(columnDir, err)
+			}
+			if err := d.waitRandomTime(d.ctx); err != nil {
+				return failures.abort(columnDir, err)
 			}
 		}
+		if err := failures.retry(download); err != nil {
+			return failures.abort(columnDir, err)
+		}
 	}
 
-	return nil
+	return failures.report(columnDir)
 }
 
 // DownloadArticle processes the download of a single article from Geektime.
@@ -122,7 +221,7 @@ func (d *CourseDownloader) DownloadArticle(course geektime.Course, productType u
 		if skip {
 			return nil
 		}
-		return d.downloadTextArticle(article, columnDir, overwrite)
+		return d.downloadTextArticle(course, article, columnDir, overwrite)
 	} else {
 		skip := d.skipDownloadVideoArticle(article, columnDir, overwrite)
 		if skip {
@@ -134,12 +233,38 @@ func (d *CourseDownloader) DownloadArticle(course geektime.Course, productType u
 
 // DownloadSingleVideoProduct downloads a single video product.
 // 每日一课，大厂案例等
+// Video is named by product title, the definition actually downloaded is recorded in journal of product dir.
 func (d *CourseDownloader) DownloadSingleVideoProduct(title string, articleID int, sourceType int) error {
 	columnDir, err := d.mkDownloadColumnDir(title)
 	if err != nil {
 		return err
 	}
-	return video.DownloadArticleVideo(d.ctx, d.geektimeClient, articleID, sourceType, columnDir, d.cfg.Quality, d.concurrency)
+	j, err := d.columnJournal(columnDir)
+	if err != nil {
+		return err
+	}
+	article := geektime.Article{AID: articleID, Title: title}
+	outputs := d.videoOutputs(article, columnDir)
+	var definition string
+	err = d.downloadOutputs(j, article, outputs, func() (err error) {
+		definition, err = video.DownloadArticleVideo(d.ctx, d.geektimeClient, articleID, sourceType, title, columnDir, d.videoOptions())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return setVideoDefinition(j, article, outputs, definition)
+}
+
+func (d *CourseDownloader) videoOptions() video.Options {
+	return video.Options{
+		Quality:            d.cfg.Quality,
+		Format:             d.cfg.VideoFormat,
+		SegmentConcurrency: d.cfg.SegmentConcurrency,
+		RangeConcurrency:   d.concurrency,
+		Subtitles:          d.cfg.Subtitles,
+		EmbedSubtitles:     d.cfg.EmbedSubtitles,
+	}
 }
 
 func increaseDownloadedTextArticleCount(total int, downloaded *int) {
@@ -151,52 +276,135 @@ func increaseDownloadedTextArticleCount(total int, downloaded *int) {
 	fmt.Printf("\r已完成下载%d/%d", current, total)
 }
 
+// skipNotPurchasedArticle checks if err is API rejecting the article without access or taken offline,
+// such article is skipped when downloading all, other articles can still be downloaded.
+func skipNotPurchasedArticle(article geektime.Article, err error) bool {
+	switch {
+	case geektime.IsNotPurchased(err):
+		logger.Warnf("Skip article without access, articleID: %d, title: %s, err: %v", article.AID, article.Title, err)
+		fmt.Printf("\r《%s》 尚未购买, 已跳过\n", article.Title)
+	case geektime.IsArticleOffline(err):
+		logger.Warnf("Skip article taken offline, articleID: %d, title: %s, err: %v", article.AID, article.Title, err)
+		fmt.Printf("\r《%s》 已下架, 已跳过\n", article.Title)
+	default:
+		return false
+	}
+	return true
+}
+
 func (d *CourseDownloader) skipDownloadTextArticle(article geektime.Article, columnDir string, overwrite bool) bool {
 	if overwrite {
 		return false
 	}
 
-	needDownloadPDF := d.cfg.ColumnOutputType&outputPDF != 0
-	needDownloadMD := d.cfg.ColumnOutputType&outputMD != 0
-	needDownloadAudio := d.cfg.ColumnOutputType&outputAudio != 0
-
-	if needDownloadPDF {
-		pdfFileName := filepath.Join(columnDir, filenamify.Filenamify(article.Title)+pdf.PDFExtension)
-		if !files.CheckFileExists(pdfFileName) {
-			return false
-		}
-	}
-	if needDownloadMD {
-		markdownFileName := filepath.Join(columnDir, filenamify.Filenamify(article.Title)+markdown.MDExtension)
-		if !files.CheckFileExists(markdownFileName) {
-			return false
-		}
+	j, err := d.columnJournal(columnDir)
+	if err != nil {
+		return false
 	}
-	if needDownloadAudio {
-		audioFileName := filepath.Join(columnDir, filenamify.Filenamify(article.Title)+audio.MP3Extension)
-		if !files.CheckFileExists(audioFileName) {
+	for _, o := range d.articleOutputs(article, columnDir) {
+		if !j.completed(article.AID, article.Title, o.name, o.fullPath) {
 			return false
 		}
 	}
-
 	return true
 }
 
+// articleOutputs returns output files of text article selected by ColumnOutputType
+func (d *CourseDownloader) articleOutputs(article geektime.Article, columnDir string) []articleOutput {
+	var outputs []articleOutput
+	fileName := filepath.Join(columnDir, filenamify.Filenamify(article.Title))
+	if d.cfg.ColumnOutputType&outputPDF != 0 {
+		outputs = append(outputs, articleOutput{journalOutputPDF, fileName + pdf.PDFExtension})
+	}
+	if d.cfg.ColumnOutputType&outputMD != 0 {
+		outputs = append(outputs, articleOutput{journalOutputMarkdown, fileName + markdown.MDExtension})
+	}
+	if d.cfg.ColumnOutputType&outputAudio != 0 {
+		outputs = append(outputs, articleOutput{journalOutputAudio, fileName + audio.MP3Extension})
+	}
+	if d.cfg.ColumnOutputType&outputHTML != 0 {
+		outputs = append(outputs, articleOutput{journalOutputHTML, filepath.Join(columnDir, site.DirName, site.PageName(article.Title))})
+	}
+	if d.exportComments() {
+		outputs = append(outputs, articleOutput{journalOutputComments, fileName + commentsExtension})
+	}
+	if d.cfg.ColumnOutputType&outputEPUB != 0 {
+		outputs = append(outputs, articleOutput{journalOutputChapter, epub.ChapterPath(columnDir, article.AID)})
+	}
+	return outputs
+}
+
 // downloadTextArticle downloads the content of a Geektime text article in various formats (PDF, Markdown, Audio, and Video).
 // The function supports overwriting existing files if specified.
-func (d *CourseDownloader) downloadTextArticle(article geektime.Article, columnDir string, overwrite bool) error {
-	needDownloadPDF := d.cfg.ColumnOutputType&outputPDF != 0
-	needDownloadMD := d.cfg.ColumnOutputType&outputMD != 0
-	needDownloadAudio := d.cfg.ColumnOutputType&outputAudio != 0
+func (d *CourseDownloader) downloadTextArticle(course geektime.Course, article geektime.Article, columnDir string, overwrite bool) error {
+	j, err := d.columnJournal(columnDir)
+	if err != nil {
+		return err
+	}
 
-	articleInfo, err := d.geektimeClient.V1ArticleInfo(article.AID)
+	ta, err := d.loadTextArticle(article)
 	if err != nil {
 		return err
 	}
+	videoErr := d.downloadInlineVideos(d.ctx, ta, columnDir, overwrite)
+	if stopsDownload(videoErr) {
+		return videoErr
+	}
+	return errors.Join(videoErr, d.downloadTextOutputs(d.ctx, j, course, ta, columnDir, d.articleOutputs(article, columnDir), overwrite, nil))
+}
+
+// textArticle is a text article with its info, comments are fetched at most once for markdown and json outputs
+type textArticle struct {
+	article geektime.Article
+	info    response.V1ArticleResponse
+
+	commentsOnce sync.Once
+	comments     []geektime.Comment
+	commentsErr  error
+}
+
+func (d *CourseDownloader) loadTextArticle(article geektime.Article) (*textArticle, error) {
+	articleInfo, err := d.geektimeClient.V1ArticleInfo(article.AID)
+	if err != nil {
+		return nil, err
+	}
+	return &textArticle{article: article, info: articleInfo}, nil
+}
+
+// exportComments checks if comments are fetched from comment list api and saved beside articles
+func (d *CourseDownloader) exportComments() bool {
+	return d.cfg.DownloadComments != pdf.DownloadCommentsNone
+}
 
+func (d *CourseDownloader) loadComments(ta *textArticle) ([]geektime.Comment, error) {
+	if !d.exportComments() {
+		return nil, nil
+	}
+	ta.commentsOnce.Do(func() {
+		ta.comments, ta.commentsErr = d.geektimeClient.ArticleComments(ta.article.AID, d.cfg.DownloadComments == pdf.DownloadCommentsAll)
+	})
+	return ta.comments, ta.commentsErr
+}
+
+// hasInlineVideos checks if article content has videos which are saved as mp4 beside article
+func hasInlineVideos(ta *textArticle) bool {
+	hasVideo, videoURL := getVideoURLFromArticleContent(ta.info.Data.ArticleContent)
+	return hasVideo && videoURL != "" || len(ta.info.Data.InlineVideoSubtitles) > 0
+}
+
+// downloadInlineVideos downloads videos in article content as mp4, with their subtitles if configured
+func (d *CourseDownloader) downloadInlineVideos(ctx context.Context, ta *textArticle, columnDir string, overwrite bool) error {
+	if err := d.downloadInlineVideoFiles(ctx, ta, columnDir, overwrite); err != nil {
+		return &outputError{journalOutputVideo, err}
+	}
+	return nil
+}
+
+func (d *CourseDownloader) downloadInlineVideoFiles(ctx context.Context, ta *textArticle, columnDir string, overwrite bool) error {
+	article, articleInfo := ta.article, ta.info
 	hasVideo, videoURL := getVideoURLFromArticleContent(articleInfo.Data.ArticleContent)
 	if hasVideo && videoURL != "" {
-		if err := video.DownloadMP4(d.ctx, article.Title, columnDir, []string{videoURL}, overwrite); err != nil {
+		if err := video.DownloadMP4(ctx, d.geektimeClient.BaseURL(), article.Title, columnDir, []string{videoURL}, overwrite); err != nil {
 			return err
 		}
 	}
@@ -206,49 +414,468 @@ func (d *CourseDownloader) downloadTextArticle(article geektime.Article, columnD
 		for i, v := range articleInfo.Data.InlineVideoSubtitles {
 			videoURLs[i] = v.VideoURL
 		}
-		if err := video.DownloadMP4(d.ctx, article.Title, columnDir, videoURLs, overwrite); err != nil {
+		if err := video.DownloadMP4(ctx, d.geektimeClient.BaseURL(), article.Title, columnDir, videoURLs, overwrite); err != nil {
 			return err
 		}
+		if d.cfg.Subtitles {
+			for _, v := range articleInfo.Data.InlineVideoSubtitles {
+				if v.VideoSubtitle == "" {
+					continue
+				}
+				if err := video.DownloadMP4Subtitle(ctx, d.geektimeClient, article.Title, columnDir, v.VideoURL, v.VideoSubtitle); err != nil {
+					logger.Warnf("Failed to download article mp4 video subtitle, title: %s, subtitle: %s, err: %v", article.Title, v.VideoSubtitle, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// downloadTextOutputs downloads outputs of text article, PDF is printed in a tab of browser if it's not nil.
+// An output failed doesn't stop the others, errors of all failed outputs are returned joined.
+func (d *CourseDownloader) downloadTextOutputs(ctx context.Context,
+	j *journal,
+	course geektime.Course,
+	ta *textArticle,
+	columnDir string,
+	outputs []articleOutput,
+	overwrite bool,
+	browser *pdf.Browser,
+) error {
+	article, articleInfo := ta.article, ta.info
+	var errs []error
+	for _, o := range outputs {
+		var download func() error
+		switch o.name {
+		case journalOutputPDF:
+			download = func() error {
+				if d.cfg.PDFEngine == pdf.EngineNative {
+					return pdf.RenderArticleToPDF(ctx, d.geektimeClient.BaseURL(), article, articleInfo.Data.ArticleContent, columnDir)
+				}
+				if browser != nil {
+					return browser.PrintArticlePageToPDF(ctx, d.geektimeClient.BaseURL(), article, columnDir, d.geektimeClient.Cookies, d.cfg)
+				}
+				return pdf.PrintArticlePageToPDF(ctx,
+					d.geektimeClient.BaseURL(),
+					article,
+					columnDir,
+					d.geektimeClient.Cookies,
+					d.cfg,
+				)
+			}
+		case journalOutputMarkdown:
+			download = func() error {
+				comments, err := d.loadComments(ta)
+				if err != nil {
+					return err
+				}
+				return markdown.Download(ctx,
+					d.geektimeClient.BaseURL(),
+					articleInfo.Data.ArticleContent,
+					article.Title,
+					columnDir,
+					d.articleMeta(course, article, articleInfo),
+					comments,
+				)
+			}
+		case journalOutputAudio:
+			download = func() error {
+				return audio.DownloadAudio(ctx,
+					d.geektimeClient.BaseURL(),
+					articleInfo.Data.AudioDownloadURL,
+					columnDir,
+					article.Title,
+					d.audioTag(course, article, articleInfo, columnDir),
+				)
+			}
+		case journalOutputHTML:
+			download = func() error {
+				return site.WriteArticle(ctx,
+					d.geektimeClient.BaseURL(),
+					filepath.Join(columnDir, site.DirName),
+					course.Title,
+					article,
+					articleInfo.Data.ArticleContent,
+					articleInfo.Data.Neighbors.Left.ArticleTitle,
+					articleInfo.Data.Neighbors.Right.ArticleTitle,
+				)
+			}
+		case journalOutputComments:
+			download = func() error {
+				comments, err := d.loadComments(ta)
+				if err != nil {
+					return err
+				}
+				return saveComments(o.fullPath, comments)
+			}
+		case journalOutputChapter:
+			download = func() error {
+				chapter, err := epub.NewChapter(ctx,
+					d.geektimeClient.BaseURL(),
+					article.Title,
+					article.SectionTitle,
+					articleInfo.Data.ArticleContent,
+					columnDir,
+					article.AID,
+				)
+				if err != nil {
+					return err
+				}
+				return epub.SaveChapter(columnDir, article.AID, chapter)
+			}
+		}
+		if err := d.downloadOutput(j, article, o, overwrite, download); err != nil {
+			errs = append(errs, err)
+			if stopsDownload(err) {
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// downloadColumnEPUB joins chapters of articles into one EPUB book in column order, if it's not completed
+// or any chapter is newer. Chapters are converted by text article pipeline, articles are not loaded again here.
+func (d *CourseDownloader) downloadColumnEPUB(course geektime.Course, columnDir string) error {
+	j, err := d.columnJournal(columnDir)
+	if err != nil {
+		return err
+	}
+	column := geektime.Article{AID: columnJournalID, Title: course.Title}
+	o := articleOutput{journalOutputEPUB, filepath.Join(columnDir, filenamify.Filenamify(course.Title)+epub.EPUBExtension)}
+	stale := columnBookStale(j, course, o.name, o.fullPath, journalOutputChapter, func(article geektime.Article) string {
+		return epub.ChapterPath(columnDir, article.AID)
+	})
+	if !stale {
+		return nil
 	}
 
-	if needDownloadPDF {
-		if err := pdf.PrintArticlePageToPDF(d.ctx,
-			article,
-			columnDir,
-			d.geektimeClient.Cookies,
-			d.cfg,
-		); err != nil {
+	return d.downloadOutput(j, column, o, true, func() error {
+		logger.Infof("Begin download column epub, columnID: %d, title: %s", course.ID, course.Title)
+		book := epub.Book{
+			ID:       fmt.Sprintf("urn:geektime:column:%d", course.ID),
+			Title:    course.Title,
+			Author:   course.Author,
+			Language: "zh-CN",
+		}
+		if course.CoverURL != "" {
+			localPaths, err := markdown.DownloadImages(d.ctx, d.geektimeClient.BaseURL(), []string{course.CoverURL}, filepath.Join(columnDir, "images"))
+			if err != nil {
+				return err
+			}
+			if p := localPaths[course.CoverURL]; epub.MediaType(p) != "" {
+				book.Cover = &epub.Image{Href: epub.ImageHref(columnDir, p), LocalPath: p}
+			}
+		}
+
+		// articles not downloaded, e.g. not purchased, are left out
+		for _, article := range course.Articles {
+			chapter, err := epub.LoadChapter(columnDir, article.AID)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			book.Chapters = append(book.Chapters, chapter)
+		}
+
+		if err := book.Write(o.fullPath); err != nil {
 			return err
 		}
+		logger.Infof("Finish download column epub, columnID: %d, title: %s", course.ID, course.Title)
+		return nil
+	})
+}
+
+// articleMeta returns front matter of article markdown, enterprise article has no source since its page is not at a fixed url
+func (d *CourseDownloader) articleMeta(course geektime.Course, article geektime.Article, articleInfo response.V1ArticleResponse) markdown.Meta {
+	meta := markdown.Meta{
+		ArticleID:     article.AID,
+		ColumnID:      course.ID,
+		ColumnTitle:   course.Title,
+		Section:       article.SectionTitle,
+		Author:        articleInfo.Data.AuthorName,
+		AudioDuration: articleInfo.Data.AudioTime,
+	}
+	if !d.cfg.IsEnterprise {
+		meta.SourceURL = d.geektimeClient.ArticleURL(article.AID)
+	}
+	if meta.Author == "" {
+		meta.Author = course.Author
+	}
+	if articleInfo.Data.ArticleCtime > 0 {
+		meta.Published = time.Unix(articleInfo.Data.ArticleCtime, 0)
+	}
+	return meta
+}
+
+// saveComments writes comments of article into json file
+func saveComments(fullPath string, comments []geektime.Comment) error {
+	if comments == nil {
+		comments = []geektime.Comment{}
+	}
+	data, err := json.MarshalIndent(comments, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := fullPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fullPath)
+}
+
+// columnPDFPath returns the file path of column PDF book
+func columnPDFPath(course geektime.Course, columnDir string) string {
+	return filepath.Join(columnDir, filenamify.Filenamify(course.Title)+pdf.PDFExtension)
+}
+
+// prepareColumnPDF checks if column PDF book needs to be merged again, article PDFs deleted
+// after last merge are downloaded again in this case.
+func (d *CourseDownloader) prepareColumnPDF(course geektime.Course, columnDir string) (bool, error) {
+	j, err := d.columnJournal(columnDir)
+	if err != nil {
+		return false, err
+	}
+	stale := columnBookStale(j, course, journalOutputPDFBook, columnPDFPath(course, columnDir), journalOutputPDF, func(article geektime.Article) string {
+		return pdf.ArticlePDFPath(columnDir, article)
+	})
+	if stale {
+		for _, article := range course.Articles {
+			if err := j.unmerge(article.AID, journalOutputPDF); err != nil {
+				return false, err
+			}
+		}
+	}
+	return stale, nil
+}
+
+// columnBookStale checks if column book joined from article outputs needs to be written again,
+// because it's not completed, or any article output is not downloaded yet or downloaded after it.
+func columnBookStale(j *journal, course geektime.Course, bookOutput, bookPath, articleOutput string, articlePath func(geektime.Article) string) bool {
+	if !j.completed(columnJournalID, course.Title, bookOutput, bookPath) {
+		return true
+	}
+	written := j.updatedAt(columnJournalID, bookOutput)
+	for _, article := range course.Articles {
+		if !j.completed(article.AID, article.Title, articleOutput, articlePath(article)) || j.updatedAt(article.AID, articleOutput).After(written) {
+			return true
+		}
 	}
+	return false
+}
 
-	if needDownloadMD {
-		if err := markdown.Download(d.ctx,
-			articleInfo.Data.ArticleContent,
-			article.Title,
-			columnDir,
-			article.AID,
-		); err != nil {
+// mergeColumnPDF merges article PDFs into column PDF book, article PDFs are deleted after merged if configured
+func (d *CourseDownloader) mergeColumnPDF(course geektime.Course, columnDir string) error {
+	j, err := d.columnJournal(columnDir)
+	if err != nil {
+		return err
+	}
+	column := geektime.Article{AID: columnJournalID, Title: course.Title}
+	o := articleOutput{journalOutputPDFBook, columnPDFPath(course, columnDir)}
+
+	var merged []geektime.Article
+	if err := d.downloadOutput(j, column, o, true, func() error {
+		merged, err = pdf.MergeColumnPDF(course, columnDir, o.fullPath)
+		return err
+	}); err != nil {
+		return err
+	}
+	if !d.cfg.DeleteArticlePDF {
+		return nil
+	}
+	for _, article := range merged {
+		if err := os.Remove(pdf.ArticlePDFPath(columnDir, article)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := j.merge(article.AID, journalOutputPDF); err != nil {
 			return err
 		}
 	}
+	return nil
+}
+
+// mergeColumnAudio joins article audios into column audiobook, if it's not completed or any article audio is newer
+func (d *CourseDownloader) mergeColumnAudio(course geektime.Course, columnDir string) error {
+	j, err := d.columnJournal(columnDir)
+	if err != nil {
+		return err
+	}
+	column := geektime.Article{AID: columnJournalID, Title: course.Title}
+	o := articleOutput{journalOutputAudiobook, filepath.Join(columnDir, filenamify.Filenamify(course.Title)+audio.MP3Extension)}
+	stale := columnBookStale(j, course, o.name, o.fullPath, journalOutputAudio, func(article geektime.Article) string {
+		return audio.ArticleAudioPath(columnDir, article)
+	})
+	if !stale {
+		return nil
+	}
+
+	tag := mp3.Tag{
+		Title:  course.Title,
+		Album:  course.Title,
+		Artist: course.Author,
+		Cover:  d.columnCover(course, columnDir),
+	}
+	return d.downloadOutput(j, column, o, true, func() error {
+		_, err := audio.MergeColumnAudio(course, columnDir, o.fullPath, tag)
+		// columns without audio are common, audiobook is recorded as unavailable
+		if errors.Is(err, audio.ErrNoAudio) {
+			logger.Warnf("No article audio to merge, columnID: %d, title: %s", course.ID, course.Title)
+		}
+		return err
+	})
+}
+
+// audioTag returns tag of article audio, track number is the article order in column
+func (d *CourseDownloader) audioTag(course geektime.Course, article geektime.Article, articleInfo response.V1ArticleResponse, columnDir string) mp3.Tag {
+	tag := mp3.Tag{
+		Title:      article.Title,
+		Album:      course.Title,
+		Artist:     articleInfo.Data.AudioDubber,
+		TrackTotal: len(course.Articles),
+		Cover:      d.columnCover(course, columnDir),
+	}
+	for i, a := range course.Articles {
+		if a.AID == article.AID {
+			tag.Track = i + 1
+			break
+		}
+	}
+	if tag.Artist == "" {
+		tag.Artist = articleInfo.Data.AuthorName
+	}
+	if tag.Artist == "" {
+		tag.Artist = course.Author
+	}
+	return tag
+}
+
+// columnCover returns column cover image, it's downloaded into images of column dir at first use.
+// Audio is still tagged without cover if the download fails.
+func (d *CourseDownloader) columnCover(course geektime.Course, columnDir string) []byte {
+	if course.CoverURL == "" {
+		return nil
+	}
+	imagesFolder := filepath.Join(columnDir, "images")
+	name := path.Base(strings.SplitN(course.CoverURL, "?", 2)[0])
+	if cover, err := os.ReadFile(filepath.Join(imagesFolder, name)); err == nil {
+		return cover
+	}
+	localPaths, err := markdown.DownloadImages(d.ctx, d.geektimeClient.BaseURL(), []string{course.CoverURL}, imagesFolder)
+	if err != nil {
+		logger.Warnf("Failed to download column cover, columnID: %d, err: %v", course.ID, err)
+		return nil
+	}
+	cover, err := os.ReadFile(localPaths[course.CoverURL])
+	if err != nil {
+		logger.Warnf("Failed to read column cover, columnID: %d, err: %v", course.ID, err)
+		return nil
+	}
+	return cover
+}
+
+// downloadOutput downloads one output file of article if it's not completed yet,
+// and records download state in journal.
+func (d *CourseDownloader) downloadOutput(j *journal, article geektime.Article, o articleOutput, overwrite bool, download func() error) error {
+	if !overwrite && j.completed(article.AID, article.Title, o.name, o.fullPath) {
+		return nil
+	}
+	return d.downloadOutputs(j, article, []articleOutput{o}, download)
+}
 
-	if needDownloadAudio {
-		if err := audio.DownloadAudio(d.ctx, articleInfo.Data.AudioDownloadURL, columnDir, article.Title); err != nil {
+// downloadOutputs records download state of output files which are written by one download in journal.
+// Outputs are recorded as unavailable if download tells the article has no such output.
+func (d *CourseDownloader) downloadOutputs(j *journal, article geektime.Article, outputs []articleOutput, download func() error) error {
+	for _, o := range outputs {
+		if err := j.begin(article.AID, article.Title, o.name, o.fullPath); err != nil {
+			return err
+		}
+	}
+	err := download()
+	if errors.Is(err, audio.ErrNoAudio) || errors.Is(err, video.ErrNoVideo) {
+		for _, o := range outputs {
+			if err := j.unavailable(article.AID, article.Title, o.name, o.fullPath); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err != nil {
+		names := make([]string, len(outputs))
+		for i, o := range outputs {
+			names[i] = o.name
+		}
+		return &outputError{strings.Join(names, ","), err}
+	}
+	for _, o := range outputs {
+		if err := j.finish(article.AID, article.Title, o.name, o.fullPath); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// skipDownloadVideoArticle checks if video of article is downloaded in either format, so switching video format
+// doesn't download video again. MP4 which is wanted but missing is remuxed from TS downloaded before,
+// video is downloaded again only if there is no TS, remux fails or subtitles need to be embedded.
 func (d *CourseDownloader) skipDownloadVideoArticle(article geektime.Article, columnDir string, overwrite bool) bool {
-	dir := columnDir
-	fileName := filenamify.Filenamify(article.Title) + video.TSExtension
-	fullPath := filepath.Join(dir, fileName)
-	if files.CheckFileExists(fullPath) && !overwrite {
+	if overwrite {
+		return false
+	}
+	j, err := d.columnJournal(columnDir)
+	if err != nil {
+		return false
+	}
+	ts, mp4 := d.videoFiles(article, columnDir)
+	tsDone := j.completed(article.AID, article.Title, ts.name, ts.fullPath)
+	mp4Done := j.completed(article.AID, article.Title, mp4.name, mp4.fullPath)
+	if !tsDone && !mp4Done {
+		return false
+	}
+	if d.cfg.VideoFormat == video.FormatTS || mp4Done {
 		return true
 	}
-	return false
+	if !tsDone || d.cfg.EmbedSubtitles {
+		return false
+	}
+	err = d.downloadOutput(j, article, mp4, true, func() error {
+		return video.RemuxTS(ts.fullPath, mp4.fullPath)
+	})
+	if err != nil {
+		logger.Warnf("Failed to remux downloaded ts video, download it again, articleID: %d, title: %s, err: %v", article.AID, article.Title, err)
+		return false
+	}
+	if definition := j.definition(article.AID, ts.name); definition != "" {
+		if err := j.setDefinition(article.AID, mp4.name, definition); err != nil {
+			logger.Warnf("Failed to record video definition, articleID: %d, err: %v", article.AID, err)
+		}
+	}
+	return true
+}
+
+// videoFiles returns TS and MP4 video file of video article, named by article title like video package does
+func (d *CourseDownloader) videoFiles(article geektime.Article, columnDir string) (ts, mp4 articleOutput) {
+	dir := columnDir
+	if article.SectionTitle != "" {
+		dir = filepath.Join(columnDir, filenamify.Filenamify(article.SectionTitle))
+	}
+	fileName := filepath.Join(dir, filenamify.Filenamify(article.Title))
+	return articleOutput{journalOutputVideo, fileName + video.TSExtension},
+		articleOutput{journalOutputVideoMP4, fileName + video.MP4Extension}
+}
+
+// videoOutputs returns final video files of video article selected by VideoFormat
+func (d *CourseDownloader) videoOutputs(article geektime.Article, columnDir string) []articleOutput {
+	ts, mp4 := d.videoFiles(article, columnDir)
+	switch d.cfg.VideoFormat {
+	case video.FormatMP4:
+		return []articleOutput{mp4}
+	case video.FormatBoth:
+		return []articleOutput{ts, mp4}
+	default:
+		return []articleOutput{ts}
+	}
 }
 
 // downloadVideoArticle downloads a video article to the specified column directory.
@@ -264,14 +891,56 @@ func (d *CourseDownloader) downloadVideoArticle(course geektime.Course, productT
 		}
 	}
 
-	if productType.IsUniversity() {
-		err = video.DownloadUniversityVideo(d.ctx, d.geektimeClient, article.AID, course, dir, d.cfg.Quality, d.concurrency)
-	} else if d.cfg.IsEnterprise {
-		err = video.DownloadEnterpriseArticleVideo(d.ctx, d.geektimeClient, article.AID, dir, d.cfg.Quality, d.concurrency)
-	} else {
-		err = video.DownloadArticleVideo(d.ctx, d.geektimeClient, article.AID, productType.SourceType, dir, d.cfg.Quality, d.concurrency)
+	j, err := d.columnJournal(columnDir)
+	if err != nil {
+		return err
 	}
-	return err
+	outputs := d.videoOutputs(article, columnDir)
+	var definition string
+	// skip check is done by caller, always download here
+	err = d.downloadOutputs(j, article, outputs, func() (err error) {
+		if productType.IsUniversity() {
+			definition, err = video.DownloadUniversityVideo(d.ctx, d.geektimeClient, article.AID, course, article.Title, dir, d.videoOptions())
+		} else if d.cfg.IsEnterprise {
+			definition, err = video.DownloadEnterpriseArticleVideo(d.ctx, d.geektimeClient, article.AID, article.Title, dir, d.videoOptions())
+		} else {
+			definition, err = video.DownloadArticleVideo(d.ctx, d.geektimeClient, article.AID, productType.SourceType, article.Title, dir, d.videoOptions())
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return setVideoDefinition(j, article, outputs, definition)
+}
+
+// setVideoDefinition records the definition actually downloaded of video outputs, empty definition means no video
+func setVideoDefinition(j *journal, article geektime.Article, outputs []articleOutput, definition string) error {
+	if definition == "" {
+		return nil
+	}
+	for _, o := range outputs {
+		if err := j.setDefinition(article.AID, o.name, definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columnJournal returns download state journal of column dir
+func (d *CourseDownloader) columnJournal(columnDir string) (*journal, error) {
+	d.journalsMu.Lock()
+	defer d.journalsMu.Unlock()
+
+	if j, ok := d.journals[columnDir]; ok {
+		return j, nil
+	}
+	j, err := openJournal(columnDir)
+	if err != nil {
+		return nil, err
+	}
+	d.journals[columnDir] = j
+	return j, nil
 }
 
 // mkDownloadColumnDir creates a directory for downloading a column with the given columnName.
@@ -331,8 +1000,13 @@ func getVideoURLFromArticleContent(content string) (hasVideo bool, videoURL stri
 	return hasVideo, videoURL
 }
 
-// waitRandomTime wait interval seconds of time plus a 2000ms max jitter
-func (d *CourseDownloader) waitRandomTime() {
+// waitRandomTime wait interval seconds of time plus a 2000ms max jitter, it returns early if ctx is canceled
+func (d *CourseDownloader) waitRandomTime(ctx context.Context) error {
 	randomMillis := d.cfg.Interval*1000 + d.waitRand.Intn(2000)
-	time.Sleep(time.Duration(randomMillis) * time.Millisecond)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Duration(randomMillis) * time.Millisecond):
+		return nil
+	}
 }
@@ -0,0 +1,61 @@
+package course
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/nicoxiang/geektime-downloader/internal/config"
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+	"github.com/nicoxiang/geektime-downloader/internal/video"
+)
+
+func TestSkipDownloadVideoArticle_EitherFormat(t *testing.T) {
+	article := geektime.Article{AID: 1, Title: "a/1"}
+	tests := []struct {
+		name       string
+		format     string
+		downloaded []string
+		skip       bool
+	}{
+		{"nothing downloaded", video.FormatTS, nil, false},
+		{"ts wanted, mp4 downloaded", video.FormatTS, []string{journalOutputVideoMP4}, true},
+		{"mp4 wanted, mp4 downloaded", video.FormatMP4, []string{journalOutputVideoMP4}, true},
+		{"both wanted, mp4 downloaded", video.FormatBoth, []string{journalOutputVideoMP4}, true},
+		// ts is not a valid video, so remux fails and video is downloaded again
+		{"mp4 wanted, broken ts downloaded", video.FormatMP4, []string{journalOutputVideo}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columnDir := t.TempDir()
+			d := NewCourseDownloader(context.Background(), &config.AppConfig{VideoFormat: tt.format}, nil, nil)
+			j, err := d.columnJournal(columnDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ts, mp4 := d.videoFiles(article, columnDir)
+			for _, o := range []articleOutput{ts, mp4} {
+				for _, name := range tt.downloaded {
+					if o.name != name {
+						continue
+					}
+					if err := os.WriteFile(o.fullPath, []byte("video"), 0o644); err != nil {
+						t.Fatal(err)
+					}
+					if err := j.finish(article.AID, article.Title, o.name, o.fullPath); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			if got := d.skipDownloadVideoArticle(article, columnDir, false); got != tt.skip {
+				t.Fatalf("skip = %v, want %v", got, tt.skip)
+			}
+			if !tt.skip && tt.format == video.FormatMP4 {
+				if _, err := os.Stat(mp4.fullPath); !os.IsNotExist(err) {
+					t.Errorf("mp4 of failed remux is kept, err: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package course
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/logger"
+)
+
+// FailuresFileName is the name of failure report written to column dir in keep going mode
+const FailuresFileName = "failures.json"
+
+// failureOutputArticle is the output of failure which happens before any output is downloaded,
+// e.g. loading article info
+const failureOutputArticle = "article"
+
+// failure categories in failure report
+const (
+	failureNotPurchased = "not_purchased"
+	failureOffline      = "offline"
+	failureServer       = "server"
+	failureAPI          = "api"
+	failureNetwork      = "network"
+	failureTimeout      = "timeout"
+	failureOther        = "other"
+)
+
+// ErrPartialFailure is returned by DownloadAll in keep going mode when some articles still fail after retry
+var ErrPartialFailure = errors.New("部分文章下载失败")
+
+// outputError is error of downloading an output of article
+type outputError struct {
+	output string
+	err    error
+}
+
+func (e *outputError) Error() string {
+	return e.err.Error()
+}
+
+func (e *outputError) Unwrap() error {
+	return e.err
+}
+
+// failure is one failed item in failure report
+type failure struct {
+	ArticleID int    `json:"article_id"`
+	Title     string `json:"title"`
+	Output    string `json:"output"`
+	Category  string `json:"category"`
+	Error     string `json:"error"`
+}
+
+// failureCollector records failed articles of DownloadAll in keep going mode,
+// it records nothing if keep going mode is off, so the first error stops the download.
+type failureCollector struct {
+	enabled bool
+	// order is IDs of failed articles in the order they first failed
+	order    []int
+	articles map[int]*failedArticle
+}
+
+// failedArticle is a failed article and its failures, one failure per output
+type failedArticle struct {
+	article  geektime.Article
+	failures []failure
+}
+
+// add records err of article and returns true if the download can go on.
+// Errors which fail every article, like interrupt, login expired and rate limit, are not recorded.
+// Each failed output in err is recorded as a failure of its own, output already failed is not recorded again.
+func (c *failureCollector) add(article geektime.Article, err error) bool {
+	if !c.enabled || stopsDownload(err) {
+		return false
+	}
+	if c.articles == nil {
+		c.articles = make(map[int]*failedArticle)
+	}
+	fa, ok := c.articles[article.AID]
+	if !ok {
+		fa = &failedArticle{article: article}
+		c.articles[article.AID] = fa
+		c.order = append(c.order, article.AID)
+	}
+	for _, oe := range outputErrors(err) {
+		logger.Errorf(oe.err, "Failed to download, keep going, articleID: %d, title: %s, output: %s", article.AID, article.Title, oe.output)
+		if fa.failed(oe.output) {
+			continue
+		}
+		fmt.Printf("\r《%s》 下载失败: %v\n", article.Title, oe.err)
+		fa.failures = append(fa.failures, failure{
+			ArticleID: article.AID,
+			Title:     article.Title,
+			Output:    oe.output,
+			Category:  failureCategory(oe.err),
+			Error:     oe.err.Error(),
+		})
+	}
+	return true
+}
+
+// failed checks if output of article is already recorded
+func (fa *failedArticle) failed(output string) bool {
+	for _, f := range fa.failures {
+		if f.Output == output {
+			return true
+		}
+	}
+	return false
+}
+
+// failures returns all failures recorded, in the order articles failed
+func (c *failureCollector) failures() []failure {
+	var failures []failure
+	for _, aid := range c.order {
+		failures = append(failures, c.articles[aid].failures...)
+	}
+	return failures
+}
+
+// runColumnOutputs makes outputs of whole column, outputs failed are made once more after the others,
+// and the ones still failing are recorded as failures of column.
+func (c *failureCollector) runColumnOutputs(column geektime.Article, outputs []func() error) error {
+	var failed []func() error
+	for _, output := range outputs {
+		err := output()
+		if err == nil {
+			continue
+		}
+		if !c.enabled || stopsDownload(err) {
+			return err
+		}
+		logger.Warnf("Failed to make column output, retry later, title: %s, err: %v", column.Title, err)
+		failed = append(failed, output)
+	}
+	if len(failed) > 0 {
+		fmt.Printf("\r正在重试生成失败的 %d 项专栏输出\n", len(failed))
+	}
+	for _, output := range failed {
+		if err := output(); err != nil && !c.add(column, err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// retry downloads failed articles once more, articles failed again are recorded again
+func (c *failureCollector) retry(download func(geektime.Article) error) error {
+	order, failed := c.order, c.articles
+	c.order, c.articles = nil, nil
+	if len(order) > 0 {
+		fmt.Printf("\r正在重试下载失败的 %d 篇文章\n", len(order))
+	}
+	for _, aid := range order {
+		article := failed[aid].article
+		if err := download(article); err != nil && !skipNotPurchasedArticle(article, err) && !c.add(article, err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// abort writes failures recorded so far before returning err which stops the download
+func (c *failureCollector) abort(columnDir string, err error) error {
+	if failures := c.failures(); c.enabled && len(failures) > 0 {
+		if werr := writeFailures(columnDir, failures); werr != nil {
+			logger.Errorf(werr, "Failed to write failure report, columnDir: %s", columnDir)
+		}
+	}
+	return err
+}
+
+// report writes failure report to column dir and returns ErrPartialFailure if there is any failure,
+// report of last download is removed if all succeed.
+func (c *failureCollector) report(columnDir string) error {
+	if !c.enabled {
+		return nil
+	}
+	fullPath := filepath.Join(columnDir, FailuresFileName)
+	failures := c.failures()
+	if len(failures) == 0 {
+		if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := writeFailures(columnDir, failures); err != nil {
+		return err
+	}
+	fmt.Printf("\r有 %d 项下载失败, 详见 %s\n", len(failures), fullPath)
+	return fmt.Errorf("%w: %d 项, 详见 %s", ErrPartialFailure, len(failures), fullPath)
+}
+
+func writeFailures(columnDir string, failures []failure) error {
+	data, err := json.MarshalIndent(failures, "", "  ")
+	if err != nil {
+		return err
+	}
+	fullPath := filepath.Join(columnDir, FailuresFileName)
+	tmp := fullPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fullPath)
+}
+
+// stopsDownload checks if err fails every article, so the download stops even in keep going mode
+func stopsDownload(err error) bool {
+	return errors.Is(err, context.Canceled) || geektime.IsAuth(err) || geektime.IsRateLimit(err)
+}
+
+// outputErrors splits err into errors of each failed output, err of no output is of failureOutputArticle
+func outputErrors(err error) []*outputError {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var errs []*outputError
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, outputErrors(e)...)
+		}
+		return errs
+	}
+	var oe *outputError
+	if errors.As(err, &oe) {
+		return []*outputError{oe}
+	}
+	return []*outputError{{failureOutputArticle, err}}
+}
+
+// failureCategory classifies err for failure report
+func failureCategory(err error) string {
+	var apiErr *geektime.APIError
+	var netErr net.Error
+	switch {
+	case geektime.IsNotPurchased(err):
+		return failureNotPurchased
+	case geektime.IsArticleOffline(err):
+		return failureOffline
+	case errors.Is(err, context.DeadlineExceeded), os.IsTimeout(err):
+		return failureTimeout
+	case geektime.IsRetryable(err):
+		return failureServer
+	case errors.As(err, &apiErr):
+		return failureAPI
+	case errors.As(err, &netErr):
+		return failureNetwork
+	default:
+		return failureOther
+	}
+}
@@ -0,0 +1,135 @@
+package course
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+)
+
+func TestFailureCollector(t *testing.T) {
+	dir := t.TempDir()
+	c := &failureCollector{enabled: true}
+	a1 := geektime.Article{AID: 1, Title: "a1"}
+	a2 := geektime.Article{AID: 2, Title: "a2"}
+
+	if c.add(a1, geektime.ErrAuthFailed) {
+		t.Error("auth failure is recorded, want download stopped")
+	}
+	if !c.add(a1, &outputError{journalOutputPDF, errors.New("timeout")}) || !c.add(a2, errors.New("boom")) {
+		t.Fatal("failure is not recorded")
+	}
+
+	attempts := make(map[int]int)
+	err := c.retry(func(article geektime.Article) error {
+		attempts[article.AID]++
+		if article.AID == 1 {
+			return nil
+		}
+		return &outputError{journalOutputMarkdown, errors.New("boom")}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts[1] != 1 || attempts[2] != 1 {
+		t.Errorf("attempts = %v, want each failed article retried once", attempts)
+	}
+
+	if err := c.report(dir); !errors.Is(err, ErrPartialFailure) {
+		t.Fatalf("report = %v, want ErrPartialFailure", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, FailuresFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var failures []failure
+	if err := json.Unmarshal(data, &failures); err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 1 || failures[0].ArticleID != 2 || failures[0].Output != journalOutputMarkdown || failures[0].Category != failureOther {
+		t.Errorf("failures = %+v", failures)
+	}
+
+	if err := (&failureCollector{enabled: true}).report(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, FailuresFileName)); !os.IsNotExist(err) {
+		t.Error("failure report is not removed after all succeed")
+	}
+}
+
+func TestFailureCollector_EachFailedOutput(t *testing.T) {
+	c := &failureCollector{enabled: true}
+	article := geektime.Article{AID: 1, Title: "a1"}
+	err := errors.Join(
+		&outputError{journalOutputPDF, errors.New("boom")},
+		&outputError{journalOutputAudio, &geektime.APIError{StatusCode: 502}},
+	)
+	if !c.add(article, err) {
+		t.Fatal("failure is not recorded")
+	}
+	failures := c.failures()
+	if len(c.order) != 1 || len(failures) != 2 ||
+		failures[0].Output != journalOutputPDF || failures[1].Output != journalOutputAudio ||
+		failures[1].Category != failureServer {
+		t.Errorf("articles = %v, failures = %+v", c.order, failures)
+	}
+}
+
+func TestFailureCollector_ArticleFailedTwice(t *testing.T) {
+	c := &failureCollector{enabled: true}
+	a1 := geektime.Article{AID: 1, Title: "a1"}
+	a2 := geektime.Article{AID: 2, Title: "a2"}
+	c.add(a1, &outputError{journalOutputPDF, errors.New("boom")})
+	c.add(a2, errors.New("boom"))
+	c.add(a1, errors.Join(
+		&outputError{journalOutputPDF, errors.New("boom again")},
+		&outputError{journalOutputMarkdown, errors.New("boom")},
+	))
+
+	failures := c.failures()
+	if len(c.order) != 2 || c.order[0] != 1 || c.order[1] != 2 || len(failures) != 3 ||
+		failures[0].Output != journalOutputPDF || failures[1].Output != journalOutputMarkdown || failures[2].ArticleID != 2 {
+		t.Errorf("articles = %v, failures = %+v", c.order, failures)
+	}
+}
+
+func TestFailureCollector_RunColumnOutputs(t *testing.T) {
+	c := &failureCollector{enabled: true}
+	column := geektime.Article{AID: columnJournalID, Title: "column"}
+	attempts := make([]int, 3)
+	outputs := []func() error{
+		func() error {
+			attempts[0]++
+			return nil
+		},
+		func() error {
+			attempts[1]++
+			if attempts[1] == 1 {
+				return &outputError{journalOutputPDFBook, errors.New("timeout")}
+			}
+			return nil
+		},
+		func() error {
+			attempts[2]++
+			return &outputError{journalOutputEPUB, errors.New("boom")}
+		},
+	}
+	if err := c.runColumnOutputs(column, outputs); err != nil {
+		t.Fatal(err)
+	}
+	if attempts[0] != 1 || attempts[1] != 2 || attempts[2] != 2 {
+		t.Errorf("attempts = %v, want failed outputs made once more", attempts)
+	}
+	if failures := c.failures(); len(failures) != 1 || failures[0].Output != journalOutputEPUB || failures[0].ArticleID != columnJournalID {
+		t.Errorf("failures = %+v", failures)
+	}
+
+	stop := errors.New("stop")
+	if err := (&failureCollector{}).runColumnOutputs(column, []func() error{func() error { return stop }}); err != stop {
+		t.Errorf("runColumnOutputs without keep going = %v, want error returned", err)
+	}
+}
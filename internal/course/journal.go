@@ -0,0 +1,328 @@
+package course
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/files"
+)
+
+// JournalFileName is the download state journal file name in every column dir
+const JournalFileName = ".geektime-state.json"
+
+// output names recorded in journal
+const (
+	journalOutputPDF       = "pdf"
+	journalOutputMarkdown  = "markdown"
+	journalOutputAudio     = "audio"
+	journalOutputComments  = "comments"
+	journalOutputHTML      = "html"
+	journalOutputVideo     = "video"
+	journalOutputVideoMP4  = "video-mp4"
+	journalOutputEPUB      = "epub"
+	journalOutputChapter   = "epub-chapter"
+	journalOutputPDFBook   = "pdf-book"
+	journalOutputAudiobook = "audiobook"
+)
+
+// output status recorded in journal
+const (
+	// statusDownloading means download started but not finished, the file may be truncated
+	statusDownloading = "downloading"
+	// statusDone means the file is completely written
+	statusDone = "done"
+	// statusUnavailable means the article has no such output, e.g. article without audio
+	statusUnavailable = "unavailable"
+	// statusMerged means the file is merged into column PDF book and deleted, it's completed as long as the book is
+	statusMerged = "merged"
+)
+
+// outputState is the download state of one output file of an article
+type outputState struct {
+	// File is the output file path relative to column dir
+	File   string `json:"file"`
+	Status string `json:"status"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256,omitempty"`
+	// Definition is the video definition actually downloaded, only for video outputs
+	Definition string    `json:"definition,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// articleState is the download state of all outputs of an article
+type articleState struct {
+	Title   string                  `json:"title"`
+	Outputs map[string]*outputState `json:"outputs"`
+}
+
+// journal records download state of every article output in a column dir,
+// so a broken download can resume from where it stopped.
+type journal struct {
+	mu        sync.Mutex
+	columnDir string
+
+	// legacy is true if column dir was downloaded by old version without journal, existing files
+	// without record are checked and recorded in this case. It's not saved, since once journal is
+	// written files without record are never downloaded by old version.
+	legacy   bool
+	Articles map[int]*articleState `json:"articles"`
+}
+
+// openJournal reads journal in column dir, or creates an empty one
+func openJournal(columnDir string) (*journal, error) {
+	j := &journal{
+		columnDir: columnDir,
+		Articles:  make(map[int]*articleState),
+	}
+	data, err := os.ReadFile(filepath.Join(columnDir, JournalFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		j.legacy = hasDownloadedFiles(columnDir)
+		return j, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, j); err != nil {
+		return nil, err
+	}
+	if j.Articles == nil {
+		j.Articles = make(map[int]*articleState)
+	}
+	return j, nil
+}
+
+// hasDownloadedFiles checks if there is any file in column dir
+func hasDownloadedFiles(columnDir string) bool {
+	entries, err := os.ReadDir(columnDir)
+	return err == nil && len(entries) > 0
+}
+
+// completed checks if the output file of article is completely downloaded,
+// files which are recorded as done but missing or truncated are not completed.
+func (j *journal) completed(aid int, title, output, fullPath string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	state := j.output(aid, output)
+	if state == nil {
+		// files downloaded by old version are recorded if they are complete, so they are not checked again
+		return j.legacy && j.adopt(aid, title, output, fullPath)
+	}
+	switch state.Status {
+	case statusUnavailable:
+		return true
+	case statusDone:
+		info, err := os.Stat(fullPath)
+		if err != nil || info.Size() != state.Size || j.rel(fullPath) != state.File {
+			return false
+		}
+		// file modified after it's recorded may be rewritten with the same size, checksum tells if it's still the same
+		if info.ModTime().After(state.UpdatedAt) && state.SHA256 != "" {
+			_, sum, err := checksum(fullPath)
+			return err == nil && sum == state.SHA256
+		}
+		return true
+	case statusMerged:
+		book := j.output(columnJournalID, journalOutputPDFBook)
+		if book == nil || book.Status != statusDone {
+			return false
+		}
+		info, err := os.Stat(filepath.Join(j.columnDir, filepath.FromSlash(book.File)))
+		return err == nil && info.Size() == book.Size
+	default:
+		return false
+	}
+}
+
+// begin records the output file of article starts downloading
+func (j *journal) begin(aid int, title, output, fullPath string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.setOutput(aid, title, output, &outputState{
+		File:      j.rel(fullPath),
+		Status:    statusDownloading,
+		UpdatedAt: time.Now(),
+	})
+	return j.save()
+}
+
+// finish records the output file of article is completely downloaded, with its size and checksum.
+// It's an error if the file is not written.
+func (j *journal) finish(aid int, title, output, fullPath string) error {
+	size, sum, err := checksum(fullPath)
+	if err != nil {
+		return fmt.Errorf("output %s is not written: %w", output, err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.setOutput(aid, title, output, &outputState{
+		File:      j.rel(fullPath),
+		Status:    statusDone,
+		Size:      size,
+		SHA256:    sum,
+		UpdatedAt: time.Now(),
+	})
+	return j.save()
+}
+
+// unavailable records the article has no such output, e.g. article without audio
+func (j *journal) unavailable(aid int, title, output, fullPath string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.setOutput(aid, title, output, &outputState{
+		File:      j.rel(fullPath),
+		Status:    statusUnavailable,
+		UpdatedAt: time.Now(),
+	})
+	return j.save()
+}
+
+// setDefinition records the video definition actually downloaded of output
+func (j *journal) setDefinition(aid int, output, definition string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	state := j.output(aid, output)
+	if state == nil {
+		return nil
+	}
+	state.Definition = definition
+	return j.save()
+}
+
+// definition returns the video definition recorded of output, empty if there is none
+func (j *journal) definition(aid int, output string) string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if state := j.output(aid, output); state != nil {
+		return state.Definition
+	}
+	return ""
+}
+
+// merge records the output file of article is merged into column PDF book and deleted,
+// its update time is kept so it's not newer than the book.
+func (j *journal) merge(aid int, output string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	state := j.output(aid, output)
+	if state == nil {
+		return nil
+	}
+	state.Status = statusMerged
+	return j.save()
+}
+
+// unmerge forgets the output file of article which is merged and deleted, so it's downloaded again
+func (j *journal) unmerge(aid int, output string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	state := j.output(aid, output)
+	if state == nil || state.Status != statusMerged {
+		return nil
+	}
+	delete(j.Articles[aid].Outputs, output)
+	return j.save()
+}
+
+// updatedAt returns the time output file of article is recorded, zero if there is no record
+func (j *journal) updatedAt(aid int, output string) time.Time {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if state := j.output(aid, output); state != nil {
+		return state.UpdatedAt
+	}
+	return time.Time{}
+}
+
+// adopt records existing file which is downloaded by old version as done if it's complete
+func (j *journal) adopt(aid int, title, output, fullPath string) bool {
+	if !files.CheckFileExists(fullPath) || !validLegacyFile(output, fullPath) {
+		return false
+	}
+	size, sum, err := checksum(fullPath)
+	if err != nil {
+		return false
+	}
+	j.setOutput(aid, title, output, &outputState{
+		File:      j.rel(fullPath),
+		Status:    statusDone,
+		Size:      size,
+		SHA256:    sum,
+		UpdatedAt: time.Now(),
+	})
+	return j.save() == nil
+}
+
+func (j *journal) output(aid int, output string) *outputState {
+	a, ok := j.Articles[aid]
+	if !ok {
+		return nil
+	}
+	return a.Outputs[output]
+}
+
+func (j *journal) setOutput(aid int, title, output string, state *outputState) {
+	a, ok := j.Articles[aid]
+	if !ok {
+		a = &articleState{Outputs: make(map[string]*outputState)}
+		j.Articles[aid] = a
+	}
+	a.Title = title
+	a.Outputs[output] = state
+}
+
+// save writes journal to temp file then rename, so journal is never half written
+func (j *journal) save() error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(j.columnDir, JournalFileName)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (j *journal) rel(fullPath string) string {
+	rel, err := filepath.Rel(j.columnDir, fullPath)
+	if err != nil {
+		return fullPath
+	}
+	return filepath.ToSlash(rel)
+}
+
+// checksum returns size and sha256 hex string of file
+func checksum(fullPath string) (int64, string, error) {
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return 0, "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
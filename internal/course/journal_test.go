@@ -0,0 +1,205 @@
+package course
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournal_TruncatedFileNotCompleted(t *testing.T) {
+	dir := t.TempDir()
+	fullPath := filepath.Join(dir, "a.md")
+
+	j, err := openJournal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.begin(1, "a", journalOutputMarkdown, fullPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fullPath, []byte("# a\ncontent"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if j.completed(1, "a", journalOutputMarkdown, fullPath) {
+		t.Fatal("want not completed before finish")
+	}
+	if err := j.finish(1, "a", journalOutputMarkdown, fullPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// reopen to make sure state is persisted
+	j, err = openJournal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !j.completed(1, "a", journalOutputMarkdown, fullPath) {
+		t.Fatal("want completed after finish")
+	}
+
+	if err := os.WriteFile(fullPath, []byte("# a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if j.completed(1, "a", journalOutputMarkdown, fullPath) {
+		t.Fatal("want truncated file not completed")
+	}
+}
+
+func TestJournal_LegacyFilesAdopted(t *testing.T) {
+	dir := t.TempDir()
+	fullPath := filepath.Join(dir, "a.pdf")
+	if err := os.WriteFile(fullPath, []byte("%PDF-1.4\n%%EOF\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	truncated := filepath.Join(dir, "b.pdf")
+	if err := os.WriteFile(truncated, []byte("%PDF-1.4\n1 0 obj"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := openJournal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !j.completed(1, "a", journalOutputPDF, fullPath) {
+		t.Fatal("want file downloaded by old version completed")
+	}
+	if j.Articles[1].Title != "a" {
+		t.Errorf("title of adopted file = %q", j.Articles[1].Title)
+	}
+	if j.completed(2, "b", journalOutputPDF, truncated) {
+		t.Fatal("want truncated file downloaded by old version not completed")
+	}
+	if j.completed(3, "c", journalOutputPDF, filepath.Join(dir, "c.pdf")) {
+		t.Fatal("want missing file not completed")
+	}
+
+	// files are trusted only before journal is written
+	j, err = openJournal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.legacy || j.completed(2, "b", journalOutputPDF, truncated) {
+		t.Error("want files without record not trusted once journal is written")
+	}
+}
+
+func TestValidMP3(t *testing.T) {
+	// MPEG1 layer III, 128kbps, 44100Hz, no padding: 417 bytes per frame
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xff, 0xfb, 0x90, 0x00})
+	data := append(append([]byte{}, frame...), frame...)
+
+	dir := t.TempDir()
+	fullPath := filepath.Join(dir, "a.mp3")
+	if err := os.WriteFile(fullPath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !validMP3(fullPath) {
+		t.Error("want whole frames valid")
+	}
+	if err := os.WriteFile(fullPath, data[:600], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if validMP3(fullPath) {
+		t.Error("want truncated frame invalid")
+	}
+}
+
+func TestJournal_MergedCompletedWithBook(t *testing.T) {
+	dir := t.TempDir()
+	article := filepath.Join(dir, "a.pdf")
+	book := filepath.Join(dir, "book.pdf")
+	for _, p := range []string{article, book} {
+		if err := os.WriteFile(p, []byte("pdf"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	j, err := openJournal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.finish(1, "a", journalOutputPDF, article); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.finish(columnJournalID, "book", journalOutputPDFBook, book); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(article); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.merge(1, journalOutputPDF); err != nil {
+		t.Fatal(err)
+	}
+	if !j.completed(1, "a", journalOutputPDF, article) {
+		t.Fatal("want merged article completed while book exists")
+	}
+
+	if err := os.Remove(book); err != nil {
+		t.Fatal(err)
+	}
+	if j.completed(1, "a", journalOutputPDF, article) {
+		t.Fatal("want merged article not completed without book")
+	}
+	if err := j.unmerge(1, journalOutputPDF); err != nil {
+		t.Fatal(err)
+	}
+	if j.output(1, journalOutputPDF) != nil {
+		t.Fatal("want merged article forgotten after unmerge")
+	}
+}
+
+func TestJournal_MissingFileNotUnavailable(t *testing.T) {
+	dir := t.TempDir()
+	fullPath := filepath.Join(dir, "a.mp3")
+
+	j, err := openJournal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.finish(1, "a", journalOutputAudio, fullPath); err == nil {
+		t.Fatal("want error when output file is not written")
+	}
+	if j.completed(1, "a", journalOutputAudio, fullPath) {
+		t.Fatal("want output without file not completed")
+	}
+	if err := j.unavailable(1, "a", journalOutputAudio, fullPath); err != nil {
+		t.Fatal(err)
+	}
+	if !j.completed(1, "a", journalOutputAudio, fullPath) {
+		t.Fatal("want unavailable output completed")
+	}
+}
+
+func TestJournal_RewrittenFileVerifiedByChecksum(t *testing.T) {
+	dir := t.TempDir()
+	fullPath := filepath.Join(dir, "a.md")
+	if err := os.WriteFile(fullPath, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := openJournal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.finish(1, "a", journalOutputMarkdown, fullPath); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(fullPath, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if !j.completed(1, "a", journalOutputMarkdown, fullPath) {
+		t.Fatal("want touched file with same content completed")
+	}
+
+	if err := os.WriteFile(fullPath, []byte("CONTENT"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(fullPath, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if j.completed(1, "a", journalOutputMarkdown, fullPath) {
+		t.Fatal("want rewritten file with same size not completed")
+	}
+}
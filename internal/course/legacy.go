@@ -0,0 +1,144 @@
+package course
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nicoxiang/geektime-downloader/internal/video"
+)
+
+// tsPacketLength is the length of TS packet, merged TS video is made of whole packets
+const tsPacketLength = 188
+
+// validLegacyFile checks if output file downloaded by old version without journal is complete,
+// old version left truncated files when it's interrupted, they are downloaded again.
+// Only outputs old version wrote are checked, others are never trusted.
+func validLegacyFile(output, fullPath string) bool {
+	switch output {
+	case journalOutputPDF:
+		return validPDF(fullPath)
+	case journalOutputMarkdown:
+		info, err := os.Stat(fullPath)
+		return err == nil && info.Size() > 0
+	case journalOutputAudio:
+		return validMP3(fullPath)
+	case journalOutputVideo:
+		return validTS(fullPath)
+	default:
+		return false
+	}
+}
+
+// validPDF checks PDF file has its header and end of file marker
+func validPDF(fullPath string) bool {
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(f, header); err != nil || string(header) != "%PDF-" {
+		return false
+	}
+	tail := make([]byte, min(info.Size(), 1024))
+	if _, err := f.ReadAt(tail, info.Size()-int64(len(tail))); err != nil {
+		return false
+	}
+	return bytes.Contains(tail, []byte("%%EOF"))
+}
+
+// validTS checks TS video is made of whole packets, and the temp folder of segments old version
+// removed after merging is not left, or merging is interrupted
+func validTS(fullPath string) bool {
+	info, err := os.Stat(fullPath)
+	if err != nil || info.Size() == 0 || info.Size()%tsPacketLength != 0 {
+		return false
+	}
+	if _, err := os.Stat(strings.TrimSuffix(fullPath, video.TSExtension)); err == nil {
+		return false
+	}
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	b := make([]byte, 1)
+	for _, off := range []int64{0, info.Size() - tsPacketLength} {
+		if _, err := f.ReadAt(b, off); err != nil || b[0] != 0x47 {
+			return false
+		}
+	}
+	return true
+}
+
+// bitrates of MPEG audio layer III in kbps, by bitrate index
+var (
+	mpeg1Bitrates = [15]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320}
+	mpeg2Bitrates = [15]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160}
+	mpeg1Rates    = [3]int{44100, 48000, 32000}
+)
+
+// validMP3 checks MP3 file is made of whole MPEG audio frames, truncated file ends in the middle of a frame
+func validMP3(fullPath string) bool {
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		return false
+	}
+	pos, end := 0, len(data)
+	// skip ID3v2 tag at the beginning
+	if end >= 10 && string(data[:3]) == "ID3" {
+		pos = 10 + int(data[6])<<21 | int(data[7])<<14 | int(data[8])<<7 | int(data[9])
+		if data[5]&0x10 != 0 {
+			pos += 10
+		}
+	}
+	// skip ID3v1 tag at the end
+	if end-pos >= 128 && string(data[end-128:end-125]) == "TAG" {
+		end -= 128
+	}
+	frames := 0
+	for pos < end {
+		if end-pos < 4 {
+			return false
+		}
+		n := mp3FrameLength(data[pos : pos+4])
+		if n <= 0 {
+			return false
+		}
+		pos += n
+		frames++
+	}
+	return frames > 0 && pos == end
+}
+
+// mp3FrameLength returns length of MPEG audio layer III frame of header, 0 if header is invalid
+func mp3FrameLength(h []byte) int {
+	if h[0] != 0xff || h[1]&0xe0 != 0xe0 || (h[1]>>1)&0x3 != 1 {
+		return 0
+	}
+	version := (h[1] >> 3) & 0x3
+	bitrateIndex, rateIndex := int(h[2]>>4), int((h[2]>>2)&0x3)
+	if version == 1 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return 0
+	}
+	padding := int((h[2] >> 1) & 0x1)
+	rate := mpeg1Rates[rateIndex]
+	switch version {
+	case 3:
+		return 144*mpeg1Bitrates[bitrateIndex]*1000/rate + padding
+	case 2:
+		return 72*mpeg2Bitrates[bitrateIndex]*1000/(rate/2) + padding
+	default:
+		return 72*mpeg2Bitrates[bitrateIndex]*1000/(rate/4) + padding
+	}
+}
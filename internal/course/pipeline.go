@@ -0,0 +1,215 @@
+package course
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+	"github.com/nicoxiang/geektime-downloader/internal/pdf"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/logger"
+)
+
+// textStage is a stage of text article pipeline, each output of article is downloaded by its stage
+type textStage int
+
+const (
+	// stagePDF prints article page or renders article content to PDF
+	stagePDF textStage = iota
+	// stageText writes markdown with images, html page and comments
+	stageText
+	// stageMedia downloads audio and videos in article content
+	stageMedia
+
+	stageCount
+)
+
+func stageOf(output string) textStage {
+	switch output {
+	case journalOutputPDF:
+		return stagePDF
+	case journalOutputAudio:
+		return stageMedia
+	default:
+		return stageText
+	}
+}
+
+// textJob is a text article going through pipeline, it's done when all its stages are done
+type textJob struct {
+	article geektime.Article
+	ta      *textArticle
+	outputs [stageCount][]articleOutput
+	videos  bool
+
+	pending int32
+	mu      sync.Mutex
+	err     error
+}
+
+// finish marks one stage of job done, it returns true if it's the last one. Errors of all stages are kept.
+func (job *textJob) finish(err error) bool {
+	if err != nil {
+		job.mu.Lock()
+		job.err = errors.Join(job.err, err)
+		job.mu.Unlock()
+	}
+	return atomic.AddInt32(&job.pending, -1) == 0
+}
+
+// downloadTextArticles downloads articles of text column in a pipeline. Article info is fetched one by one
+// with interval like before, then PDF, text and media outputs of articles are downloaded by separate stages,
+// each stage has ArticleConcurrency workers, and Chrome PDFs are printed in a pool of tabs of one browser.
+// Requests of all stages share the global rate limiters of API, page and CDN.
+//
+// done is called in the calling goroutine once all outputs of an article are finished, so progress and failures
+// are reported in one place. The pipeline stops and returns the error if done returns error.
+func (d *CourseDownloader) downloadTextArticles(course geektime.Course,
+	articles []geektime.Article,
+	columnDir string,
+	done func(geektime.Article, error) error,
+) error {
+	j, err := d.columnJournal(columnDir)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(d.ctx)
+	defer cancel()
+
+	workers := d.cfg.ArticleConcurrency
+	if workers <= 0 {
+		workers = 1
+	}
+	var browser *pdf.Browser
+	if d.cfg.ColumnOutputType&outputPDF != 0 && d.cfg.PDFEngine != pdf.EngineNative {
+		browser = pdf.NewBrowser(ctx, workers)
+		defer browser.Close()
+	}
+	// column cover is downloaded once before audio tags of articles read it
+	var coverOnce sync.Once
+
+	var stages [stageCount]chan *textJob
+	for s := range stages {
+		stages[s] = make(chan *textJob, workers)
+	}
+	results := make(chan *textJob)
+	finish := func(job *textJob, err error) {
+		if job.finish(err) {
+			results <- job
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer func() {
+			for _, ch := range stages {
+				close(ch)
+			}
+		}()
+		for i, article := range articles {
+			if ctx.Err() != nil {
+				return
+			}
+			if i > 0 && d.waitRandomTime(ctx) != nil {
+				return
+			}
+			job := d.newTextJob(j, article, columnDir)
+			var queued []textStage
+			for s := range stages {
+				if len(job.outputs[s]) > 0 || textStage(s) == stageMedia && job.videos {
+					queued = append(queued, textStage(s))
+				}
+			}
+			if job.ta == nil || len(queued) == 0 {
+				job.pending = 1
+				// error of loading article info is already in job
+				finish(job, nil)
+				continue
+			}
+			job.pending = int32(len(queued))
+			for _, s := range queued {
+				stages[s] <- job
+			}
+		}
+	}()
+
+	for s := range stages {
+		stage := textStage(s)
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for job := range stages[stage] {
+					if ctx.Err() != nil {
+						finish(job, ctx.Err())
+						continue
+					}
+					if stage == stageMedia && len(job.outputs[stage]) > 0 {
+						coverOnce.Do(func() {
+							d.columnCover(course, columnDir)
+						})
+					}
+					finish(job, d.runTextStage(ctx, j, course, job, stage, columnDir, browser))
+				}
+			}()
+		}
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// results are drained after stop, so no worker blocks on sending
+	var stopErr error
+	for job := range results {
+		if stopErr != nil {
+			continue
+		}
+		if err := done(job.article, job.err); err != nil {
+			stopErr = err
+			cancel()
+		}
+	}
+	return stopErr
+}
+
+// newTextJob fetches article info and groups outputs not completed yet by stage, error is kept in job
+func (d *CourseDownloader) newTextJob(j *journal, article geektime.Article, columnDir string) *textJob {
+	job := &textJob{article: article}
+	logger.Infof("Begin download article, articleID: %d, articleTitle: %s", article.AID, article.Title)
+	ta, err := d.loadTextArticle(article)
+	if err != nil {
+		job.err = err
+		return job
+	}
+	job.ta = ta
+	job.videos = hasInlineVideos(ta)
+	for _, o := range d.articleOutputs(article, columnDir) {
+		if !j.completed(article.AID, article.Title, o.name, o.fullPath) {
+			s := stageOf(o.name)
+			job.outputs[s] = append(job.outputs[s], o)
+		}
+	}
+	return job
+}
+
+func (d *CourseDownloader) runTextStage(ctx context.Context,
+	j *journal,
+	course geektime.Course,
+	job *textJob,
+	stage textStage,
+	columnDir string,
+	browser *pdf.Browser,
+) error {
+	var videoErr error
+	if stage == stageMedia && job.videos {
+		if videoErr = d.downloadInlineVideos(ctx, job.ta, columnDir, false); stopsDownload(videoErr) {
+			return videoErr
+		}
+	}
+	return errors.Join(videoErr, d.downloadTextOutputs(ctx, j, course, job.ta, columnDir, job.outputs[stage], false, browser))
+}
@@ -0,0 +1,170 @@
+package course
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nicoxiang/geektime-downloader/internal/config"
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+	"github.com/nicoxiang/geektime-downloader/internal/markdown"
+)
+
+func TestDownloadTextArticles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID string `json:"id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		if req.ID == "2" {
+			_, _ = io.WriteString(w, `{"code":-1,"error":{"msg":"未购买","code":-5001},"data":[]}`)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"code":0,"data":{"article_title":"a%s","article_content":"<p>content %s</p>"}}`, req.ID, req.ID)
+	}))
+	defer server.Close()
+
+	cfg := &config.AppConfig{ColumnOutputType: outputMD, ArticleConcurrency: 2}
+	client := geektime.NewClient(nil, geektime.WithBaseURL(server.URL))
+	d := NewCourseDownloader(context.Background(), cfg, client, nil)
+	articles := []geektime.Article{{AID: 1, Title: "a1"}, {AID: 2, Title: "a2"}, {AID: 3, Title: "a3"}}
+	course := geektime.Course{ID: 100, Title: "column", Articles: articles}
+	columnDir := t.TempDir()
+
+	errs := make(map[int]error)
+	err := d.downloadTextArticles(course, articles, columnDir, func(article geektime.Article, err error) error {
+		errs[article.AID] = err
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 3 || errs[1] != nil || errs[3] != nil || !geektime.IsNotPurchased(errs[2]) {
+		t.Fatalf("errs = %v, want only article 2 not purchased", errs)
+	}
+	j, err := d.columnJournal(columnDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, aid := range []int{1, 3} {
+		fullPath := filepath.Join(columnDir, fmt.Sprintf("a%d", aid)+markdown.MDExtension)
+		if _, err := os.Stat(fullPath); err != nil {
+			t.Error(err)
+		}
+		if !j.completed(aid, fmt.Sprintf("a%d", aid), journalOutputMarkdown, fullPath) {
+			t.Errorf("article %d markdown is not completed in journal", aid)
+		}
+	}
+}
+
+func TestDownloadTextArticles_FailedOutputNotStopOthers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"code":0,"data":{"article_title":"a1","article_content":"<p>content</p>"}}`)
+	}))
+	defer server.Close()
+
+	cfg := &config.AppConfig{ColumnOutputType: outputMD | outputHTML, ArticleConcurrency: 1}
+	client := geektime.NewClient(nil, geektime.WithBaseURL(server.URL))
+	d := NewCourseDownloader(context.Background(), cfg, client, nil)
+	article := geektime.Article{AID: 1, Title: "a1"}
+	course := geektime.Course{ID: 100, Title: "column", Articles: []geektime.Article{article}}
+	columnDir := t.TempDir()
+	// markdown can't be written where a directory is
+	if err := os.Mkdir(filepath.Join(columnDir, "a1"+markdown.MDExtension), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	var articleErr error
+	err := d.downloadTextArticles(course, course.Articles, columnDir, func(_ geektime.Article, err error) error {
+		articleErr = err
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := outputErrors(articleErr)
+	if len(failed) != 1 || failed[0].output != journalOutputMarkdown {
+		t.Fatalf("err = %v, want only markdown failed", articleErr)
+	}
+	j, err := d.columnJournal(columnDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range d.articleOutputs(article, columnDir) {
+		if o.name == journalOutputHTML && !j.completed(article.AID, article.Title, o.name, o.fullPath) {
+			t.Error("html is not written after markdown failed")
+		}
+	}
+}
+
+func TestDownloadColumnEPUB_ReusesPipelineChapters(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		var req struct {
+			ID string `json:"id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"code":0,"data":{"article_title":"a%s","article_content":"<p>content %s</p>"}}`, req.ID, req.ID)
+	}))
+	defer server.Close()
+
+	cfg := &config.AppConfig{ColumnOutputType: outputEPUB, ArticleConcurrency: 2}
+	client := geektime.NewClient(nil, geektime.WithBaseURL(server.URL))
+	d := NewCourseDownloader(context.Background(), cfg, client, nil)
+	course := geektime.Course{ID: 100, Title: "column", Articles: []geektime.Article{{AID: 1, Title: "a1"}, {AID: 2, Title: "a2"}}}
+	columnDir := t.TempDir()
+	download := func(articles []geektime.Article) {
+		t.Helper()
+		err := d.downloadTextArticles(course, articles, columnDir, func(article geektime.Article, err error) error {
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	j, err := d.columnJournal(columnDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	download(course.Articles)
+	loaded := atomic.LoadInt32(&requests)
+	if err := d.downloadColumnEPUB(course, columnDir); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(&requests); got != loaded {
+		t.Fatalf("epub loads articles again, %d requests", got-loaded)
+	}
+	written := j.updatedAt(columnJournalID, journalOutputEPUB)
+	if written.IsZero() {
+		t.Fatal("epub is not written")
+	}
+
+	if err := d.downloadColumnEPUB(course, columnDir); err != nil {
+		t.Fatal(err)
+	}
+	if !j.updatedAt(columnJournalID, journalOutputEPUB).Equal(written) {
+		t.Fatal("epub is written again without any change")
+	}
+
+	added := geektime.Article{AID: 3, Title: "a3"}
+	course.Articles = append(course.Articles, added)
+	download([]geektime.Article{added})
+	if err := d.downloadColumnEPUB(course, columnDir); err != nil {
+		t.Fatal(err)
+	}
+	if !j.updatedAt(columnJournalID, journalOutputEPUB).After(written) {
+		t.Fatal("epub is not written again after article is added")
+	}
+}
@@ -0,0 +1,79 @@
+package course
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+	"github.com/nicoxiang/geektime-downloader/internal/geektime/response"
+	"github.com/nicoxiang/geektime-downloader/internal/ui"
+)
+
+var (
+	// ErrNotPurchased is returned when current account has no access to the product
+	ErrNotPurchased = errors.New("尚未购买该课程")
+	// ErrInvalidProductID is returned when product type returned by API not match the selected product type
+	ErrInvalidProductID = errors.New("输入的课程 ID 有误")
+)
+
+// LoadCourse loads course info and all articles of the product which need select article,
+// returns ErrNotPurchased or ErrInvalidProductID if the product can not be downloaded.
+func LoadCourse(client *geektime.Client, productType ui.ProductTypeSelectOption, productID int) (geektime.Course, error) {
+	var course geektime.Course
+	var err error
+	if productType.IsEnterpriseMode {
+		// TODO: check enterprise course type
+		course, err = client.EnterpriseCourseInfo(productID)
+	} else if productType.IsUniversity() {
+		// university don't need check product type
+		// if input invalid id, access mark is 0
+		course, err = client.UniversityClassInfo(productID)
+	} else {
+		course, err = client.CourseInfo(productID)
+		if err == nil && !ValidateProductCode(productType, course.Type) {
+			return course, ErrInvalidProductID
+		}
+	}
+	if err != nil {
+		return course, notPurchasedOf(err)
+	}
+	if !course.Access {
+		return course, ErrNotPurchased
+	}
+	return course, nil
+}
+
+// LoadSingleVideoProduct loads product info of daily lesson or qconplus,
+// input id means product id.
+func LoadSingleVideoProduct(client *geektime.Client, productType ui.ProductTypeSelectOption, productID int) (response.V3ProductInfoResponse, error) {
+	productInfo, err := client.ProductInfo(productID)
+	if err != nil {
+		return productInfo, notPurchasedOf(err)
+	}
+	if productInfo.Data.Info.Extra.Sub.AccessMask == 0 {
+		return productInfo, ErrNotPurchased
+	}
+	if !ValidateProductCode(productType, productInfo.Data.Info.Type) {
+		return productInfo, ErrInvalidProductID
+	}
+	return productInfo, nil
+}
+
+// notPurchasedOf returns ErrNotPurchased if API rejects the request by no access to the product
+func notPurchasedOf(err error) error {
+	if geektime.IsNotPurchased(err) {
+		return fmt.Errorf("%w: %s", ErrNotPurchased, err.Error())
+	}
+	return err
+}
+
+// ValidateProductCode checks if the product code field in the response body returned by the API
+// exists in the selected product's accepted product types list.
+func ValidateProductCode(productType ui.ProductTypeSelectOption, productCode string) bool {
+	for _, pt := range productType.AcceptProductTypes {
+		if pt == productCode {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package epub
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/nicoxiang/geektime-downloader/internal/markdown"
+)
+
+// NewChapter converts article content html into a chapter, images are downloaded into
+// images/aid of columnDir, the same folder used by markdown output. origin is the Origin header of image requests.
+func NewChapter(ctx context.Context, origin, title, section, content, columnDir string, aid int) (Chapter, error) {
+	nodes, err := html.ParseFragment(strings.NewReader(content), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return Chapter{}, err
+	}
+
+	var imageURLs []string
+	var images []*html.Node
+	for _, n := range nodes {
+		walk(n, func(n *html.Node) {
+			if n.DataAtom == atom.Img {
+				if src := attr(n, "src"); markdown.IsImageURL(src) && MediaType(imagePath(src)) != "" {
+					imageURLs = append(imageURLs, src)
+					images = append(images, n)
+				}
+			}
+		})
+	}
+
+	imagesFolder := filepath.Join(columnDir, "images", strconv.Itoa(aid))
+	localPaths, err := markdown.DownloadImages(ctx, origin, imageURLs, imagesFolder)
+	if err != nil {
+		return Chapter{}, err
+	}
+	chapter := Chapter{Title: title, Section: section}
+	seen := make(map[string]bool)
+	for i, n := range images {
+		localPath := localPaths[imageURLs[i]]
+		href := ImageHref(columnDir, localPath)
+		setAttr(n, "src", href)
+		if !seen[href] {
+			seen[href] = true
+			chapter.Images = append(chapter.Images, Image{Href: href, LocalPath: localPath})
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, n := range nodes {
+		// scripts are not allowed in reflowable content without scripted property
+		if n.DataAtom == atom.Script {
+			continue
+		}
+		if err := html.Render(&buf, n); err != nil {
+			return Chapter{}, err
+		}
+	}
+	chapter.Body = buf.String()
+	return chapter, nil
+}
+
+// walk visits n and all its descendants, script elements are removed
+func walk(n *html.Node, visit func(*html.Node)) {
+	visit(n)
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.DataAtom == atom.Script {
+			n.RemoveChild(c)
+		} else {
+			walk(c, visit)
+		}
+		c = next
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func setAttr(n *html.Node, key, val string) {
+	for i := range n.Attr {
+		if n.Attr[i].Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: val})
+}
+
+// imagePath strips query of image url
+func imagePath(imageURL string) string {
+	p, _, _ := strings.Cut(imageURL, "?")
+	return p
+}
@@ -0,0 +1,60 @@
+package epub
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ChapterDirName is the folder in column dir where chapters converted from articles are kept,
+// so book is written again from them without loading articles again.
+const ChapterDirName = ".epub"
+
+// ChapterPath returns the file path of chapter of article in column dir
+func ChapterPath(columnDir string, aid int) string {
+	return filepath.Join(columnDir, ChapterDirName, strconv.Itoa(aid)+".json")
+}
+
+// SaveChapter writes chapter of article into column dir, image paths are saved relative to column dir
+func SaveChapter(columnDir string, aid int, c Chapter) error {
+	images := make([]Image, len(c.Images))
+	for i, img := range c.Images {
+		images[i] = img
+		if rel, err := filepath.Rel(columnDir, img.LocalPath); err == nil {
+			images[i].LocalPath = filepath.ToSlash(rel)
+		}
+	}
+	c.Images = images
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	fullPath := ChapterPath(columnDir, aid)
+	if err := os.MkdirAll(filepath.Dir(fullPath), os.ModePerm); err != nil {
+		return err
+	}
+	tmp := fullPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fullPath)
+}
+
+// LoadChapter reads chapter of article saved in column dir, it returns error satisfies os.IsNotExist if there is none
+func LoadChapter(columnDir string, aid int) (Chapter, error) {
+	var c Chapter
+	data, err := os.ReadFile(ChapterPath(columnDir, aid))
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, err
+	}
+	for i, img := range c.Images {
+		if !filepath.IsAbs(img.LocalPath) {
+			c.Images[i].LocalPath = filepath.Join(columnDir, filepath.FromSlash(img.LocalPath))
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,350 @@
+// Package epub writes a whole text column into one EPUB 3 book
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// EPUBExtension ...
+const EPUBExtension = ".epub"
+
+// Book is an EPUB book, chapters are written in order
+type Book struct {
+	// ID is the unique identifier of book, like urn:geektime:column:100
+	ID       string
+	Title    string
+	Author   string
+	Language string
+	// Cover is optional
+	Cover    *Image
+	Chapters []Chapter
+}
+
+// Chapter is one article of column
+type Chapter struct {
+	Title string
+	// Section groups chapters in table of contents, empty if column has no sections
+	Section string
+	// Body is XHTML fragment inside body element, images refer to Image.Href
+	Body   string
+	Images []Image
+}
+
+// Image is a resource file embedded in book
+type Image struct {
+	// Href is path of image in book, relative to content document
+	Href string
+	// LocalPath is the downloaded image file
+	LocalPath string
+}
+
+// mediaTypes of supported images, EPUB readers may not show other types
+var mediaTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".svg":  "image/svg+xml",
+}
+
+// MediaType returns media type of image by its extension, empty if not supported
+func MediaType(name string) string {
+	return mediaTypes[strings.ToLower(path.Ext(name))]
+}
+
+// item is an entry in manifest of package document
+type item struct {
+	ID         string
+	Href       string
+	MediaType  string
+	Properties string
+}
+
+// navSection is a section in table of contents
+type navSection struct {
+	Title    string
+	Chapters []navChapter
+}
+
+type navChapter struct {
+	Title string
+	Href  string
+}
+
+// Write writes book into dst, the file is written to a temp file first and renamed at last
+func (b *Book) Write(dst string) (err error) {
+	if len(b.Chapters) == 0 {
+		return fmt.Errorf("book %s has no chapters", b.Title)
+	}
+	tmp := dst + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+		if err != nil {
+			_ = os.Remove(tmp)
+		}
+	}()
+
+	zw := zip.NewWriter(f)
+	if err = b.write(zw); err != nil {
+		return err
+	}
+	if err = zw.Close(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+func (b *Book) write(zw *zip.Writer) error {
+	// mimetype must be the first file and stored without compression
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "application/epub+zip"); err != nil {
+		return err
+	}
+	if err := writeFile(zw, "META-INF/container.xml", []byte(containerXML)); err != nil {
+		return err
+	}
+	if err := writeFile(zw, "OEBPS/style.css", []byte(styleCSS)); err != nil {
+		return err
+	}
+
+	var items, spine []item
+	var sections []navSection
+	written := make(map[string]bool)
+	addImage := func(img Image, properties string) error {
+		if written[img.Href] {
+			return nil
+		}
+		mediaType := MediaType(img.Href)
+		if mediaType == "" {
+			return fmt.Errorf("unsupported image %s", img.Href)
+		}
+		data, err := os.ReadFile(img.LocalPath)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(zw, "OEBPS/"+img.Href, data); err != nil {
+			return err
+		}
+		written[img.Href] = true
+		items = append(items, item{
+			ID:         fmt.Sprintf("img%d", len(written)),
+			Href:       img.Href,
+			MediaType:  mediaType,
+			Properties: properties,
+		})
+		return nil
+	}
+
+	if b.Cover != nil {
+		if err := addImage(*b.Cover, "cover-image"); err != nil {
+			return err
+		}
+		page := item{ID: "cover", Href: "cover.xhtml", MediaType: "application/xhtml+xml"}
+		body := fmt.Sprintf(`<div class="cover"><img src="%s" alt="%s"/></div>`, escape(b.Cover.Href), escape(b.Title))
+		if err := b.writeDocument(zw, page.Href, b.Title, body); err != nil {
+			return err
+		}
+		items = append(items, page)
+		spine = append(spine, page)
+	}
+
+	for i, c := range b.Chapters {
+		for _, img := range c.Images {
+			if err := addImage(img, ""); err != nil {
+				return err
+			}
+		}
+		page := item{
+			ID:        fmt.Sprintf("chapter%04d", i+1),
+			Href:      fmt.Sprintf("chapter-%04d.xhtml", i+1),
+			MediaType: "application/xhtml+xml",
+		}
+		body := fmt.Sprintf("<h1>%s</h1>\n%s", escape(c.Title), c.Body)
+		if err := b.writeDocument(zw, page.Href, c.Title, body); err != nil {
+			return err
+		}
+		items = append(items, page)
+		spine = append(spine, page)
+
+		if len(sections) == 0 || sections[len(sections)-1].Title != c.Section {
+			sections = append(sections, navSection{Title: c.Section})
+		}
+		s := &sections[len(sections)-1]
+		s.Chapters = append(s.Chapters, navChapter{Title: c.Title, Href: page.Href})
+	}
+
+	nav := item{ID: "nav", Href: "nav.xhtml", MediaType: "application/xhtml+xml", Properties: "nav"}
+	items = append(items, nav, item{ID: "css", Href: "style.css", MediaType: "text/css"})
+	if err := render(zw, "OEBPS/"+nav.Href, navTemplate, map[string]interface{}{
+		"Book":     b,
+		"Sections": sections,
+	}); err != nil {
+		return err
+	}
+
+	language := b.Language
+	if language == "" {
+		language = "zh-CN"
+	}
+	return render(zw, "OEBPS/content.opf", packageTemplate, map[string]interface{}{
+		"Book":     b,
+		"Language": language,
+		"Modified": time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		"Items":    items,
+		"Spine":    spine,
+	})
+}
+
+func (b *Book) writeDocument(zw *zip.Writer, href, title, body string) error {
+	return render(zw, "OEBPS/"+href, documentTemplate, map[string]interface{}{
+		"Language": b.Language,
+		"Title":    title,
+		"Body":     body,
+	})
+}
+
+func writeFile(zw *zip.Writer, name string, data []byte) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func render(zw *zip.Writer, name string, t *template.Template, data interface{}) error {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return err
+	}
+	return writeFile(zw, name, buf.Bytes())
+}
+
+func escape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// ImageHref returns href of image downloaded to localPath, the path relative to
+// column dir is kept so that image names of different articles never conflict
+func ImageHref(columnDir, localPath string) string {
+	rel, err := filepath.Rel(columnDir, localPath)
+	if err != nil {
+		rel = filepath.Base(localPath)
+	}
+	return filepath.ToSlash(rel)
+}
+
+const containerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+const styleCSS = `body { font-family: sans-serif; line-height: 1.6; }
+img { max-width: 100%; }
+pre { white-space: pre-wrap; word-wrap: break-word; font-size: 0.85em; }
+blockquote { margin-left: 1em; padding-left: 0.5em; border-left: 3px solid #ccc; color: #555; }
+.cover { text-align: center; }
+`
+
+var funcs = template.FuncMap{"escape": escape}
+
+var documentTemplate = template.Must(template.New("document").Funcs(funcs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"{{if .Language}} xml:lang="{{escape .Language}}"{{end}}>
+<head>
+<meta charset="UTF-8"/>
+<title>{{escape .Title}}</title>
+<link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+{{.Body}}
+</body>
+</html>
+`))
+
+var navTemplate = template.Must(template.New("nav").Funcs(funcs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head>
+<meta charset="UTF-8"/>
+<title>{{escape .Book.Title}}</title>
+</head>
+<body>
+<nav epub:type="toc" id="toc">
+<h1>目录</h1>
+<ol>
+{{- range .Sections}}
+{{- if .Title}}
+<li><a href="{{(index .Chapters 0).Href}}">{{escape .Title}}</a>
+<ol>
+{{- range .Chapters}}
+<li><a href="{{.Href}}">{{escape .Title}}</a></li>
+{{- end}}
+</ol>
+</li>
+{{- else}}
+{{- range .Chapters}}
+<li><a href="{{.Href}}">{{escape .Title}}</a></li>
+{{- end}}
+{{- end}}
+{{- end}}
+</ol>
+</nav>
+</body>
+</html>
+`))
+
+var packageTemplate = template.Must(template.New("package").Funcs(funcs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="book-id">{{escape .Book.ID}}</dc:identifier>
+<dc:title>{{escape .Book.Title}}</dc:title>
+{{- if .Book.Author}}
+<dc:creator>{{escape .Book.Author}}</dc:creator>
+{{- end}}
+<dc:language>{{escape .Language}}</dc:language>
+<meta property="dcterms:modified">{{.Modified}}</meta>
+{{- range .Items}}
+{{- if eq .Properties "cover-image"}}
+<meta name="cover" content="{{.ID}}"/>
+{{- end}}
+{{- end}}
+</metadata>
+<manifest>
+{{- range .Items}}
+<item id="{{.ID}}" href="{{escape .Href}}" media-type="{{.MediaType}}"{{if .Properties}} properties="{{.Properties}}"{{end}}/>
+{{- end}}
+</manifest>
+<spine>
+{{- range .Spine}}
+<itemref idref="{{.ID}}"/>
+{{- end}}
+</spine>
+</package>
+`))
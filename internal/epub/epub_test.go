@@ -0,0 +1,88 @@
+package epub
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+)
+
+func TestWriteBook(t *testing.T) {
+	dir := t.TempDir()
+	cover := filepath.Join(dir, "cover.png")
+	if err := os.WriteFile(cover, []byte("png"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	first, err := NewChapter(context.Background(), geektime.DefaultBaseURL, "开篇词", "", "<p>第一行<br>第二行 &amp; <b>粗体</b></p><script>alert(1)</script><img src=\"data:x\">", dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	book := Book{
+		ID:     "urn:geektime:column:1",
+		Title:  "专栏 <测试>",
+		Author: "作者",
+		Cover:  &Image{Href: ImageHref(dir, cover), LocalPath: cover},
+		Chapters: []Chapter{
+			first,
+			{Title: "第一讲", Section: "基础篇", Body: "<p>a</p>"},
+			{Title: "第二讲", Section: "基础篇", Body: "<p>b</p>"},
+		},
+	}
+	dst := filepath.Join(dir, "book"+EPUBExtension)
+	if err := book.Write(dst); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.OpenReader(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	if r.File[0].Name != "mimetype" || r.File[0].Method != zip.Store {
+		t.Fatalf("mimetype must be the first stored file, got %s", r.File[0].Name)
+	}
+
+	files := make(map[string]string)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		files[f.Name] = string(data)
+	}
+	for _, name := range []string{"META-INF/container.xml", "OEBPS/content.opf", "OEBPS/nav.xhtml", "OEBPS/cover.xhtml", "OEBPS/cover.png", "OEBPS/chapter-0003.xhtml"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("missing %s", name)
+		}
+	}
+	// every document must be well formed xml
+	for name, content := range files {
+		if !strings.HasSuffix(name, ".xhtml") && !strings.HasSuffix(name, ".opf") {
+			continue
+		}
+		d := xml.NewDecoder(strings.NewReader(content))
+		for {
+			if _, err := d.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s is not well formed: %v\n%s", name, err, content)
+			}
+		}
+	}
+	if strings.Contains(files["OEBPS/chapter-0001.xhtml"], "alert") {
+		t.Error("script is not removed")
+	}
+	if !strings.Contains(files["OEBPS/nav.xhtml"], "基础篇") {
+		t.Error("section is missing in nav")
+	}
+}
@@ -111,43 +111,11 @@ func (r *FSMRunner) handleInputProductIDIfNeedSelectArticle(productID int) error
 	r.sp.Start()
 	defer r.sp.Stop()
 
-	var course geektime.Course
-	var err error
-	if r.selectedProductType.IsEnterpriseMode {
-		// TODO: check enterprise course type
-		course, err = r.geektimeClient.EnterpriseCourseInfo(productID)
-		if err != nil {
-			return err
-		}
-
-	} else {
-		if r.selectedProductType.IsUniversity() {
-			// university don't need check product type
-			// if input invalid id, access mark is 0
-			course, err = r.geektimeClient.UniversityClassInfo(productID)
-			if err != nil {
-				return err
-			}
-		} else {
-			course, err = r.geektimeClient.CourseInfo(productID)
-			if err == nil {
-				valid := r.validateProductCode(course.Type)
-				// if check product type fail, re-input product
-				if !valid {
-					r.currentState = StateInputProductID
-					return nil
-				}
-			} else {
-				return err
-			}
-		}
-	}
-	if !course.Access {
-		fmt.Fprint(os.Stderr, "尚未购买该课程\n")
-		r.currentState = StateInputProductID
-		return nil
+	c, err := course.LoadCourse(r.geektimeClient, r.selectedProductType, productID)
+	if err != nil {
+		return r.handleLoadProductError(err)
 	}
-	r.selectedProduct = course
+	r.selectedProduct = c
 	r.currentState = StateProductAction
 	return nil
 }
@@ -156,39 +124,57 @@ func (r *FSMRunner) handleInputProductIDIfDownloadDirectly(productID int) error
 	// when product type is daily lesson or qconplus,
 	// input id means product id
 	// download video directly
-	productInfo, err := r.geektimeClient.ProductInfo(productID)
+	productInfo, err := course.LoadSingleVideoProduct(r.geektimeClient, r.selectedProductType, productID)
 	if err != nil {
-		return err
+		return r.handleLoadProductError(err)
 	}
 
-	if productInfo.Data.Info.Extra.Sub.AccessMask == 0 {
-		fmt.Fprint(os.Stderr, "尚未购买该课程\n")
-		r.currentState = StateInputProductID
-		return nil
+	err = r.courseDownloader.DownloadSingleVideoProduct(productInfo.Data.Info.Title,
+		productInfo.Data.Info.Article.ID,
+		r.selectedProductType.SourceType)
+	if err != nil {
+		return err
 	}
+	r.currentState = StateInputProductID
+	return nil
+}
 
-	if r.validateProductCode(productInfo.Data.Info.Type) {
-		err = r.courseDownloader.DownloadSingleVideoProduct(productInfo.Data.Info.Title,
-			productInfo.Data.Info.Article.ID,
-			r.selectedProductType.SourceType)
-		if err != nil {
-			return err
-		}
+// handleLoadProductError prints the reason and lets user re-input product id
+// if the product can not be downloaded, otherwise returns the error.
+func (r *FSMRunner) handleLoadProductError(err error) error {
+	switch {
+	case errors.Is(err, course.ErrNotPurchased):
+		fmt.Fprint(os.Stderr, "尚未购买该课程\n")
+	case errors.Is(err, course.ErrInvalidProductID):
+		fmt.Fprint(os.Stderr, "\r输入的课程 ID 有误\n")
+	default:
+		return err
 	}
 	r.currentState = StateInputProductID
 	return nil
 }
 
-// validateProductCode checks if the product code field in the response body returned by the API
-// exists in the selected product's accepted product types list.
-func (r *FSMRunner) validateProductCode(productCode string) bool {
-	for _, pt := range r.selectedProductType.AcceptProductTypes {
-		if pt == productCode {
-			return true
-		}
+// handleArticleAPIError prints the reason and lets user select another article
+// if API rejects the article only or it is taken offline, otherwise returns the error.
+// Auth failures and rate limit can't be recovered by selecting another article.
+func (r *FSMRunner) handleArticleAPIError(err error) error {
+	var apiErr *geektime.APIError
+	offline := geektime.IsArticleOffline(err)
+	if !offline && (!errors.As(err, &apiErr) || geektime.IsAuth(err) || geektime.IsRetryable(err)) {
+		return err
+	}
+	logger.Errorf(err, "Article download failed")
+	switch {
+	case offline:
+		fmt.Fprint(os.Stderr, "\r该文章已下架, 请重新选择\n")
+	case geektime.IsNotPurchased(err):
+		fmt.Fprint(os.Stderr, "\r尚未购买该文章, 请重新选择\n")
+	default:
+		fmt.Fprintf(os.Stderr, "\r%s\n", apiErr.Error())
 	}
-	fmt.Fprint(os.Stderr, "\r输入的课程 ID 有误\n")
-	return false
+	time.Sleep(time.Second)
+	r.currentState = StateSelectArticle
+	return nil
 }
 
 func (r *FSMRunner) handleSelectArticle(index int, selectedProductType ui.ProductTypeSelectOption, selectedProduct geektime.Course) error {
@@ -215,7 +201,7 @@ func (r *FSMRunner) handleSelectArticle(index int, selectedProductType ui.Produc
 
 	err := r.courseDownloader.DownloadArticle(r.selectedProduct, r.selectedProductType, a, true)
 	if err != nil {
-		return err
+		return r.handleArticleAPIError(err)
 	}
 	fmt.Printf("\r%s 下载完成", a.Title)
 	time.Sleep(time.Second)
@@ -227,7 +213,11 @@ func (r *FSMRunner) handleSelectArticle(index int, selectedProductType ui.Produc
 // Returns an error if any step in the download process fails.
 func (r *FSMRunner) handleDownloadAll() error {
 	if err := r.courseDownloader.DownloadAll(r.selectedProduct, r.selectedProductType); err != nil {
-		return err
+		// failed articles are reported in failures.json, user can continue with other products
+		if !errors.Is(err, course.ErrPartialFailure) {
+			return err
+		}
+		logger.Errorf(err, "Some articles failed to download")
 	}
 	r.currentState = StateSelectProductType
 	return nil
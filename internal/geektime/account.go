@@ -19,7 +19,7 @@ const (
 )
 
 // Login call geektime login api and return auth cookies
-func Login(phone, password string) ([]*http.Cookie, error) {
+func (c *Client) Login(phone, password string) ([]*http.Cookie, error) {
 	var res struct {
 		Code int `json:"code"`
 		Data struct {
@@ -32,15 +32,12 @@ func Login(phone, password string) ([]*http.Cookie, error) {
 		} `json:"error"`
 	}
 
-	client := resty.New().
-		SetTimeout(DefaultTimeout).
-		SetHeader(UserAgent, DefaultUserAgent).
-		SetLogger(logger.DiscardLogger{})
+	client := c.accountClient()
 
 	logger.Infof("Login request start")
 
 	resp, err := client.R().
-		SetHeader(Origin, DefaultBaseURL).
+		SetHeader(Origin, c.baseURL).
 		SetBody(map[string]interface{}{
 			"country":   86,
 			"appid":     1,
@@ -49,7 +46,7 @@ func Login(phone, password string) ([]*http.Cookie, error) {
 			"password":  password,
 		}).
 		SetResult(&res).
-		Post(GeekBangAccountBaseURL + LoginPath)
+		Post(c.accountBaseURL + LoginPath)
 
 	if err != nil {
 		return nil, err
@@ -64,9 +61,9 @@ func Login(phone, password string) ([]*http.Cookie, error) {
 
 	if res.Code == 0 {
 		var cookies []*http.Cookie
-		for _, c := range resp.Cookies() {
-			if c.Name == GCID || c.Name == GCESS {
-				cookies = append(cookies, c)
+		for _, cookie := range resp.Cookies() {
+			if cookie.Name == GCID || cookie.Name == GCESS {
+				cookies = append(cookies, cookie)
 			}
 		}
 		return cookies, nil
@@ -75,11 +72,11 @@ func Login(phone, password string) ([]*http.Cookie, error) {
 	} else if res.Error.Code == -3005 {
 		return nil, ErrTooManyLoginAttemptTimes
 	}
-	return nil, ErrGeekTimeAPIBadCode{LoginPath, resp.String()}
+	return nil, newAPIError(LoginPath, resp.StatusCode(), resp.Body())
 }
 
-// Auth check if current user login is expired or login in another device
-func Auth(cs []*http.Cookie) error {
+// Auth check if cookies of client are expired or login in another device
+func (c *Client) Auth() error {
 	var res struct {
 		Code int `json:"code"`
 	}
@@ -88,19 +85,16 @@ func Auth(cs []*http.Cookie) error {
 	params["t"] = t
 	params["v_t"] = t
 
-	client := resty.New().
-		SetTimeout(DefaultTimeout).
-		SetHeader(UserAgent, DefaultUserAgent).
-		SetLogger(logger.DiscardLogger{})
+	client := c.accountClient()
 
 	logger.Infof("Auth request start")
 
 	resp, err := client.R().
 		SetQueryParams(params).
-		SetCookies(cs).
-		SetHeader(Origin, DefaultBaseURL).
+		SetCookies(c.Cookies).
+		SetHeader(Origin, c.baseURL).
 		SetResult(&res).
-		Get(GeekBangAccountBaseURL + V1AuthPath)
+		Get(c.accountBaseURL + V1AuthPath)
 
 	if err != nil {
 		return err
@@ -119,3 +113,15 @@ func Auth(cs []*http.Cookie) error {
 
 	return nil
 }
+
+// accountClient returns a new resty client without cookies of Client, it shares transport of Client
+func (c *Client) accountClient() *resty.Client {
+	client := resty.New().
+		SetTimeout(DefaultTimeout).
+		SetHeader(UserAgent, DefaultUserAgent).
+		SetLogger(logger.DiscardLogger{})
+	if c.transport != nil {
+		client.SetTransport(c.transport)
+	}
+	return client
+}
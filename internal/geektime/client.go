@@ -1,14 +1,15 @@
 package geektime
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	"net/http"
-	"reflect"
+	"strconv"
 	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/nicoxiang/geektime-downloader/internal/pkg/logger"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/ratelimit"
 )
 
 const (
@@ -25,19 +26,99 @@ const (
 type Client struct {
 	RestyClient *resty.Client
 	Cookies     []*http.Cookie
+
+	// base urls of Geektime sites and aliyun vod, default to the online ones
+	baseURL           string
+	enterpriseBaseURL string
+	universityBaseURL string
+	accountBaseURL    string
+	vodBaseURL        string
+	// transport sends all requests of client, nil uses http.DefaultTransport
+	transport http.RoundTripper
+
+	// ctx cancels requests and waits of limiter and backoff
+	ctx        context.Context
+	apiLimiter *ratelimit.Limiter
+	cdnLimiter *ratelimit.Limiter
+	backoff    *ratelimit.Backoff
+}
+
+// Option configures Client
+type Option func(*Client)
+
+// WithBaseURL sets base url of Geektime API
+func WithBaseURL(u string) Option {
+	return func(c *Client) {
+		c.baseURL = u
+	}
+}
+
+// WithEnterpriseBaseURL sets base url of Geektime enterprise API
+func WithEnterpriseBaseURL(u string) Option {
+	return func(c *Client) {
+		c.enterpriseBaseURL = u
+	}
+}
+
+// WithUniversityBaseURL sets base url of Geektime university API
+func WithUniversityBaseURL(u string) Option {
+	return func(c *Client) {
+		c.universityBaseURL = u
+	}
+}
+
+// WithAccountBaseURL sets base url of Geektime account API, which is used by login and auth
+func WithAccountBaseURL(u string) Option {
+	return func(c *Client) {
+		c.accountBaseURL = u
+	}
+}
+
+// WithVODBaseURL sets base url of aliyun vod API, which returns video play info
+func WithVODBaseURL(u string) Option {
+	return func(c *Client) {
+		c.vodBaseURL = u
+	}
+}
+
+// WithTransport sends all requests of client by rt, e.g. to record or replay responses
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.transport = rt
+	}
+}
+
+// WithContext sets context of all requests, program exits without waiting for rate limit when it's canceled
+func WithContext(ctx context.Context) Option {
+	return func(c *Client) {
+		c.ctx = ctx
+	}
+}
+
+// WithAPILimiter throttles requests to Geektime API
+func WithAPILimiter(l *ratelimit.Limiter) Option {
+	return func(c *Client) {
+		c.apiLimiter = l
+	}
 }
 
-// ErrGeekTimeAPIBadCode ...
-type ErrGeekTimeAPIBadCode struct {
-	Path           string
-	ResponseString string
+// WithCDNLimiter throttles other requests of RestyClient, like m3u8, video key and subtitle
+func WithCDNLimiter(l *ratelimit.Limiter) Option {
+	return func(c *Client) {
+		c.cdnLimiter = l
+	}
 }
 
-// Error implements error interface
-func (e ErrGeekTimeAPIBadCode) Error() string {
-	return fmt.Sprintf("请求极客时间接口 %s 失败, ResponseBody: %s", e.Path, e.ResponseString)
+// WithBackoff retries Geektime API requests after backoff when they hit rate limit
+func WithBackoff(b *ratelimit.Backoff) Option {
+	return func(c *Client) {
+		c.backoff = b
+	}
 }
 
+// apiRequestKey marks context of Geektime API request, which is throttled by api limiter instead of cdn limiter
+type apiRequestKey struct{}
+
 var (
 	// ErrWrongPassword ...
 	ErrWrongPassword = errors.New("密码错误, 请尝试重新登录")
@@ -47,10 +128,12 @@ var (
 	ErrGeekTimeRateLimit = errors.New("已触发限流, 你可以选择重新登录/重新获取 cookie, 或者稍后再试, 然后生成剩余的文章")
 	// ErrAuthFailed ...
 	ErrAuthFailed = errors.New("当前账户在其他设备登录或者登录已经过期, 请尝试重新登录")
+	// ErrArticleOffline ...
+	ErrArticleOffline = errors.New("文章已下架")
 )
 
 // NewClient returns a new Geektime API client.
-func NewClient(cs []*http.Cookie) *Client {
+func NewClient(cs []*http.Cookie, opts ...Option) *Client {
 	restyClient := resty.New().
 		SetCookies(cs).
 		SetRetryCount(1).
@@ -58,10 +141,46 @@ func NewClient(cs []*http.Cookie) *Client {
 		SetHeader(UserAgent, DefaultUserAgent).
 		SetLogger(logger.DiscardLogger{})
 
-	c := &Client{RestyClient: restyClient, Cookies: cs}
+	c := &Client{
+		RestyClient:       restyClient,
+		Cookies:           cs,
+		baseURL:           DefaultBaseURL,
+		enterpriseBaseURL: GeekBangEnterpriseBaseURL,
+		universityBaseURL: GeekBangUniversityBaseURL,
+		accountBaseURL:    GeekBangAccountBaseURL,
+		vodBaseURL:        AliyunVODBaseURL,
+		ctx:               context.Background(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.transport != nil {
+		restyClient.SetTransport(c.transport)
+	}
+	restyClient.OnBeforeRequest(func(_ *resty.Client, r *resty.Request) error {
+		if r.Context().Value(apiRequestKey{}) != nil {
+			return nil
+		}
+		return c.cdnLimiter.Wait(r.Context())
+	})
 	return c
 }
 
+// BaseURL returns base url of Geektime API, which is also the site of article pages and Origin of CDN requests
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// ArticleURL returns page url of column article
+func (c *Client) ArticleURL(aid int) string {
+	return c.baseURL + ArticlePagePath + strconv.Itoa(aid)
+}
+
+// VODBaseURL returns base url of aliyun vod API
+func (c *Client) VODBaseURL() string {
+	return c.vodBaseURL
+}
+
 // newRequest new http request
 func (c *Client) newRequest(
 	method string,
@@ -71,7 +190,7 @@ func (c *Client) newRequest(
 	body interface{},
 	result interface{},
 ) *resty.Request {
-	r := c.RestyClient.R()
+	r := c.RestyClient.R().SetContext(context.WithValue(c.ctx, apiRequestKey{}, true))
 	r.Method = method
 	r.URL = baseURL + path
 	r.SetHeader(Origin, baseURL)
@@ -85,15 +204,29 @@ func (c *Client) newRequest(
 	return r
 }
 
-// do perform http request
-func do(request *resty.Request) (*resty.Response, error) {
+// do perform http request, request hitting rate limit is sent again after backoff
+func (c *Client) do(request *resty.Request) (*resty.Response, error) {
+	var resp *resty.Response
+	err := c.backoff.Do(request.Context(), ErrGeekTimeRateLimit, func() (err error) {
+		resp, err = c.execute(request)
+		return err
+	})
+	return resp, err
+}
+
+// execute sends http request once after api limiter allows
+func (c *Client) execute(request *resty.Request) (*resty.Response, error) {
+	if err := c.apiLimiter.Wait(request.Context()); err != nil {
+		return nil, err
+	}
 	logger.Infof("Http request start, method: %s, url: %s, request body: %v",
 		request.Method,
 		request.URL,
 		request.Body,
 	)
 	resp, err := request.Execute(request.Method, request.URL)
-	if err != nil {
+	// failed response is still returned when its data can't be decoded into result, e.g. "data":[]
+	if resp == nil || resp.RawResponse == nil {
 		return nil, err
 	}
 
@@ -105,31 +238,17 @@ func do(request *resty.Request) (*resty.Response, error) {
 		resp.RawResponse.StatusCode,
 	)
 
-	if statusCode != 200 {
-		logNotOkResponse(resp)
-		switch statusCode {
-		case 451:
-			return nil, ErrGeekTimeRateLimit
-		case 452:
-			return nil, ErrAuthFailed
+	// other non 200 status with code 0 is not an error, only rate limit, auth failed and server error are
+	e := newAPIError(resp.RawResponse.Request.URL.Path, statusCode, resp.Body())
+	if statusCode != StatusRateLimit && statusCode != StatusAuthFailed &&
+		statusCode < http.StatusInternalServerError && e.Code == 0 {
+		if statusCode != 200 {
+			logNotOkResponse(resp)
 		}
+		return resp, err
 	}
-
-	rv := reflect.ValueOf(request.Result)
-	f := reflect.Indirect(rv).FieldByName("Code")
-	code := int(f.Int())
-
-	if code == 0 {
-		return resp, nil
-	}
-
 	logNotOkResponse(resp)
-	// 未登录或者已失效
-	if code == -3050 || code == -2000 {
-		return nil, ErrAuthFailed
-	}
-
-	return nil, ErrGeekTimeAPIBadCode{request.URL, resp.String()}
+	return nil, e
 }
 
 func logNotOkResponse(resp *resty.Response) {
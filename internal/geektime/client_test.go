@@ -0,0 +1,68 @@
+package geektime
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/ratelimit"
+)
+
+func TestClient_BaseURLAndBackoff(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != V1ArticlePath {
+			t.Errorf("path = %s, want %s", r.URL.Path, V1ArticlePath)
+		}
+		if requests == 1 {
+			w.WriteHeader(451)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"code":0,"data":{"article_title":"标题"}}`)
+	}))
+	defer server.Close()
+
+	c := NewClient(nil,
+		WithBaseURL(server.URL),
+		WithBackoff(&ratelimit.Backoff{Initial: time.Millisecond, Max: time.Millisecond, Retries: 1}),
+	)
+	res, err := c.V1ArticleInfo(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 || res.Data.ArticleTitle != "标题" {
+		t.Errorf("requests = %d, title = %q, want retried once after rate limit", requests, res.Data.ArticleTitle)
+	}
+}
+
+func TestClient_APIErrorWithEmptyData(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"code":-1,"error":{"msg":"未购买","code":-5001},"extra":{"request-id":"r1"},"data":[]}`)
+	}))
+	defer server.Close()
+
+	_, err := NewClient(nil, WithBaseURL(server.URL)).V1ArticleInfo(1)
+	var e *APIError
+	if !errors.As(err, &e) || !IsNotPurchased(err) || e.RequestID != "r1" {
+		t.Errorf("err = %v, want not purchased APIError with request-id", err)
+	}
+}
+
+func TestClient_ArticleOffline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"code":0,"data":{"article_title":"","article_content":""},"error":[],"extra":[]}`)
+	}))
+	defer server.Close()
+
+	_, err := NewClient(nil, WithBaseURL(server.URL)).V1ArticleInfo(1)
+	if !IsArticleOffline(err) || IsRetryable(err) {
+		t.Errorf("err = %v, want article offline", err)
+	}
+}
@@ -0,0 +1,83 @@
+package geektime
+
+import (
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/nicoxiang/geektime-downloader/internal/geektime/response"
+)
+
+const (
+	// V4CommentListPath used to get comments of article page by page
+	V4CommentListPath = "/serv/v4/comment/list"
+	// commentPageSize is the number of comments in one page, same as article page
+	commentPageSize = 20
+)
+
+// Comment is a featured comment of article
+type Comment struct {
+	ID      int       `json:"id"`
+	Author  string    `json:"author"`
+	Content string    `json:"content"`
+	Time    time.Time `json:"time"`
+	Likes   int       `json:"likes"`
+	// Replies is the number of replies by other users
+	Replies       int            `json:"replies"`
+	AuthorReplies []CommentReply `json:"author_replies,omitempty"`
+}
+
+// CommentReply is a reply to comment by column author
+type CommentReply struct {
+	Author  string    `json:"author"`
+	Content string    `json:"content"`
+	Time    time.Time `json:"time"`
+}
+
+// ArticleComments pages through comments of article, only the first page is returned if all is false
+func (c *Client) ArticleComments(articleID int, all bool) ([]Comment, error) {
+	var comments []Comment
+	var prev int64
+	for {
+		var res response.V4CommentListResponse
+		r := c.newRequest(
+			resty.MethodPost,
+			c.baseURL,
+			V4CommentListPath,
+			nil,
+			map[string]interface{}{
+				"aid":  articleID,
+				"prev": prev,
+				"size": commentPageSize,
+			},
+			&res,
+		)
+		if _, err := c.do(r); err != nil {
+			return nil, err
+		}
+
+		for _, v := range res.Data.List {
+			comment := Comment{
+				ID:      v.ID,
+				Author:  v.UserName,
+				Content: v.CommentContent,
+				Time:    time.Unix(v.CommentCtime, 0),
+				Likes:   v.LikeCount,
+				Replies: v.DiscussionCount,
+			}
+			for _, reply := range v.Replies {
+				comment.AuthorReplies = append(comment.AuthorReplies, CommentReply{
+					Author:  reply.UserName,
+					Content: reply.Content,
+					Time:    time.Unix(reply.Ctime, 0),
+				})
+			}
+			comments = append(comments, comment)
+		}
+
+		// next page starts after score of the last comment
+		if !all || !res.Data.Page.More || len(res.Data.List) == 0 {
+			return comments, nil
+		}
+		prev = res.Data.List[len(res.Data.List)-1].Score
+	}
+}
@@ -0,0 +1,53 @@
+package geektime
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_ArticleComments(t *testing.T) {
+	// comments are paged by score, next page starts after the score of last comment
+	pages := map[int64]string{
+		0:  `{"code":0,"data":{"list":[{"id":1,"user_name":"u1","comment_content":"c1","score":30,"replies":[{"user_name":"作者","content":"r1","ctime":1}]},{"id":2,"comment_content":"c2","score":20}],"page":{"more":true}}}`,
+		20: `{"code":0,"data":{"list":[{"id":3,"comment_content":"c3","score":10}],"page":{"more":false}}}`,
+	}
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var req struct {
+			AID  int   `json:"aid"`
+			Prev int64 `json:"prev"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		page, ok := pages[req.Prev]
+		if r.URL.Path != V4CommentListPath || req.AID != 100 || !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, page)
+	}))
+	defer server.Close()
+
+	client := NewClient(nil, WithBaseURL(server.URL))
+	all, err := client.ArticleComments(100, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all[0].ID != 1 || all[2].ID != 3 || requests != 2 {
+		t.Fatalf("all = %+v, %d requests", all, requests)
+	}
+	if len(all[0].AuthorReplies) != 1 || all[0].AuthorReplies[0].Content != "r1" {
+		t.Errorf("author replies = %+v", all[0].AuthorReplies)
+	}
+	first, err := client.ArticleComments(100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 2 || requests != 3 {
+		t.Errorf("first page = %+v, %d requests", first, requests)
+	}
+}
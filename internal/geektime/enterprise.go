@@ -44,7 +44,7 @@ func (c *Client) V1EnterpriseArticleDetail(articleID string) (response.V1Enterpr
 	var res response.V1EnterpriseArticlesDetailResponse
 	r := c.newRequest(
 		resty.MethodPost,
-		GeekBangEnterpriseBaseURL,
+		c.enterpriseBaseURL,
 		V1EnterpriseArticleDetailPath,
 		nil,
 		map[string]interface{}{
@@ -52,7 +52,7 @@ func (c *Client) V1EnterpriseArticleDetail(articleID string) (response.V1Enterpr
 		},
 		&res,
 	)
-	if _, err := do(r); err != nil {
+	if _, err := c.do(r); err != nil {
 		return response.V1EnterpriseArticlesDetailResponse{}, err
 	}
 	return res, nil
@@ -63,7 +63,7 @@ func (c *Client) EnterpriseVideoPlayAuth(articleID, videoID string) (string, err
 	var res response.V3VideoPlayAuthResponse
 	r := c.newRequest(
 		resty.MethodPost,
-		GeekBangEnterpriseBaseURL,
+		c.enterpriseBaseURL,
 		V1EnterpriseVideoPlayAuthPath,
 		nil,
 		map[string]interface{}{
@@ -72,7 +72,7 @@ func (c *Client) EnterpriseVideoPlayAuth(articleID, videoID string) (string, err
 		},
 		&res,
 	)
-	if _, err := do(r); err != nil {
+	if _, err := c.do(r); err != nil {
 		return "", err
 	}
 	return res.Data.PlayAuth, nil
@@ -83,7 +83,7 @@ func (c *Client) enterpriseCourseInfo(productID int) (Course, error) {
 
 	r := c.newRequest(
 		resty.MethodPost,
-		GeekBangEnterpriseBaseURL,
+		c.enterpriseBaseURL,
 		V1EnterpriseCourseInfoPath,
 		nil,
 		map[string]interface{}{
@@ -92,7 +92,7 @@ func (c *Client) enterpriseCourseInfo(productID int) (Course, error) {
 		&res,
 	)
 
-	if _, err := do(r); err != nil {
+	if _, err := c.do(r); err != nil {
 		return Course{}, err
 	}
 
@@ -109,7 +109,7 @@ func (c *Client) enterpriseCourseArticles(productID int) ([]Article, error) {
 	var res response.V1EnterpriseArticlesResponse
 	r := c.newRequest(
 		resty.MethodPost,
-		GeekBangEnterpriseBaseURL,
+		c.enterpriseBaseURL,
 		V1EnterpriseArticlesInfoPath,
 		nil,
 		map[string]interface{}{
@@ -118,7 +118,7 @@ func (c *Client) enterpriseCourseArticles(productID int) ([]Article, error) {
 		&res,
 	)
 
-	if _, err := do(r); err != nil {
+	if _, err := c.do(r); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,139 @@
+package geektime
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// http status and business codes of Geektime API with known meaning
+const (
+	// StatusRateLimit means too many requests
+	StatusRateLimit = 451
+	// StatusAuthFailed means login is expired or account logged in on another device
+	StatusAuthFailed = 452
+	// CodeNotLogin means request has no valid login cookies
+	CodeNotLogin = -2000
+	// CodeLoginExpired means login is expired
+	CodeLoginExpired = -3050
+	// CodeNotPurchased means account has no access to the product
+	CodeNotPurchased = -5001
+)
+
+// APIError is a failed response of Geektime API, either http status is not 200 or business code is not 0
+type APIError struct {
+	// Endpoint is the request path, like /serv/v1/article
+	Endpoint   string
+	StatusCode int
+	// Code is the business code, the code of error field if there is one
+	Code      int
+	Message   string
+	RequestID string
+	// Body is the raw response body, for error without message
+	Body string
+}
+
+// Error implements error interface
+func (e *APIError) Error() string {
+	detail := fmt.Sprintf("接口: %s, HTTP %d, code: %d", e.Endpoint, e.StatusCode, e.Code)
+	if e.Message != "" {
+		detail += ", msg: " + e.Message
+	}
+	if e.RequestID != "" {
+		detail += ", request-id: " + e.RequestID
+	}
+	if sentinel := e.sentinel(); sentinel != nil {
+		return fmt.Sprintf("%s (%s)", sentinel.Error(), detail)
+	}
+	if e.Message == "" {
+		detail += ", ResponseBody: " + e.Body
+	}
+	return "请求极客时间接口失败, " + detail
+}
+
+// Is makes errors.Is match ErrGeekTimeRateLimit and ErrAuthFailed by status and code
+func (e *APIError) Is(target error) bool {
+	sentinel := e.sentinel()
+	return sentinel != nil && sentinel == target
+}
+
+func (e *APIError) sentinel() error {
+	switch {
+	case e.StatusCode == StatusRateLimit:
+		return ErrGeekTimeRateLimit
+	case e.StatusCode == StatusAuthFailed, e.Code == CodeNotLogin, e.Code == CodeLoginExpired:
+		return ErrAuthFailed
+	}
+	return nil
+}
+
+// IsRetryable checks if request of err may succeed later, when it hits rate limit, server fails or times out
+func IsRetryable(err error) bool {
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	var e *APIError
+	if !errors.As(err, &e) {
+		return false
+	}
+	return e.StatusCode == StatusRateLimit ||
+		e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode >= http.StatusInternalServerError
+}
+
+// IsRateLimit checks if err is caused by Geektime rate limit
+func IsRateLimit(err error) bool {
+	return errors.Is(err, ErrGeekTimeRateLimit)
+}
+
+// IsAuth checks if err is caused by expired or missing login, user needs to login again
+func IsAuth(err error) bool {
+	return errors.Is(err, ErrAuthFailed)
+}
+
+// IsNotPurchased checks if err is caused by no access to the product
+func IsNotPurchased(err error) bool {
+	var e *APIError
+	return errors.As(err, &e) && e.Code == CodeNotPurchased
+}
+
+// IsArticleOffline checks if err is caused by the article taken offline
+func IsArticleOffline(err error) bool {
+	return errors.Is(err, ErrArticleOffline)
+}
+
+// newAPIError returns APIError of response body, body which is not json only fills the status
+func newAPIError(endpoint string, statusCode int, body []byte) *APIError {
+	e := &APIError{Endpoint: endpoint, StatusCode: statusCode, Body: string(body)}
+
+	// error and extra are empty arrays in successful response
+	var res struct {
+		Code  int             `json:"code"`
+		Error json.RawMessage `json:"error"`
+		Extra json.RawMessage `json:"extra"`
+	}
+	if json.Unmarshal(body, &res) != nil {
+		return e
+	}
+	e.Code = res.Code
+	var apiErr struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if json.Unmarshal(res.Error, &apiErr) == nil {
+		if apiErr.Code != 0 {
+			e.Code = apiErr.Code
+		}
+		e.Message = apiErr.Msg
+	}
+	var extra struct {
+		RequestID string `json:"request-id"`
+	}
+	if json.Unmarshal(res.Extra, &extra) == nil {
+		e.RequestID = extra.RequestID
+	}
+	return e
+}
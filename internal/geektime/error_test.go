@@ -0,0 +1,66 @@
+package geektime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAPIError_Classification(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		body         string
+		code         int
+		auth, retry  bool
+		notPurchased bool
+		rateLimit    bool
+		requestID    string
+	}{
+		{"not login", 200, `{"code":-1,"error":{"msg":"未登录","code":-2000},"extra":{"request-id":"r1"},"data":[]}`, CodeNotLogin, true, false, false, false, "r1"},
+		{"login expired", 200, `{"code":-1,"error":{"msg":"登录过期","code":-3050},"extra":[],"data":[]}`, CodeLoginExpired, true, false, false, false, ""},
+		{"not purchased", 200, `{"code":-1,"error":{"msg":"未购买","code":-5001},"data":[]}`, CodeNotPurchased, false, false, true, false, ""},
+		{"rate limit", StatusRateLimit, ``, 0, false, true, false, true, ""},
+		{"auth failed", StatusAuthFailed, `<html></html>`, 0, true, false, false, false, ""},
+		{"server error", 502, `{"code":-1,"error":[],"extra":[]}`, -1, false, true, false, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error = newAPIError(V1ArticlePath, tt.status, []byte(tt.body))
+			err = fmt.Errorf("wrapped: %w", err)
+
+			var e *APIError
+			if !errors.As(err, &e) {
+				t.Fatal("error is not APIError")
+			}
+			if e.Code != tt.code || e.RequestID != tt.requestID || e.Endpoint != V1ArticlePath {
+				t.Errorf("code = %d, request-id = %q, endpoint = %s", e.Code, e.RequestID, e.Endpoint)
+			}
+			if IsAuth(err) != tt.auth || IsRetryable(err) != tt.retry ||
+				IsNotPurchased(err) != tt.notPurchased || IsRateLimit(err) != tt.rateLimit {
+				t.Errorf("auth = %v, retryable = %v, not purchased = %v, rate limit = %v",
+					IsAuth(err), IsRetryable(err), IsNotPurchased(err), IsRateLimit(err))
+			}
+		})
+	}
+}
+
+func TestIsRetryable_NetworkTimeout(t *testing.T) {
+	var d net.Dialer
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	time.Sleep(time.Millisecond)
+	_, err := d.DialContext(ctx, "tcp", "127.0.0.1:1")
+	if err == nil {
+		t.Fatal("dial succeeded, want timeout")
+	}
+	if !IsRetryable(fmt.Errorf("wrapped: %w", err)) {
+		t.Errorf("timeout %v is not retryable", err)
+	}
+	if IsRetryable(errors.New("other")) {
+		t.Error("error which is not timeout is retryable")
+	}
+}
@@ -5,24 +5,32 @@ import (
 
 	"github.com/go-resty/resty/v2"
 	"github.com/nicoxiang/geektime-downloader/internal/geektime/response"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/logger"
 )
 
 const (
 	// DefaultBaseURL ...
 	DefaultBaseURL = "https://time.geekbang.org"
 
+	// ArticlePagePath is the path of column article page, followed by article id
+	ArticlePagePath = "/column/article/"
+
 	// V1ColumnArticlesPath get all articles summary info in one column
 	V1ColumnArticlesPath = "/serv/v1/column/articles"
 	// V1ArticlePath used in normal column
 	V1ArticlePath = "/serv/v1/article"
 	// V3ColumnInfoPath used in get normal column/video info
 	V3ColumnInfoPath = "/serv/v3/column/info"
+	// V1ChaptersPath used to get sections of normal column
+	V1ChaptersPath = "/serv/v1/chapters"
 	// V3ProductInfoPath used in get daily lesson, qconplus product info
 	V3ProductInfoPath = "/serv/v3/product/info"
 	// V3ArticleInfoPath used in normal video, daily lesson, qconplus
 	V3ArticleInfoPath = "/serv/v3/article/info"
 	// V3VideoPlayAuthPath used in normal video, daily lesson, qconplus video play auth
 	V3VideoPlayAuthPath = "/serv/v3/source_auth/video_play_auth"
+	// AliyunVODBaseURL is the aliyun vod API which returns video play info
+	AliyunVODBaseURL = "https://vod.cn-shanghai.aliyuncs.com"
 
 	// GeekBangCookieDomain ...
 	GeekBangCookieDomain = ".geekbang.org"
@@ -35,11 +43,14 @@ const (
 
 // Course ...
 type Course struct {
-	Access   bool
-	ID       int
-	Title    string
-	Type     string
-	IsVideo  bool
+	Access  bool
+	ID      int
+	Title   string
+	Type    string
+	IsVideo bool
+	// Author and CoverURL are only available for normal column
+	Author   string
+	CoverURL string
 	Articles []Article
 }
 
@@ -59,8 +70,14 @@ func (c *Client) CourseInfo(productID int) (Course, error) {
 		return p, err
 	}
 
+	// only text column articles are grouped by section, video column files are saved in section dir
+	var chapters map[string]string
+	if IsTextCourse(p) {
+		chapters = c.columnChapters(productID)
+	}
+
 	var articles []Article
-	articles, err = c.columnArticles(productID)
+	articles, err = c.columnArticles(productID, chapters)
 	if err != nil {
 		return p, err
 	}
@@ -74,7 +91,7 @@ func (c *Client) V1ArticleInfo(articleID int) (response.V1ArticleResponse, error
 	var res response.V1ArticleResponse
 	r := c.newRequest(
 		resty.MethodPost,
-		DefaultBaseURL,
+		c.baseURL,
 		V1ArticlePath,
 		nil,
 		map[string]interface{}{
@@ -85,9 +102,13 @@ func (c *Client) V1ArticleInfo(articleID int) (response.V1ArticleResponse, error
 		},
 		&res,
 	)
-	if _, err := do(r); err != nil {
+	if _, err := c.do(r); err != nil {
 		return response.V1ArticleResponse{}, err
 	}
+	// article taken offline is still returned successfully, but without title and content
+	if res.Data.ArticleTitle == "" && res.Data.ArticleContent == "" {
+		return response.V1ArticleResponse{}, ErrArticleOffline
+	}
 	return res, nil
 }
 
@@ -96,7 +117,7 @@ func (c *Client) ProductInfo(productID int) (response.V3ProductInfoResponse, err
 	var res response.V3ProductInfoResponse
 	r := c.newRequest(
 		resty.MethodPost,
-		DefaultBaseURL,
+		c.baseURL,
 		V3ProductInfoPath,
 		nil,
 		map[string]interface{}{
@@ -104,7 +125,7 @@ func (c *Client) ProductInfo(productID int) (response.V3ProductInfoResponse, err
 		},
 		&res,
 	)
-	if _, err := do(r); err != nil {
+	if _, err := c.do(r); err != nil {
 		return response.V3ProductInfoResponse{}, err
 	}
 	return res, nil
@@ -115,7 +136,7 @@ func (c *Client) V3ArticleInfo(articleID int) (response.V3ArticleInfoResponse, e
 	var res response.V3ArticleInfoResponse
 	r := c.newRequest(
 		resty.MethodPost,
-		DefaultBaseURL,
+		c.baseURL,
 		V3ArticleInfoPath,
 		nil,
 		map[string]interface{}{
@@ -123,7 +144,7 @@ func (c *Client) V3ArticleInfo(articleID int) (response.V3ArticleInfoResponse, e
 		},
 		&res,
 	)
-	if _, err := do(r); err != nil {
+	if _, err := c.do(r); err != nil {
 		return response.V3ArticleInfoResponse{}, err
 	}
 	return res, nil
@@ -134,7 +155,7 @@ func (c *Client) VideoPlayAuth(articleID, sourceType int, videoID string) (strin
 	var res response.V3VideoPlayAuthResponse
 	r := c.newRequest(
 		resty.MethodPost,
-		DefaultBaseURL,
+		c.baseURL,
 		V3VideoPlayAuthPath,
 		nil,
 		map[string]interface{}{
@@ -144,7 +165,7 @@ func (c *Client) VideoPlayAuth(articleID, sourceType int, videoID string) (strin
 		},
 		&res,
 	)
-	if _, err := do(r); err != nil {
+	if _, err := c.do(r); err != nil {
 		return "", err
 	}
 	return res.Data.PlayAuth, nil
@@ -155,7 +176,7 @@ func (c *Client) columnInfo(productID int) (Course, error) {
 	var res response.V3ColumnInfoResponse
 	r := c.newRequest(
 		resty.MethodPost,
-		DefaultBaseURL,
+		c.baseURL,
 		V3ColumnInfoPath,
 		nil,
 		map[string]interface{}{
@@ -164,25 +185,52 @@ func (c *Client) columnInfo(productID int) (Course, error) {
 		},
 		&res,
 	)
-	if _, err := do(r); err != nil {
+	if _, err := c.do(r); err != nil {
 		return Course{}, err
 	}
 
 	return Course{
-		Access:  res.Data.Extra.Sub.AccessMask > 0,
-		ID:      res.Data.ID,
-		Type:    res.Data.Type,
-		Title:   res.Data.Title,
-		IsVideo: res.Data.IsVideo,
+		Access:   res.Data.Extra.Sub.AccessMask > 0,
+		ID:       res.Data.ID,
+		Type:     res.Data.Type,
+		Title:    res.Data.Title,
+		IsVideo:  res.Data.IsVideo,
+		Author:   res.Data.Author.Name,
+		CoverURL: res.Data.Cover.Rectangle,
 	}, nil
 }
 
-// columnArticles call geektime api to get article list
-func (c *Client) columnArticles(cid int) ([]Article, error) {
+// columnChapters returns section titles of column by chapter id, column without sections or
+// failed request returns nil and articles are not grouped
+func (c *Client) columnChapters(cid int) map[string]string {
+	var res response.V1ChaptersResponse
+	r := c.newRequest(
+		resty.MethodPost,
+		c.baseURL,
+		V1ChaptersPath,
+		nil,
+		map[string]interface{}{
+			"cid": strconv.Itoa(cid),
+		},
+		&res,
+	)
+	if _, err := c.do(r); err != nil {
+		logger.Warnf("Failed to get column chapters, columnID: %d, err: %v", cid, err)
+		return nil
+	}
+	chapters := make(map[string]string, len(res.Data))
+	for _, v := range res.Data {
+		chapters[v.ID.String()] = v.Title
+	}
+	return chapters
+}
+
+// columnArticles call geektime api to get article list, section titles are looked up in chapters
+func (c *Client) columnArticles(cid int, chapters map[string]string) ([]Article, error) {
 	res := &response.V1ColumnArticlesResponse{}
 	r := c.newRequest(
 		resty.MethodPost,
-		DefaultBaseURL,
+		c.baseURL,
 		V1ColumnArticlesPath,
 		nil,
 		map[string]interface{}{
@@ -194,15 +242,16 @@ func (c *Client) columnArticles(cid int) ([]Article, error) {
 		},
 		res,
 	)
-	if _, err := do(r); err != nil {
+	if _, err := c.do(r); err != nil {
 		return nil, err
 	}
 
 	var articles []Article
 	for _, v := range res.Data.List {
 		articles = append(articles, Article{
-			AID:   v.ID,
-			Title: v.ArticleTitle,
+			AID:          v.ID,
+			SectionTitle: chapters[v.ChapterID.String()],
+			Title:        v.ArticleTitle,
 		})
 	}
 	return articles, nil
@@ -1,5 +1,10 @@
 package response
 
+import (
+	"bytes"
+	"encoding/json"
+)
+
 // V1ArticleResponse ...
 type V1ArticleResponse struct {
 	// Error []interface{} `json:"error"`
@@ -10,13 +15,13 @@ type V1ArticleResponse struct {
 		// ArticleCover    string        `json:"article_cover"`
 		// Subtitles       []interface{} `json:"subtitles"`
 		// ProductType     string        `json:"product_type"`
-		// AudioDubber     string        `json:"audio_dubber"`
+		AudioDubber string `json:"audio_dubber"`
 		// IsFinished      bool          `json:"is_finished"`
 		// Like            struct {
 		// 	HadDone bool `json:"had_done"`
 		// 	Count   int  `json:"count"`
 		// } `json:"like"`
-		// AudioTime string `json:"audio_time"`
+		AudioTime string `json:"audio_time"`
 		// Share     struct {
 		// 	Content string `json:"content"`
 		// 	Title   string `json:"title"`
@@ -49,7 +54,7 @@ type V1ArticleResponse struct {
 		// VideoID              string        `json:"video_id"`
 		// Sku                  string        `json:"sku"`
 		// VideoCover           string        `json:"video_cover"`
-		// AuthorName           string        `json:"author_name"`
+		AuthorName string `json:"author_name"`
 		// ColumnIsOnboard      bool          `json:"column_is_onboard"`
 		InlineVideoSubtitles []struct {
 			VideoURL          string `json:"video_url"`
@@ -63,13 +68,10 @@ type V1ArticleResponse struct {
 		// ChapterID            string        `json:"chapter_id"`
 		// ColumnHadSub         bool          `json:"column_had_sub"`
 		// ColumnCover          string        `json:"column_cover"`
-		// Neighbors            struct {
-		// 	Left  []interface{} `json:"left"`
-		// 	Right struct {
-		// 		ArticleTitle string `json:"article_title"`
-		// 		ID           int    `json:"id"`
-		// 	} `json:"right"`
-		// } `json:"neighbors"`
+		Neighbors struct {
+			Left  V1ArticleNeighbor `json:"left"`
+			Right V1ArticleNeighbor `json:"right"`
+		} `json:"neighbors"`
 		// RatePercent     int `json:"rate_percent"`
 		// FooterCoverData struct {
 		// 	ImgURL  string `json:"img_url"`
@@ -130,8 +132,24 @@ type V1ArticleResponse struct {
 		// HlsVideos        []interface{} `json:"hls_videos"`
 		// InPvip           int           `json:"in_pvip"`
 		AudioDownloadURL string `json:"audio_download_url"`
-		// ArticleCtime     int           `json:"article_ctime"`
+		ArticleCtime     int64  `json:"article_ctime"`
 		// VideoHeight      int           `json:"video_height"`
 	} `json:"data"`
 	Code int `json:"code"`
 }
+
+// V1ArticleNeighbor is the previous or next article, api returns empty array if there is no such article
+type V1ArticleNeighbor struct {
+	ArticleTitle string `json:"article_title"`
+	ID           int    `json:"id"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler, empty array is decoded as zero value
+func (n *V1ArticleNeighbor) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		*n = V1ArticleNeighbor{}
+		return nil
+	}
+	type neighbor V1ArticleNeighbor
+	return json.Unmarshal(data, (*neighbor)(n))
+}
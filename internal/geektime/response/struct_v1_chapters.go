@@ -0,0 +1,12 @@
+package response
+
+import "encoding/json"
+
+// V1ChaptersResponse ...
+type V1ChaptersResponse struct {
+	Code int `json:"code"`
+	Data []struct {
+		ID    json.Number `json:"id"`
+		Title string      `json:"title"`
+	} `json:"data"`
+}
@@ -1,5 +1,7 @@
 package response
 
+import "encoding/json"
+
 // V1ColumnArticlesResponse ...
 type V1ColumnArticlesResponse struct {
 	// Error []interface{} `json:"error"`
@@ -11,7 +13,7 @@ type V1ColumnArticlesResponse struct {
 			// ArticleCover      string        `json:"article_cover"`
 			// Subtitles         []interface{} `json:"subtitles"`
 			// AudioURL          string        `json:"audio_url,omitempty"`
-			// ChapterID         string        `json:"chapter_id"`
+			ChapterID         json.Number   `json:"chapter_id"`
 			// ColumnHadSub      bool          `json:"column_had_sub"`
 			// ReadingTime       int           `json:"reading_time"`
 			// IsFinished        bool          `json:"is_finished"`
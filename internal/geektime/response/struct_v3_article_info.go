@@ -51,7 +51,7 @@ type V3ArticleInfoResponse struct {
 				// 	Quality string `json:"quality"`
 				// 	URL     string `json:"url"`
 				// } `json:"hls_medias"`
-				// Subtitles []interface{} `json:"subtitles"`
+				Subtitles []interface{} `json:"subtitles"`
 				// Tips      []interface{} `json:"tips"`
 			} `json:"video"`
 			// VideoPreview struct {
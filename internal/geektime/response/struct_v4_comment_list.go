@@ -0,0 +1,32 @@
+package response
+
+// V4CommentListResponse ...
+type V4CommentListResponse struct {
+	Code int `json:"code"`
+	Data struct {
+		List []struct {
+			ID              int    `json:"id"`
+			UserName        string `json:"user_name"`
+			CommentContent  string `json:"comment_content"`
+			CommentCtime    int64  `json:"comment_ctime"`
+			LikeCount       int    `json:"like_count"`
+			DiscussionCount int    `json:"discussion_count"`
+			Score           int64  `json:"score"`
+			Replies         []struct {
+				ID       int    `json:"id"`
+				UserName string `json:"user_name"`
+				Content  string `json:"content"`
+				Ctime    int64  `json:"ctime"`
+			} `json:"replies"`
+		} `json:"list"`
+		Page struct {
+			More  bool `json:"more"`
+			Count int  `json:"count"`
+		} `json:"page"`
+	} `json:"data"`
+	Error struct{} `json:"error"`
+	Extra struct {
+		Cost      float64 `json:"cost"`
+		RequestID string  `json:"request-id"`
+	} `json:"extra"`
+}
@@ -25,7 +25,7 @@ func (c *Client) UniversityClassInfo(classID int) (Course, error) {
 	var res response.V1MyClassInfoResponse
 	r := c.newRequest(
 		resty.MethodPost,
-		GeekBangUniversityBaseURL,
+		c.universityBaseURL,
 		UniversityV1MyClassInfoPath,
 		nil,
 		map[string]interface{}{
@@ -34,17 +34,12 @@ func (c *Client) UniversityClassInfo(classID int) (Course, error) {
 		&res,
 	)
 
-	resp, err := do(r)
-	if err != nil {
-		return p, err
-	}
-
-	if res.Code != 0 {
-		if res.Error.Code == -5001 {
+	if _, err := c.do(r); err != nil {
+		if IsNotPurchased(err) {
 			p.Access = false
 			return p, nil
 		}
-		return p, ErrGeekTimeAPIBadCode{UniversityV1MyClassInfoPath, resp.String()}
+		return p, err
 	}
 
 	p = Course{
@@ -73,7 +68,7 @@ func (c *Client) UniversityClassArticleDetail(classID, articleID int) (response.
 	var res response.V1MyClassArticleResponse
 	r := c.newRequest(
 		resty.MethodPost,
-		GeekBangUniversityBaseURL,
+		c.universityBaseURL,
 		UniversityV1MyClassArticlePath,
 		nil,
 		map[string]interface{}{
@@ -83,7 +78,7 @@ func (c *Client) UniversityClassArticleDetail(classID, articleID int) (response.
 		&res,
 	)
 
-	_, err := do(r)
+	_, err := c.do(r)
 
 	return res, err
 }
@@ -93,7 +88,7 @@ func (c *Client) UniversityVideoPlayAuth(articleID, classID int) (response.V1Vid
 	var res response.V1VideoPlayAuthResponse
 	r := c.newRequest(
 		resty.MethodPost,
-		GeekBangUniversityBaseURL,
+		c.universityBaseURL,
 		UniversityV1VideoPlayAuthPath,
 		nil,
 		map[string]interface{}{
@@ -102,7 +97,7 @@ func (c *Client) UniversityVideoPlayAuth(articleID, classID int) (response.V1Vid
 		},
 		&res,
 	)
-	if _, err := do(r); err != nil {
+	if _, err := c.do(r); err != nil {
 		return response.V1VideoPlayAuthResponse{}, err
 	}
 	return res, nil
@@ -0,0 +1,36 @@
+package markdown
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+)
+
+// commentTimeLayout is the time format of comments in markdown
+const commentTimeLayout = "2006-01-02 15:04"
+
+// commentsSection returns the 精选留言 section of comments, empty if there is no comment
+func commentsSection(comments []geektime.Comment) string {
+	if len(comments) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\n## 精选留言\n")
+	for _, c := range comments {
+		fmt.Fprintf(&b, "\n**%s** · %s · 赞 %d", c.Author, c.Time.Format(commentTimeLayout), c.Likes)
+		if c.Replies > 0 {
+			fmt.Fprintf(&b, " · %d 条回复", c.Replies)
+		}
+		b.WriteString("\n\n")
+		b.WriteString(strings.TrimSpace(c.Content))
+		b.WriteString("\n")
+		for _, r := range c.AuthorReplies {
+			fmt.Fprintf(&b, "\n> **作者回复** · %s\n>\n", r.Time.Format(commentTimeLayout))
+			for _, line := range strings.Split(strings.TrimSpace(r.Content), "\n") {
+				b.WriteString("> " + line + "\n")
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,56 @@
+package markdown
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Meta is the metadata of article written as YAML front matter of markdown
+type Meta struct {
+	ArticleID   int
+	ColumnID    int
+	ColumnTitle string
+	Section     string
+	Published   time.Time
+	Author      string
+	// AudioDuration is like 08:41, empty if article has no audio
+	AudioDuration string
+	SourceURL     string
+}
+
+// frontMatter returns YAML front matter of article, fields without value are left out
+func (m Meta) frontMatter(title string) string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	field := func(key, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "%s: %s\n", key, value)
+		}
+	}
+	// double quoted YAML string accepts the escapes of Go quoted string
+	quote := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return strconv.Quote(s)
+	}
+	field("title", quote(title))
+	if m.ArticleID != 0 {
+		field("article_id", strconv.Itoa(m.ArticleID))
+	}
+	if m.ColumnID != 0 {
+		field("column_id", strconv.Itoa(m.ColumnID))
+	}
+	field("column_title", quote(m.ColumnTitle))
+	field("section", quote(m.Section))
+	if !m.Published.IsZero() {
+		field("date", m.Published.Format(time.RFC3339))
+	}
+	field("author", quote(m.Author))
+	field("audio_duration", quote(m.AudioDuration))
+	field("source", quote(m.SourceURL))
+	b.WriteString("---\n\n")
+	return b.String()
+}
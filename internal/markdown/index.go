@@ -0,0 +1,54 @@
+package markdown
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/filenamify"
+)
+
+// IndexFileName is the name of column index file which links to all article markdown files
+const IndexFileName = "README.md"
+
+// WriteIndex writes index file of column into dir, articles are listed in column order under their section headings.
+// Only articles whose markdown file is in dir are listed, articles skipped or failed are not linked.
+func WriteIndex(course geektime.Course, dir string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", course.Title)
+	if course.Author != "" {
+		fmt.Fprintf(&b, "作者: %s\n\n", course.Author)
+	}
+	section := ""
+	for _, article := range course.Articles {
+		fileName := filenamify.Filenamify(article.Title) + MDExtension
+		if _, err := os.Stat(filepath.Join(dir, fileName)); err != nil {
+			continue
+		}
+		if article.SectionTitle != section {
+			section = article.SectionTitle
+			if section != "" {
+				fmt.Fprintf(&b, "\n## %s\n\n", section)
+			}
+		}
+		fmt.Fprintf(&b, "- [%s](%s)\n", escapeLinkText(article.Title), linkPath(fileName))
+	}
+
+	path := filepath.Join(dir, IndexFileName)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// linkPath escapes characters of relative file path which end or break markdown link destination
+func linkPath(p string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29", "<", "%3C", ">", "%3E").Replace(p)
+}
+
+func escapeLinkText(s string) string {
+	return strings.NewReplacer("[", `\[`, "]", `\]`).Replace(s)
+}
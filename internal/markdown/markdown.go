@@ -21,8 +21,11 @@ import (
 )
 
 var (
-	converter *md.Converter
-	imgRegexp = regexp.MustCompile(`!\[(.*?)]\((.*?)\)`)
+	converter     *md.Converter
+	converterOnce sync.Once
+	imgRegexp     = regexp.MustCompile(`!\[(.*?)]\((.*?)\)`)
+	// imageLocks holds a lock of each image path, PDF and text stages of an article download its images concurrently
+	imageLocks sync.Map
 )
 
 // MDExtension ...
@@ -39,8 +42,10 @@ func (ms *markdownString) ReplaceAll(o, n string) {
 	ms.s = strings.ReplaceAll(ms.s, o, n)
 }
 
-// Download article as markdown
-func Download(ctx context.Context, html, title, dir string, aid int) error {
+// Download article as markdown with meta as front matter, comments are appended as 精选留言 section.
+// origin is the Origin header of image requests.
+func Download(ctx context.Context, origin, html, title, dir string, meta Meta, comments []geektime.Comment) error {
+	aid := meta.ArticleID
 	logger.Infof("Begin download article markdown, articleID: %d, title: %s", aid, title)
 
 	select {
@@ -71,7 +76,7 @@ func Download(ctx context.Context, html, title, dir string, aid int) error {
 		}
 	}
 
-	err = writeImageFile(ctx, imageURLs, dir, imagesFolder, ss)
+	err = writeImageFile(ctx, origin, imageURLs, dir, imagesFolder, ss)
 	if err != nil {
 		logger.Errorf(err, "Failed to download article images, articleID: %d, title: %s, imagesURLs: %v", aid, title, imageURLs)
 		return err
@@ -85,7 +90,7 @@ func Download(ctx context.Context, html, title, dir string, aid int) error {
 		return err
 	}
 	// step3: write md file
-	_, err = f.WriteString("# " + title + "\n" + ss.s)
+	_, err = f.WriteString(meta.frontMatter(title) + "# " + title + "\n" + ss.s + commentsSection(comments))
 	if err != nil {
 		return err
 	}
@@ -107,19 +112,40 @@ func findAllImages(md string) (images []string) {
 	return
 }
 
+// getDefaultConverter returns converter shared by articles converted at the same time
 func getDefaultConverter() *md.Converter {
-	if converter == nil {
+	converterOnce.Do(func() {
 		converter = md.NewConverter("", true, nil)
-	}
+	})
 	return converter
 }
 
 func writeImageFile(ctx context.Context,
+	origin string,
 	imageURLs []string,
 	dir,
 	imagesFolder string,
 	ms *markdownString,
 ) (err error) {
+	localPaths, err := DownloadImages(ctx, origin, imageURLs, imagesFolder)
+	if err != nil {
+		return err
+	}
+	for _, imageURL := range imageURLs {
+		rel, _ := filepath.Rel(dir, localPaths[imageURL])
+		ms.ReplaceAll(imageURL, filepath.ToSlash(rel))
+	}
+	return nil
+}
+
+// DownloadImages downloads images into imagesFolder with Origin header origin, returns local file path by image url
+func DownloadImages(ctx context.Context, origin string, imageURLs []string, imagesFolder string) (map[string]string, error) {
+	localPaths := make(map[string]string, len(imageURLs))
+	if len(imageURLs) > 0 {
+		if err := os.MkdirAll(imagesFolder, os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
 	for _, imageURL := range imageURLs {
 		segments := strings.Split(imageURL, "/")
 		f := segments[len(segments)-1]
@@ -127,20 +153,40 @@ func writeImageFile(ctx context.Context,
 			f = f[:i]
 		}
 		imageLocalFullPath := filepath.Join(imagesFolder, f)
+		if err := downloadImage(ctx, origin, imageURL, imageLocalFullPath); err != nil {
+			return nil, err
+		}
+		localPaths[imageURL] = imageLocalFullPath
+	}
+	return localPaths, nil
+}
 
-		headers := make(map[string]string, 2)
-		headers[geektime.Origin] = geektime.DefaultBaseURL
-		headers[geektime.UserAgent] = geektime.DefaultUserAgent
+// downloadImage downloads image once, image is written to a temp file first and renamed when it's complete,
+// so existing image is complete and reused.
+func downloadImage(ctx context.Context, origin, imageURL, fullPath string) error {
+	v, _ := imageLocks.LoadOrStore(fullPath, &sync.Mutex{})
+	mu := v.(*sync.Mutex)
+	mu.Lock()
+	defer mu.Unlock()
 
-		_, err := downloader.DownloadFileConcurrently(ctx, imageLocalFullPath, imageURL, headers, 1)
-		if err != nil {
-			return err
-		}
+	if _, err := os.Stat(fullPath); err == nil {
+		return nil
+	}
+	headers := make(map[string]string, 2)
+	headers[geektime.Origin] = origin
+	headers[geektime.UserAgent] = geektime.DefaultUserAgent
 
-		rel, _ := filepath.Rel(dir, imageLocalFullPath)
-		ms.ReplaceAll(imageURL, filepath.ToSlash(rel))
+	tmp := fullPath + ".tmp"
+	if _, err := downloader.DownloadFileConcurrently(ctx, tmp, imageURL, headers, 1); err != nil {
+		return err
 	}
-	return nil
+	return os.Rename(tmp, fullPath)
+}
+
+// IsImageURL reports whether url points to an image file by its extension
+func IsImageURL(urlStr string) bool {
+	isImg, err := isImageURL(urlStr)
+	return err == nil && isImg
 }
 
 func isImageURL(urlStr string) (bool, error) {
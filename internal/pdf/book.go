@@ -0,0 +1,99 @@
+package pdf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/filenamify"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/logger"
+	pw "github.com/nicoxiang/geektime-downloader/internal/pkg/pdfwriter"
+)
+
+// ArticlePDFPath returns the PDF file path of article in column dir
+func ArticlePDFPath(columnDir string, article geektime.Article) string {
+	return filepath.Join(columnDir, filenamify.Filenamify(article.Title)+PDFExtension)
+}
+
+// MergeColumnPDF merges PDF files of column articles in column order into one book at dst,
+// with a title page, bookmarks of articles grouped by section and page numbers.
+// Articles without PDF file are left out, it returns the merged articles.
+func MergeColumnPDF(course geektime.Course, columnDir, dst string) ([]geektime.Article, error) {
+	logger.Infof("Begin merge column pdf, columnID: %d, pdfFileName: %s", course.ID, dst)
+	m := pw.NewMerger()
+	m.Title = course.Title
+	m.Author = course.Author
+	m.PageNumbers = true
+	if _, err := m.AppendDocument(titlePage(course)); err != nil {
+		return nil, err
+	}
+	m.FirstNumberedPage = m.PageCount()
+
+	var merged []geektime.Article
+	var section *pw.Outline
+	for _, article := range course.Articles {
+		data, err := os.ReadFile(ArticlePDFPath(columnDir, article))
+		if os.IsNotExist(err) {
+			logger.Warnf("Article pdf not found when merging column pdf, articleID: %d, title: %s", article.AID, article.Title)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		page, err := m.Append(data)
+		if err != nil {
+			logger.Errorf(err, "Failed to merge article pdf, articleID: %d, title: %s", article.AID, article.Title)
+			return nil, err
+		}
+		merged = append(merged, article)
+
+		item := &pw.Outline{Title: article.Title, Page: page}
+		if article.SectionTitle == "" {
+			section = nil
+			m.Outlines = append(m.Outlines, item)
+			continue
+		}
+		if section == nil || section.Title != article.SectionTitle {
+			section = &pw.Outline{Title: article.SectionTitle, Page: page}
+			m.Outlines = append(m.Outlines, section)
+		}
+		section.Children = append(section.Children, item)
+	}
+	if len(merged) == 0 {
+		return nil, pw.ErrNoPages
+	}
+
+	// write to temp file first so that a broken file never has the final name
+	tmp := dst + ".tmp"
+	if err := m.WriteFile(tmp); err != nil {
+		_ = os.Remove(tmp)
+		return nil, err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return nil, err
+	}
+	logger.Infof("Finish merge column pdf, columnID: %d, pages: %d", course.ID, m.PageCount())
+	return merged, nil
+}
+
+// titlePage creates the first page of column book
+func titlePage(course geektime.Course) *pw.Document {
+	doc := pw.New()
+	p := doc.AddPage()
+	center := func(y float64, text string, font pw.Font, size float64, color pw.Color) {
+		// long title is scaled down to fit page width
+		if w := pw.TextWidth(text, font, size); w > contentWidth {
+			size = size * contentWidth / w
+		}
+		w := pw.TextWidth(text, font, size)
+		p.Text((pw.PageWidth-w)/2, y, text, font, size, color)
+	}
+	center(300, course.Title, pw.FontBold, 28, stylesheet["title"].color)
+	if course.Author != "" {
+		center(350, course.Author, pw.FontRegular, 14, stylesheet["p"].color)
+	}
+	p.Line(marginX*2, 380, pw.PageWidth-marginX*2, 380, 0.5, borderColor)
+	center(410, fmt.Sprintf("共 %d 讲", len(course.Articles)), pw.FontRegular, 11, footerColor)
+	return doc
+}
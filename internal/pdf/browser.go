@@ -0,0 +1,97 @@
+package pdf
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/chromedp/chromedp"
+
+	"github.com/nicoxiang/geektime-downloader/internal/config"
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+)
+
+// Browser prints article pages in a pool of tabs of one Chrome,
+// instead of starting Chrome for every article like PrintArticlePageToPDF.
+type Browser struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	start    sync.Once
+	startErr error
+
+	// tabs holds idle tabs, nil means the tab is not opened yet, or closed after a failed print
+	tabs chan *tab
+}
+
+type tab struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewBrowser returns Browser with at most tabs pages printed at the same time,
+// Chrome is started when the first page is printed.
+func NewBrowser(parentCtx context.Context, tabs int) *Browser {
+	if tabs <= 0 {
+		tabs = 1
+	}
+	ctx, cancel := chromedp.NewContext(parentCtx)
+	b := &Browser{ctx: ctx, cancel: cancel, tabs: make(chan *tab, tabs)}
+	for i := 0; i < tabs; i++ {
+		b.tabs <- nil
+	}
+	return b
+}
+
+// PrintArticlePageToPDF prints article page in an idle tab, it waits until a tab is idle.
+// Page is loaded again after backoff if it hits rate limit.
+func (b *Browser) PrintArticlePageToPDF(ctx context.Context,
+	baseURL string,
+	article geektime.Article,
+	dir string,
+	cookies []*http.Cookie,
+	cfg *config.AppConfig,
+) (err error) {
+	var t *tab
+	select {
+	case t = <-b.tabs:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() {
+		// page of failed print may be still loading, next print uses a new tab
+		if err != nil && t != nil {
+			t.cancel()
+			t = nil
+		}
+		b.tabs <- t
+	}()
+
+	if t == nil {
+		if t, err = b.openTab(); err != nil {
+			return err
+		}
+	}
+	return printArticlePage(ctx, t.ctx, baseURL, article, dir, cookies, cfg)
+}
+
+// Close closes Chrome and all its tabs
+func (b *Browser) Close() {
+	b.cancel()
+}
+
+func (b *Browser) openTab() (*tab, error) {
+	// tabs are opened in the browser of first context, so it must be started first
+	b.start.Do(func() {
+		b.startErr = chromedp.Run(b.ctx)
+	})
+	if b.startErr != nil {
+		return nil, b.startErr
+	}
+	ctx, cancel := chromedp.NewContext(b.ctx)
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+	return &tab{ctx: ctx, cancel: cancel}, nil
+}
@@ -0,0 +1,762 @@
+package pdf
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+	"github.com/nicoxiang/geektime-downloader/internal/markdown"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/logger"
+	pw "github.com/nicoxiang/geektime-downloader/internal/pkg/pdfwriter"
+)
+
+// PDF engines
+const (
+	// EngineChrome prints the article page in local Chrome
+	EngineChrome = "chrome"
+	// EngineNative renders article content from api in pure Go
+	EngineNative = "native"
+)
+
+// page margins in points
+const (
+	marginX      = 50.0
+	marginTop    = 56.0
+	marginBottom = 56.0
+	contentWidth = pw.PageWidth - 2*marginX
+	// pxToPt converts image pixel size at 96 dpi into points
+	pxToPt = 0.75
+)
+
+// blockStyle is the style of a block element
+type blockStyle struct {
+	font       pw.Font
+	size       float64
+	lineHeight float64
+	color      pw.Color
+	// spacing before and after block
+	marginTop    float64
+	marginBottom float64
+}
+
+// stylesheet is the bundled style of article elements
+var stylesheet = map[string]blockStyle{
+	"title":      {pw.FontBold, 22, 1.4, pw.RGB(0x222222), 0, 18},
+	"h1":         {pw.FontBold, 19, 1.4, pw.RGB(0x222222), 16, 10},
+	"h2":         {pw.FontBold, 17, 1.4, pw.RGB(0x222222), 14, 8},
+	"h3":         {pw.FontBold, 15, 1.4, pw.RGB(0x222222), 12, 6},
+	"h4":         {pw.FontBold, 13, 1.4, pw.RGB(0x333333), 10, 6},
+	"h5":         {pw.FontBold, 12, 1.4, pw.RGB(0x333333), 8, 4},
+	"h6":         {pw.FontBold, 11, 1.4, pw.RGB(0x555555), 8, 4},
+	"p":          {pw.FontRegular, 11, 1.75, pw.RGB(0x353535), 0, 10},
+	"blockquote": {pw.FontRegular, 11, 1.75, pw.RGB(0x666666), 0, 10},
+	"pre":        {pw.FontMono, 9, 1.5, pw.RGB(0x24292E), 2, 12},
+	"table":      {pw.FontRegular, 9.5, 1.5, pw.RGB(0x353535), 2, 12},
+	"caption":    {pw.FontItalic, 9.5, 1.5, pw.RGB(0x888888), 0, 10},
+}
+
+// colors of decorations
+var (
+	linkColor       = pw.RGB(0x1A73E8)
+	codeColor       = pw.RGB(0xC7254E)
+	codeBackground  = pw.RGB(0xF6F8FA)
+	quoteBarColor   = pw.RGB(0xDDDDDD)
+	borderColor     = pw.RGB(0xCCCCCC)
+	headerBackgound = pw.RGB(0xF2F2F2)
+	footerColor     = pw.RGB(0x999999)
+)
+
+// RenderArticleToPDF renders article content html from api into PDF without Chrome,
+// images are downloaded into images/aid of dir, the same folder used by markdown output.
+// origin is the Origin header of image requests.
+func RenderArticleToPDF(ctx context.Context, origin string, article geektime.Article, content, dir string) error {
+	pdfFileName := ArticlePDFPath(dir, article)
+	logger.Infof("Begin render article pdf, articleID: %d, pdfFileName: %s", article.AID, pdfFileName)
+
+	doc := pw.New()
+	doc.Title = article.Title
+	r := newRenderer(ctx, origin, doc, dir)
+	if err := r.article(article, content); err != nil {
+		logger.Errorf(err, "Failed to render article pdf, articleID: %d", article.AID)
+		return err
+	}
+	r.pageNumbers()
+
+	// write to temp file first so that a broken file never has the final name
+	tmp := pdfFileName + ".tmp"
+	if err := doc.WriteFile(tmp); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	logger.Infof("Finish render article pdf, articleID: %d, pdfFileName: %s", article.AID, pdfFileName)
+	return os.Rename(tmp, pdfFileName)
+}
+
+// renderer lays out html blocks onto pages from top to bottom
+type renderer struct {
+	ctx    context.Context
+	origin string
+	doc    *pw.Document
+	dir    string
+	aid    int
+
+	pages []*pw.Page
+	page  *pw.Page
+	// y is the top of free space on current page
+	y float64
+
+	// indent of current block from left margin
+	indent float64
+	// quoteDepth is the number of blockquote levels, a bar is drawn for each level
+	quoteDepth int
+	// marker is drawn on the first line of next block, like list bullet
+	marker func(page *pw.Page, baseline float64, size float64)
+}
+
+func newRenderer(ctx context.Context, origin string, doc *pw.Document, dir string) *renderer {
+	return &renderer{ctx: ctx, origin: origin, doc: doc, dir: dir}
+}
+
+// article renders article title and content starting from a new page
+func (r *renderer) article(article geektime.Article, content string) error {
+	r.aid = article.AID
+	r.newPage()
+	title := stylesheet["title"]
+	r.paragraph([]span{{text: article.Title, style: inlineStyle{font: title.font, color: title.color}}}, title)
+
+	nodes, err := html.ParseFragment(strings.NewReader(content), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return err
+	}
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	for _, n := range nodes {
+		body.AppendChild(n)
+	}
+	return r.blocks(body, stylesheet["p"])
+}
+
+func (r *renderer) newPage() {
+	r.page = r.doc.AddPage()
+	r.pages = append(r.pages, r.page)
+	r.y = marginTop
+}
+
+// ensure starts a new page if there is no space of height h, unless current page is empty
+func (r *renderer) ensure(h float64) {
+	if r.y+h > pw.PageHeight-marginBottom && r.y > marginTop {
+		r.newPage()
+	}
+}
+
+// space adds vertical space, it's not carried to next page
+func (r *renderer) space(h float64) {
+	if r.y > marginTop {
+		r.y += h
+	}
+}
+
+// pageNumbers draws page number in footer of every page
+func (r *renderer) pageNumbers() {
+	for i, p := range r.pages {
+		s := strconv.Itoa(i+1) + " / " + strconv.Itoa(len(r.pages))
+		w := pw.TextWidth(s, pw.FontRegular, 8)
+		p.Text((pw.PageWidth-w)/2, pw.PageHeight-marginBottom/2, s, pw.FontRegular, 8, footerColor)
+	}
+}
+
+// isBlock reports whether element starts a new block
+func isBlock(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	switch n.DataAtom {
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Header, atom.Footer, atom.Main, atom.Aside, atom.Nav,
+		atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6,
+		atom.Pre, atom.Ul, atom.Ol, atom.Li, atom.Dl, atom.Dt, atom.Dd, atom.Blockquote,
+		atom.Table, atom.Hr, atom.Figure, atom.Figcaption, atom.Img:
+		return true
+	}
+	return false
+}
+
+// blocks renders children of n, consecutive inline children make up one paragraph
+func (r *renderer) blocks(n *html.Node, style blockStyle) error {
+	var spans []span
+	flush := func() {
+		if len(spans) > 0 {
+			r.paragraph(spans, style)
+			spans = nil
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
+		if !isBlock(c) {
+			collectSpans(c, inlineStyle{font: style.font, color: style.color}, &spans)
+			continue
+		}
+		flush()
+		if err := r.block(c, style); err != nil {
+			return err
+		}
+	}
+	flush()
+	return nil
+}
+
+// block renders a block element
+func (r *renderer) block(n *html.Node, parent blockStyle) error {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		style := stylesheet[n.Data]
+		r.space(style.marginTop)
+		var spans []span
+		collectSpans(n, inlineStyle{font: style.font, color: style.color}, &spans)
+		// keep heading with the first line of next block
+		r.ensure(style.size*style.lineHeight + parent.size*parent.lineHeight*2)
+		r.paragraph(spans, style)
+	case atom.Pre:
+		r.pre(textContent(n), stylesheet["pre"])
+	case atom.Ul, atom.Ol:
+		return r.list(n, parent)
+	case atom.Li, atom.Dd:
+		r.indent += 18
+		defer func() { r.indent -= 18 }()
+		return r.blocks(n, parent)
+	case atom.Blockquote:
+		style := stylesheet["blockquote"]
+		r.indent += 14
+		r.quoteDepth++
+		defer func() {
+			r.indent -= 14
+			r.quoteDepth--
+		}()
+		return r.blocks(n, style)
+	case atom.Table:
+		r.table(n)
+	case atom.Hr:
+		r.space(6)
+		r.ensure(12)
+		r.page.Line(marginX+r.indent, r.y, marginX+contentWidth, r.y, 0.5, borderColor)
+		r.y += 12
+	case atom.Img:
+		r.image(attr(n, "src"), attr(n, "alt"))
+	case atom.Figcaption:
+		return r.blocks(n, stylesheet["caption"])
+	default:
+		return r.blocks(n, parent)
+	}
+	return nil
+}
+
+// list renders items of ul or ol with bullet or number marker
+func (r *renderer) list(n *html.Node, style blockStyle) error {
+	number := 1
+	if start, err := strconv.Atoi(attr(n, "start")); err == nil {
+		number = start
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.DataAtom != atom.Li {
+			continue
+		}
+		x := marginX + r.indent
+		if n.DataAtom == atom.Ol {
+			label := strconv.Itoa(number) + "."
+			r.marker = func(page *pw.Page, baseline, size float64) {
+				page.Text(x, baseline, label, pw.FontRegular, size, style.color)
+			}
+			number++
+		} else {
+			r.marker = func(page *pw.Page, baseline, size float64) {
+				page.Rect(x+3, baseline-size*0.38, size*0.3, size*0.3, style.color)
+			}
+		}
+		if err := r.block(c, style); err != nil {
+			return err
+		}
+		r.marker = nil
+	}
+	return nil
+}
+
+// decorate draws marker and quote bars of a line, top and height are the line box
+func (r *renderer) decorate(top, height, baseline, size float64) {
+	if r.marker != nil {
+		r.marker(r.page, baseline, size)
+		r.marker = nil
+	}
+	for i := 0; i < r.quoteDepth; i++ {
+		r.page.Rect(marginX+r.indent-float64(r.quoteDepth-i)*14, top, 3, height, quoteBarColor)
+	}
+}
+
+// paragraph lays out spans into lines, images in spans are rendered as blocks
+func (r *renderer) paragraph(spans []span, style blockStyle) {
+	var text []span
+	rendered := false
+	flush := func() {
+		lines := wrap(tokenize(text), contentWidth-r.indent, style.size)
+		text = nil
+		if len(lines) == 0 {
+			return
+		}
+		rendered = true
+		lineHeight := style.size * style.lineHeight
+		for _, line := range lines {
+			r.ensure(lineHeight)
+			baseline := r.y + (lineHeight+style.size*0.7)/2
+			r.decorate(r.y, lineHeight, baseline, style.size)
+			r.drawLine(line, marginX+r.indent, baseline, style.size)
+			r.y += lineHeight
+		}
+	}
+	for _, s := range spans {
+		if s.image != "" {
+			flush()
+			r.image(s.image, s.text)
+			rendered = true
+			continue
+		}
+		text = append(text, s)
+	}
+	flush()
+	if rendered {
+		r.space(style.marginBottom)
+	}
+}
+
+// drawLine draws tokens of a line, tokens of the same style are drawn together
+func (r *renderer) drawLine(line []token, x, baseline, size float64) {
+	var run strings.Builder
+	var runStyle inlineStyle
+	runX, cx := x, x
+	draw := func() {
+		if run.Len() == 0 {
+			return
+		}
+		s := run.String()
+		w := pw.TextWidth(s, runStyle.font, size)
+		if runStyle.code {
+			r.page.Rect(runX-1, baseline-size*0.85, w+2, size*1.15, codeBackground)
+		}
+		r.page.Text(runX, baseline, s, runStyle.font, size, runStyle.color)
+		if runStyle.href != "" {
+			r.page.Line(runX, baseline+1.5, runX+w, baseline+1.5, 0.4, runStyle.color)
+			r.page.Link(runX, baseline-size, w, size*1.3, runStyle.href)
+		}
+		run.Reset()
+	}
+	for i, t := range line {
+		if t.style != runStyle || run.Len() == 0 {
+			draw()
+			runStyle = t.style
+			if t.space && i > 0 {
+				cx += pw.RuneWidth(' ', t.style.font, size)
+			}
+			runX = cx
+		} else if t.space && i > 0 {
+			run.WriteByte(' ')
+			cx += pw.RuneWidth(' ', t.style.font, size)
+		}
+		run.WriteString(t.text)
+		cx += t.width
+	}
+	draw()
+}
+
+// pre renders preformatted code, long lines are wrapped by character
+func (r *renderer) pre(code string, style blockStyle) {
+	code = strings.TrimRight(strings.ReplaceAll(code, "\r\n", "\n"), "\n")
+	lineHeight := style.size * style.lineHeight
+	width := contentWidth - r.indent
+	padding := 8.0
+	r.space(style.marginTop)
+	r.ensure(lineHeight + padding*2)
+
+	x := marginX + r.indent
+	r.page.Rect(x, r.y, width, padding, codeBackground)
+	r.y += padding
+	for _, line := range strings.Split(code, "\n") {
+		for _, l := range wrapRunes(expandTabs(line), width-padding*2, style.font, style.size) {
+			if r.y+lineHeight > pw.PageHeight-marginBottom {
+				r.newPage()
+			}
+			r.page.Rect(x, r.y, width, lineHeight, codeBackground)
+			baseline := r.y + (lineHeight+style.size*0.7)/2
+			r.decorate(r.y, lineHeight, baseline, style.size)
+			r.page.Text(x+padding, baseline, l, style.font, style.size, style.color)
+			r.y += lineHeight
+		}
+	}
+	r.page.Rect(x, r.y, width, padding, codeBackground)
+	r.y += padding
+	r.space(style.marginBottom)
+}
+
+// table renders rows with equal width columns, a row is never split into pages
+func (r *renderer) table(n *html.Node) {
+	style := stylesheet["table"]
+	var rows [][]*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			switch c.DataAtom {
+			case atom.Tr:
+				var cells []*html.Node
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.DataAtom == atom.Td || cell.DataAtom == atom.Th {
+						cells = append(cells, cell)
+					}
+				}
+				rows = append(rows, cells)
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+
+	columns := 0
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+	if columns == 0 {
+		return
+	}
+	padding := 4.0
+	width := contentWidth - r.indent
+	cellWidth := width / float64(columns)
+	lineHeight := style.size * style.lineHeight
+	r.space(style.marginTop)
+
+	for _, row := range rows {
+		cellLines := make([][][]token, len(row))
+		height := 0.0
+		for i, cell := range row {
+			font := style.font
+			if cell.DataAtom == atom.Th {
+				font = pw.FontBold
+			}
+			var spans []span
+			collectSpans(cell, inlineStyle{font: font, color: style.color}, &spans)
+			cellLines[i] = wrap(tokenize(spans), cellWidth-padding*2, style.size)
+			if h := float64(len(cellLines[i]))*lineHeight + padding*2; h > height {
+				height = h
+			}
+		}
+		if height == 0 {
+			continue
+		}
+		r.ensure(height)
+		x := marginX + r.indent
+		for i, cell := range row {
+			cx := x + float64(i)*cellWidth
+			if cell.DataAtom == atom.Th {
+				r.page.Rect(cx, r.y, cellWidth, height, headerBackgound)
+			}
+			for j, line := range cellLines[i] {
+				baseline := r.y + padding + float64(j)*lineHeight + (lineHeight+style.size*0.7)/2
+				r.drawLine(line, cx+padding, baseline, style.size)
+			}
+		}
+		// borders
+		r.page.Line(x, r.y, x+width, r.y, 0.5, borderColor)
+		r.page.Line(x, r.y+height, x+width, r.y+height, 0.5, borderColor)
+		for i := 0; i <= columns; i++ {
+			cx := x + float64(i)*cellWidth
+			r.page.Line(cx, r.y, cx, r.y+height, 0.5, borderColor)
+		}
+		r.y += height
+	}
+	r.space(style.marginBottom)
+}
+
+// image downloads and draws image scaled to fit content width, alt text is drawn if it fails
+func (r *renderer) image(src, alt string) {
+	if !markdown.IsImageURL(src) {
+		return
+	}
+	img, err := r.loadImage(src)
+	if err != nil {
+		logger.Warnf("Failed to render image in article pdf, articleID: %d, image: %s, err: %v", r.aid, src, err)
+		if alt == "" {
+			alt = "图片"
+		}
+		r.paragraph([]span{{text: "[" + alt + "]", style: inlineStyle{font: pw.FontItalic, color: footerColor}}}, stylesheet["caption"])
+		return
+	}
+
+	maxWidth := contentWidth - r.indent
+	maxHeight := pw.PageHeight - marginTop - marginBottom
+	w, h := float64(img.Width)*pxToPt, float64(img.Height)*pxToPt
+	if w > maxWidth {
+		w, h = maxWidth, h*maxWidth/w
+	}
+	if h > maxHeight {
+		w, h = w*maxHeight/h, maxHeight
+	}
+	r.space(4)
+	r.ensure(h)
+	r.page.Image(img, marginX+r.indent+(maxWidth-w)/2, r.y, w, h)
+	r.y += h
+	r.space(8)
+}
+
+func (r *renderer) loadImage(src string) (*pw.Image, error) {
+	imagesFolder := filepath.Join(r.dir, "images", strconv.Itoa(r.aid))
+	if err := os.MkdirAll(imagesFolder, os.ModePerm); err != nil {
+		return nil, err
+	}
+	localPaths, err := markdown.DownloadImages(r.ctx, r.origin, []string{src}, imagesFolder)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(localPaths[src])
+	if err != nil {
+		return nil, err
+	}
+	return r.doc.AddImage(data)
+}
+
+// inlineStyle is the style of text run
+type inlineStyle struct {
+	font  pw.Font
+	color pw.Color
+	href  string
+	code  bool
+}
+
+// span is text of an inline element, or an image
+type span struct {
+	text  string
+	style inlineStyle
+	// image is the src of img element
+	image string
+	// pre keeps whitespaces
+	pre bool
+}
+
+// collectSpans collects text of inline element n with its style
+func collectSpans(n *html.Node, style inlineStyle, spans *[]span) {
+	switch n.Type {
+	case html.TextNode:
+		*spans = append(*spans, span{text: n.Data, style: style})
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+	switch n.DataAtom {
+	case atom.Script, atom.Style:
+		return
+	case atom.Br:
+		*spans = append(*spans, span{text: "\n", style: style, pre: true})
+		return
+	case atom.Img:
+		*spans = append(*spans, span{image: attr(n, "src"), text: attr(n, "alt")})
+		return
+	case atom.Strong, atom.B, atom.Th:
+		style.font = pw.FontBold
+	case atom.Em, atom.I, atom.Cite:
+		if style.font != pw.FontBold {
+			style.font = pw.FontItalic
+		}
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		style.font, style.color, style.code = pw.FontMono, codeColor, true
+	case atom.A:
+		if href := attr(n, "href"); strings.HasPrefix(href, "http") {
+			style.href, style.color = href, linkColor
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && isBlock(c) && c.DataAtom != atom.Img {
+			// block inside inline element, like div in a table cell, starts a new line
+			*spans = append(*spans, span{text: "\n", style: style, pre: true})
+		}
+		collectSpans(c, style, spans)
+	}
+}
+
+// token is an unbreakable piece of text
+type token struct {
+	text  string
+	style inlineStyle
+	width float64
+	// space means there is a space before token
+	space bool
+	// newline means a forced line break before token
+	newline bool
+}
+
+// characters which can not start a line
+const noLineStart = "，。、；：？！）》」』】〉”’,.;:?!)]}…%"
+
+// characters which can not end a line
+const noLineEnd = "（《「『【〈“‘([{"
+
+// tokenize splits spans into tokens, every CJK character is a token and ASCII words are tokens
+func tokenize(spans []span) []token {
+	var tokens []token
+	var word *token
+	// space and newline apply to the next token, glue means next character sticks to word
+	space, newline, glue := false, false, false
+	flushWord := func() {
+		if word != nil {
+			tokens = append(tokens, *word)
+			word = nil
+		}
+	}
+	for _, s := range spans {
+		if s.pre && s.text == "\n" {
+			flushWord()
+			newline, space, glue = true, false, false
+			continue
+		}
+		for _, c := range normalize(s.text) {
+			if c == ' ' || c == '\n' || c == '\t' || c == '\r' {
+				flushWord()
+				space = !newline && len(tokens) > 0
+				glue = false
+				continue
+			}
+			ascii := c < utf8.RuneSelf
+			opening := strings.ContainsRune(noLineEnd, c)
+			switch {
+			case strings.ContainsRune(noLineStart, c) && !space && !newline && word != nil:
+				// punctuation never starts a line
+				word.text += string(c)
+			case strings.ContainsRune(noLineStart, c) && !space && !newline && len(tokens) > 0:
+				tokens[len(tokens)-1].text += string(c)
+			case word != nil && word.style == s.style && (glue || ascii && endsWithASCII(word.text)):
+				word.text += string(c)
+			default:
+				flushWord()
+				word = &token{text: string(c), style: s.style, space: space, newline: newline}
+				space, newline = false, false
+			}
+			glue = opening
+			// CJK character can be followed by a line break
+			if !ascii && !opening && word != nil {
+				flushWord()
+			}
+		}
+	}
+	flushWord()
+	return tokens
+}
+
+func endsWithASCII(s string) bool {
+	return len(s) > 0 && s[len(s)-1] < utf8.RuneSelf
+}
+
+// normalize removes characters which have zero width and replaces no-break space
+func normalize(s string) string {
+	return strings.NewReplacer("\u00a0", " ", "\u200b", "", "\u200d", "", "\ufeff", "").Replace(s)
+}
+
+// wrap breaks tokens into lines not wider than width, too long token is broken by character
+func wrap(tokens []token, width, size float64) [][]token {
+	var lines [][]token
+	var line []token
+	lineWidth := 0.0
+	for _, t := range tokens {
+		t.width = pw.TextWidth(t.text, t.style.font, size)
+		if t.newline && len(line) > 0 {
+			lines = append(lines, line)
+			line, lineWidth = nil, 0
+		}
+		spaceWidth := 0.0
+		if t.space && len(line) > 0 {
+			spaceWidth = pw.RuneWidth(' ', t.style.font, size)
+		}
+		if lineWidth+spaceWidth+t.width > width && len(line) > 0 {
+			lines = append(lines, line)
+			line, lineWidth, spaceWidth = nil, 0, 0
+		}
+		if t.width > width {
+			// long word like url
+			for _, part := range wrapRunes(t.text, width, t.style.font, size) {
+				if len(line) > 0 {
+					lines = append(lines, line)
+				}
+				p := t
+				p.text, p.width, p.space = part, pw.TextWidth(part, t.style.font, size), false
+				line, lineWidth = []token{p}, p.width
+			}
+			continue
+		}
+		line = append(line, t)
+		lineWidth += spaceWidth + t.width
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// wrapRunes breaks text by character into lines not wider than width
+func wrapRunes(text string, width float64, font pw.Font, size float64) []string {
+	var lines []string
+	var b strings.Builder
+	w := 0.0
+	for _, c := range text {
+		cw := pw.RuneWidth(c, font, size)
+		if w+cw > width && b.Len() > 0 {
+			lines = append(lines, b.String())
+			b.Reset()
+			w = 0
+		}
+		b.WriteRune(c)
+		w += cw
+	}
+	return append(lines, b.String())
+}
+
+func expandTabs(s string) string {
+	return strings.ReplaceAll(normalize(s), "\t", "    ")
+}
+
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			b.WriteString(n.Data)
+		case n.DataAtom == atom.Br:
+			b.WriteString("\n")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		// code highlighters put every line in its own block element
+		if isBlock(n) && n.NextSibling != nil && n.Parent != nil && n.Parent.DataAtom != atom.Pre {
+			b.WriteString("\n")
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,87 @@
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/filenamify"
+)
+
+func TestRenderArticleToPDF(t *testing.T) {
+	dir := t.TempDir()
+	content := `<h2>一、标题</h2><p>正文 <strong>粗体</strong> 和 <code>inline code</code>，以及<a href="https://time.geekbang.org">链接</a>。</p>
+<pre><code class="language-go">func main() {
+	fmt.Println("你好")
+}
+</code></pre>
+<ul><li>第一项</li><li>第二项<ol><li>嵌套</li></ol></li></ul>
+<blockquote><p>引用的内容</p></blockquote>
+<table><thead><tr><th>名称</th><th>说明</th></tr></thead><tbody><tr><td>a</td><td>很长的说明文字会在单元格内换行</td></tr></tbody></table>
+<hr><p><img src="data:image/png;base64,xx" alt="图片"></p>`
+	for i := 0; i < 30; i++ {
+		content += "<p>重复的段落用来测试分页，The quick brown fox jumps over the lazy dog.</p>"
+	}
+	article := geektime.Article{AID: 1, Title: "开篇词 | 测试(Test)"}
+	if err := RenderArticleToPDF(context.Background(), geektime.DefaultBaseURL, article, content, dir); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, filenamify.Filenamify(article.Title)+PDFExtension))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-1.7")) {
+		t.Fatal("missing pdf header")
+	}
+
+	// every offset in xref table points to its object
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(data)
+	if m == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(data[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point to xref", xref)
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[xref:], -1)
+	for i, e := range entries {
+		offset, _ := strconv.Atoi(string(e[1]))
+		if want := fmt.Sprintf("%d 0 obj", i+1); !bytes.HasPrefix(data[offset:], []byte(want)) {
+			t.Fatalf("xref entry %d points to %q", i+1, data[offset:offset+10])
+		}
+	}
+	if pages := bytes.Count(data, []byte("/Type /Page ")); pages < 2 {
+		t.Errorf("expected content to break into pages, got %d page", pages)
+	}
+	if !bytes.Contains(data, []byte("/URI (https://time.geekbang.org)")) {
+		t.Error("missing link annotation")
+	}
+}
+
+func TestWrap(t *testing.T) {
+	tokens := tokenize([]span{{text: "你好，世界（hello world）。"}})
+	var texts []string
+	for _, tk := range tokens {
+		texts = append(texts, tk.text)
+	}
+	want := []string{"你", "好，", "世", "界", "（hello", "world）。"}
+	if fmt.Sprint(texts) != fmt.Sprint(want) {
+		t.Fatalf("got tokens %q, want %q", texts, want)
+	}
+
+	lines := wrap(tokens, 40, 10)
+	for _, line := range lines {
+		if len(line) == 0 {
+			t.Fatal("empty line")
+		}
+	}
+	if first := lines[1][0].text; first == "，" || first == "。" {
+		t.Errorf("line starts with punctuation %s", first)
+	}
+}
@@ -22,8 +22,10 @@ import (
 
 	"github.com/nicoxiang/geektime-downloader/internal/config"
 	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+	"github.com/nicoxiang/geektime-downloader/internal/geektime/response"
 	"github.com/nicoxiang/geektime-downloader/internal/pkg/filenamify"
 	"github.com/nicoxiang/geektime-downloader/internal/pkg/logger"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/ratelimit"
 )
 
 // PDFExtension ...
@@ -36,23 +38,51 @@ const (
 	DownloadCommentsAll
 )
 
-type V4CommentListResponse struct {
-	Code int `json:"code"`
-	Data struct {
-		Page struct {
-			More  bool `json:"more"`
-			Count int  `json:"count"`
-		} `json:"page"`
-	} `json:"data"`
-	Error struct{} `json:"error"`
-	Extra struct {
-		Cost      float64 `json:"cost"`
-		RequestID string  `json:"request-id"`
-	} `json:"extra"`
+// pageLimiter and pageBackoff throttle article page loads of Chrome, nil doesn't limit
+var (
+	pageLimiter *ratelimit.Limiter
+	pageBackoff *ratelimit.Backoff
+)
+
+// SetRateLimit sets limiter of article page loads, and backoff when page load hits Geektime rate limit
+func SetRateLimit(l *ratelimit.Limiter, b *ratelimit.Backoff) {
+	pageLimiter, pageBackoff = l, b
 }
 
-// PrintArticlePageToPDF use chromedp to print article page and save
+// PrintArticlePageToPDF use chromedp to print article page of site baseURL and save,
+// page is loaded again after backoff if it hits rate limit
 func PrintArticlePageToPDF(parentCtx context.Context,
+	baseURL string,
+	article geektime.Article,
+	dir string,
+	cookies []*http.Cookie,
+	cfg *config.AppConfig,
+) error {
+	chromeCtx, chromeCancel := chromedp.NewContext(parentCtx)
+	defer chromeCancel()
+
+	return printArticlePage(parentCtx, chromeCtx, baseURL, article, dir, cookies, cfg)
+}
+
+// printArticlePage prints article page in Chrome tab of tabCtx, page is loaded again after backoff if it hits rate limit
+func printArticlePage(parentCtx, tabCtx context.Context,
+	baseURL string,
+	article geektime.Article,
+	dir string,
+	cookies []*http.Cookie,
+	cfg *config.AppConfig,
+) error {
+	return pageBackoff.Do(parentCtx, geektime.ErrGeekTimeRateLimit, func() error {
+		if err := pageLimiter.Wait(parentCtx); err != nil {
+			return err
+		}
+		return printArticlePageToPDF(parentCtx, tabCtx, baseURL, article, dir, cookies, cfg)
+	})
+}
+
+// printArticlePageToPDF prints article page once in Chrome tab of tabCtx, it stops when parentCtx is done
+func printArticlePageToPDF(parentCtx, tabCtx context.Context,
+	baseURL string,
 	article geektime.Article,
 	dir string,
 	cookies []*http.Cookie,
@@ -63,11 +93,11 @@ func PrintArticlePageToPDF(parentCtx context.Context,
 
 	pdfFileName := filepath.Join(dir, filenamify.Filenamify(article.Title)+PDFExtension)
 
-	chromeCtx, chromeCancel := chromedp.NewContext(parentCtx)
-	defer chromeCancel()
-
-	timeoutCtx, timeoutCancel := context.WithTimeout(chromeCtx, time.Duration(cfg.PrintPDFTimeoutSeconds)*time.Second)
+	timeoutCtx, timeoutCancel := context.WithTimeout(tabCtx, time.Duration(cfg.PrintPDFTimeoutSeconds)*time.Second)
 	defer timeoutCancel()
+	// tab of pool lives longer than parentCtx
+	stop := context.AfterFunc(parentCtx, timeoutCancel)
+	defer stop()
 
 	var commentsDone uint32 = 0
 
@@ -79,7 +109,7 @@ func PrintArticlePageToPDF(parentCtx context.Context,
 		case *network.EventResponseReceived:
 			response := responseReceivedEvent.Response
 			// rate limit detection
-			if response.URL == geektime.DefaultBaseURL+"/serv/v1/article" && response.Status == 451 {
+			if response.URL == baseURL+geektime.V1ArticlePath && response.Status == 451 {
 				logger.Warnf("Hit GeekTime rate limit when downloading article pdf, articleID: %d, pdfFileName: %s", aid, pdfFileName)
 				rateLimit = true
 				timeoutCancel()
@@ -104,7 +134,7 @@ func PrintArticlePageToPDF(parentCtx context.Context,
 		network.Enable(),
 		chromedp.Emulate(device.IPadPro11),
 		setCookies(cookies),
-		chromedp.Navigate(geektime.DefaultBaseURL + `/column/article/` + strconv.Itoa(aid)),
+		chromedp.Navigate(baseURL + geektime.ArticlePagePath + strconv.Itoa(aid)),
 		chromedp.Sleep(time.Duration(cfg.PrintPDFWaitSeconds) * time.Second),
 	}
 
@@ -332,7 +362,7 @@ func fetchAndHandleCommentList(ctx context.Context, reqID network.RequestID, url
 			return
 		}
 
-		var commentResp V4CommentListResponse
+		var commentResp response.V4CommentListResponse
 		if err := json.Unmarshal(body, &commentResp); err != nil {
 			logger.Errorf(err, "Failed to unmarshal comment response body")
 			return
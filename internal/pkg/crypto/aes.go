@@ -65,3 +65,31 @@ func pkcs5UnPadding(origData []byte) []byte {
 	unpadding := int(origData[length-1])
 	return origData[:(length - unpadding)]
 }
+
+// AESDecryptHLS decrypts segment encrypted by standard HLS AES-128 method,
+// which is AES-128 CBC with PKCS7 padding
+func AESDecryptHLS(encrypted, key, iv []byte) ([]byte, error) {
+	if len(key) != aes.BlockSize || len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid AES-128 key or IV length: %d, %d", len(key), len(iv))
+	}
+	if len(encrypted) == 0 || len(encrypted)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("encrypted data length %d is not multiple of block size", len(encrypted))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	decrypted := make([]byte, len(encrypted))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, encrypted)
+
+	padding := int(decrypted[len(decrypted)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, fmt.Errorf("invalid PKCS7 padding %d", padding)
+	}
+	for _, b := range decrypted[len(decrypted)-padding:] {
+		if int(b) != padding {
+			return nil, fmt.Errorf("invalid PKCS7 padding %d", padding)
+		}
+	}
+	return decrypted[:len(decrypted)-padding], nil
+}
@@ -13,18 +13,66 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/nicoxiang/geektime-downloader/internal/pkg/logger"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/ratelimit"
 )
 
-// Part use this struct as a channel type.
-// The struct will have 2 fields. One for housing data and the other specifying its location within the final file.
-type Part struct {
-	Data  []byte
-	Index int
-	Offset int64
+// bufferSize is the size of buffer each worker streams response body through
+const bufferSize = 32 * 1024
+
+// ProgressFunc is called with the bytes written since last call.
+// It's called from multiple goroutines when downloading concurrently.
+type ProgressFunc func(written int64)
+
+// errRangeIgnored is returned when server sends the whole file for a range request of part of file
+var errRangeIgnored = errors.New("server ignores range request")
+
+// chunk is a byte range of file downloaded by one worker
+type chunk struct {
+	start int64
+	// end is inclusive, -1 means until the end of response body
+	end int64
+	// received is the bytes already written, retry only requests the rest
+	received int64
+	// base is the remote offset written at the beginning of file
+	base int64
+}
+
+// limiter throttles requests to CDN, nil doesn't limit
+var limiter *ratelimit.Limiter
+
+// client sends all requests of downloader
+var client = http.DefaultClient
+
+// SetTransport sends all requests of downloader by rt, e.g. to record or replay responses
+func SetTransport(rt http.RoundTripper) {
+	client = &http.Client{Transport: rt}
+}
+
+// SetRateLimit sets limiter of all requests sent by downloader
+func SetRateLimit(l *ratelimit.Limiter) {
+	limiter = l
+}
+
+// do sends request after limiter allows
+func do(req *http.Request) (*http.Response, error) {
+	if err := limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return client.Do(req)
 }
 
 // DownloadFileConcurrently download file in chunks, return total file size
 func DownloadFileConcurrently(ctx context.Context, filepath string, url string, headers map[string]string, concurrency int) (int64, error) {
+	return DownloadFile(ctx, filepath, url, headers, concurrency, nil)
+}
+
+// DownloadFile download file in chunks, each chunk is streamed to its offset in file directly,
+// progress is reported incrementally if progress is not nil. Return total file size.
+func DownloadFile(ctx context.Context, filepath string, url string, headers map[string]string, concurrency int, progress ProgressFunc) (int64, error) {
+	if progress == nil {
+		progress = func(int64) {}
+	}
+
 	// Use HEAD with context so it can be cancelled by parent ctx (Ctrl+C)
 	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
 	if err != nil {
@@ -34,96 +82,73 @@ func DownloadFileConcurrently(ctx context.Context, filepath string, url string,
 	for k, v := range headers {
 		req.Header.Add(k, v)
 	}
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := do(req)
 	if err != nil {
 		return 0, err
 	}
 	if resp.Body != nil {
 		_ = resp.Body.Close()
 	}
+	// Content-Length of error response is not the file size
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return 0, fmt.Errorf("unexpected response status %s of HEAD %s", resp.Status, url)
+	}
+
+	out, err := os.OpenFile(filepath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o666)
+	if err != nil {
+		return 0, err
+	}
+	removeOnError := false
+	defer func() {
+		_ = out.Close()
+		if removeOnError {
+			_ = os.Remove(filepath)
+		}
+	}()
 
 	fileSize, _ := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
 	if fileSize <= 0 {
-		out, err := os.OpenFile(filepath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o666)
-		if err != nil {
+		// size is unknown, stream the whole body in one request
+		c := &chunk{start: 0, end: -1}
+		if err := downloadChunk(ctx, out, url, headers, c, progress); err != nil {
+			removeOnError = true
 			return 0, err
 		}
-		defer func() {
-			_ = out.Close()
-		}()
-		return fileSize, nil
+		return c.received, nil
 	}
 
-	if concurrency <= 0 {
+	// server without range support sends the whole file in one response
+	if concurrency <= 0 || resp.Header.Get("Accept-Ranges") != "bytes" {
 		concurrency = 1
 	}
 	if int64(concurrency) > fileSize {
 		concurrency = int(fileSize)
 	}
 
-	g, ctx := errgroup.WithContext(ctx)
-
-	results := make(chan Part, concurrency)
+	g, gctx := errgroup.WithContext(ctx)
 
 	chunkSize := fileSize / int64(concurrency)
 
 	for i := 0; i < concurrency; i++ {
-		i := i
+		c := &chunk{start: int64(i) * chunkSize}
+		c.end = c.start + chunkSize - 1
+		// the last chunk gets the rest of the file
+		if i == concurrency-1 {
+			c.end = fileSize - 1
+		}
 		g.Go(func() error {
-			return download(ctx, concurrency, i, chunkSize, url, results)
+			return downloadChunk(gctx, out, url, headers, c, progress)
 		})
 	}
 
-	errCh := make(chan error, 1)
-	go func() {
-		err := g.Wait()
-		close(results)
-		errCh <- err
-		close(errCh)
-	}()
-
-	out, err := os.OpenFile(filepath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o666)
-	if err != nil {
-		return 0, err
-	}
-	removeOnError := false
-	defer func() {
-		_ = out.Close()
-		if removeOnError {
-			_ = os.Remove(filepath)
+	err = g.Wait()
+	if errors.Is(err, errRangeIgnored) {
+		logger.Warnf("Server ignores range request, download the whole file in one request, url: %s", url)
+		if err = out.Truncate(0); err == nil {
+			err = downloadChunk(ctx, out, url, headers, &chunk{start: 0, end: fileSize - 1}, progress)
 		}
-	}()
-
-	nextIndex := 0
-	pending := make(map[int]Part, concurrency)
-	var writeErr error
-
-	for part := range results {
-		if writeErr != nil {
-			continue
-		}
-		pending[part.Index] = part
-		for {
-			nextPart, ok := pending[nextIndex]
-			if !ok {
-				break
-			}
-			_, err := out.WriteAt(nextPart.Data, nextPart.Offset)
-			if err != nil {
-				writeErr = err
-				break
-			}
-			delete(pending, nextIndex)
-			nextIndex++
-		}
-	}
-
-	if writeErr != nil {
-		removeOnError = true
-		return 0, writeErr
 	}
-
-	if err := <-errCh; err != nil {
+	if err != nil {
 		removeOnError = true
 		return 0, err
 	}
@@ -131,52 +156,99 @@ func DownloadFileConcurrently(ctx context.Context, filepath string, url string,
 	return fileSize, nil
 }
 
-func download(ctx context.Context, workers int, index int, chunkSize int64, url string, c chan Part) error {
-	// calculate offset by multiplying
-	// index with size
-	start := int64(index) * chunkSize
-
-	// Write data range in correct format
-	// I'm reducing one from the end size to account for
-	// the next chunk starting there
-	dataRange := fmt.Sprintf("bytes=%d-%d", start, start+chunkSize-1)
-
-	// if this is downloading the last chunk
-	// rewrite the header. It's an easy way to specify
-	// getting the rest of the file
-	if index == workers-1 {
-		dataRange = fmt.Sprintf("bytes=%d-", start)
+// DownloadRange downloads length bytes from offset of url into file, return bytes written
+func DownloadRange(ctx context.Context, filepath string, url string, headers map[string]string, offset, length int64, progress ProgressFunc) (int64, error) {
+	if progress == nil {
+		progress = func(int64) {}
 	}
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	out, err := os.OpenFile(filepath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o666)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	defer func() {
+		_ = out.Close()
+	}()
 
-	req.Header.Add("Range", dataRange)
+	c := &chunk{start: offset, end: offset + length - 1, base: offset}
+	if err := downloadChunk(ctx, out, url, headers, c, progress); err != nil {
+		_ = os.Remove(filepath)
+		return 0, err
+	}
+	return c.received, nil
+}
+
+// downloadChunk streams the chunk into out at its offset, retries only request the bytes not received yet
+func downloadChunk(ctx context.Context, out *os.File, url string, headers map[string]string, c *chunk, progress ProgressFunc) error {
+	buf := make([]byte, bufferSize)
 
 	// fix error: http2: server sent GOAWAY and closed the connection; LastStreamID=1999
 	// error comes from io read, not request
-	err = retry(ctx, 3, 700*time.Millisecond, func() error {
-		resp, err := http.DefaultClient.Do(req)
+	return retry(ctx, 3, 700*time.Millisecond, func() error {
+		offset := c.start + c.received
+		if c.end >= 0 && offset > c.end {
+			return nil
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return err
+		}
+		for k, v := range headers {
+			req.Header.Add(k, v)
+		}
+		switch {
+		case c.end >= 0:
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, c.end))
+		case offset > 0:
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+
+		resp, err := do(req)
 		if err != nil {
 			return err
 		}
 		defer func() {
 			_ = resp.Body.Close()
 		}()
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return err
+
+		// server may ignore range header and send the whole file, which is only fine if chunk is the whole file,
+		// it's written again from the beginning then
+		switch {
+		case resp.StatusCode == http.StatusOK && c.start == 0 && c.base == 0 && (c.end < 0 || c.end+1 == resp.ContentLength):
+			offset, c.received = 0, 0
+		case resp.StatusCode == http.StatusOK:
+			return errRangeIgnored
+		case resp.StatusCode != http.StatusPartialContent:
+			return fmt.Errorf("unexpected response status %s for range %s", resp.Status, req.Header.Get("Range"))
 		}
-		c <- Part{Index: index, Offset: start, Data: body}
-		return nil
-	})
-	if err != nil {
-		return err
-	}
 
-	return err
+		for {
+			n, readErr := resp.Body.Read(buf)
+			if n > 0 {
+				if c.end >= 0 && offset+int64(n)-1 > c.end {
+					n = int(c.end - offset + 1)
+				}
+				if _, err := out.WriteAt(buf[:n], offset-c.base); err != nil {
+					return err
+				}
+				offset += int64(n)
+				c.received += int64(n)
+				progress(int64(n))
+			}
+			if c.end >= 0 && offset > c.end {
+				return nil
+			}
+			if errors.Is(readErr, io.EOF) {
+				if c.end >= 0 {
+					return io.ErrUnexpectedEOF
+				}
+				return nil
+			}
+			if readErr != nil {
+				return readErr
+			}
+		}
+	})
 }
 
 func retry(ctx context.Context, attempts int, sleep time.Duration, f func() error) (err error) {
@@ -193,9 +265,9 @@ func retry(ctx context.Context, attempts int, sleep time.Duration, f func() erro
 			logger.Infof("retry hanppen, times: %s", strconv.Itoa(i))
 		}
 		err = f()
-		if err == nil || errors.Is(err, context.Canceled) {
+		if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, errRangeIgnored) {
 			return err
 		}
 	}
-	return fmt.Errorf("after %d attempts, last error: %s", attempts, err)
+	return fmt.Errorf("after %d attempts, last error: %w", attempts, err)
 }
@@ -0,0 +1,126 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDownloadFileResumesBrokenRange(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+
+	var mu sync.Mutex
+	var ranges []string
+	broken := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != "test-agent" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Header().Set("Accept-Ranges", "bytes")
+			return
+		}
+		var start, end int
+		if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		breakNow := !broken && start == 0
+		broken = broken || breakNow
+		mu.Unlock()
+
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(content)))
+		w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+		w.WriteHeader(http.StatusPartialContent)
+		if breakNow {
+			// send half of the range then drop the connection
+			_, _ = w.Write(content[start : start+(end-start+1)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		_, _ = w.Write(content[start : end+1])
+	}))
+	defer srv.Close()
+
+	dst := filepath.Join(t.TempDir(), "file")
+	var progress int64
+	size, err := DownloadFile(context.Background(), dst, srv.URL, map[string]string{"User-Agent": "test-agent"}, 2,
+		func(written int64) { atomic.AddInt64(&progress, written) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(content)) || !bytes.Equal(got, content) {
+		t.Fatalf("downloaded content mismatch, size %d", size)
+	}
+	if progress != int64(len(content)) {
+		t.Fatalf("expected progress %d, got %d", len(content), progress)
+	}
+	// the broken first range is resumed from where it stopped
+	resumed := fmt.Sprintf("bytes=%d-%d", len(content)/4, len(content)/2-1)
+	found := false
+	for _, r := range ranges {
+		found = found || r == resumed
+	}
+	if !found {
+		t.Fatalf("expected resumed range %s, got %v", resumed, ranges)
+	}
+}
+
+func TestDownloadFileRangeIgnored(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		if r.Method == http.MethodHead {
+			return
+		}
+		// range header is ignored
+		_, _ = w.Write(content)
+	}))
+	defer srv.Close()
+
+	dst := filepath.Join(t.TempDir(), "file")
+	size, err := DownloadFile(context.Background(), dst, srv.URL, nil, 4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(content)) || !bytes.Equal(got, content) {
+		t.Fatalf("downloaded content mismatch, size %d", size)
+	}
+}
+
+func TestDownloadFileHeadFailed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	dst := filepath.Join(t.TempDir(), "file")
+	if _, err := DownloadFile(context.Background(), dst, srv.URL, nil, 2, nil); err == nil {
+		t.Fatal("want error of HEAD not found")
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Errorf("file is created after HEAD failed, err: %v", err)
+	}
+}
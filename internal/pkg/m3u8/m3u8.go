@@ -1,54 +1,40 @@
 package m3u8
 
 import (
-	"bufio"
-	"regexp"
-	"strings"
+	"fmt"
 
 	"github.com/nicoxiang/geektime-downloader/internal/geektime"
 )
 
-// regex pattern for extracting `key=value` parameters from a line
-var linePattern = regexp.MustCompile(`([a-zA-Z-]+)=("[^"]+"|[^",]+)`)
-
-// Parse do m3u8 url GET request, and extract ts file names and check if it's encrypt video
-func Parse(client *geektime.Client, m3u8url string) (tsFileNames []string, isVodEncryptVideo bool, err error) {
-	m3u8Resp, err := client.RestyClient.R().SetDoNotParseResponse(true).Get(m3u8url)
+// Parse do m3u8 url GET request and decodes the media playlist.
+// If it's a master playlist, the variant with highest bandwidth is used.
+func Parse(client *geektime.Client, m3u8url string) (*MediaPlaylist, error) {
+	playlist, err := fetch(client, m3u8url)
 	if err != nil {
-		return nil, false, err
-	}
-	defer func() {
-		_ = m3u8Resp.RawBody().Close()
-	}()
-	s := bufio.NewScanner(m3u8Resp.RawBody())
-	var lines []string
-	for s.Scan() {
-		lines = append(lines, s.Text())
+		return nil, err
 	}
-
-	gotKeyURI := false
-
-	for _, line := range lines {
-		// geektime video ONLY has one EXT-X-KEY
-		if strings.HasPrefix(line, "#EXT-X-KEY") && !gotKeyURI {
-			// ONLY Method and URI, IV not present
-			params := parseLineParameters(line)
-			isVodEncryptVideo, gotKeyURI = params["MEATHOD"] == "AES-128", true
+	if playlist.Master != nil {
+		variant, ok := playlist.Master.BestVariant()
+		if !ok {
+			return nil, fmt.Errorf("no variant stream in master playlist %s", m3u8url)
 		}
-		if !strings.HasPrefix(line, "#") && strings.HasSuffix(line, ".ts") {
-			tsFileNames = append(tsFileNames, line)
+		if playlist, err = fetch(client, variant.URI); err != nil {
+			return nil, err
+		}
+		if playlist.Media == nil {
+			return nil, fmt.Errorf("variant stream %s is not a media playlist", variant.URI)
 		}
 	}
-
-	return
+	return playlist.Media, nil
 }
 
-// parseLineParameters extra parameters in string `line`
-func parseLineParameters(line string) map[string]string {
-	r := linePattern.FindAllStringSubmatch(line, -1)
-	params := make(map[string]string)
-	for _, arr := range r {
-		params[arr[1]] = strings.Trim(arr[2], "\"")
+func fetch(client *geektime.Client, m3u8url string) (*Playlist, error) {
+	m3u8Resp, err := client.RestyClient.R().SetDoNotParseResponse(true).Get(m3u8url)
+	if err != nil {
+		return nil, err
 	}
-	return params
+	defer func() {
+		_ = m3u8Resp.RawBody().Close()
+	}()
+	return Decode(m3u8Resp.RawBody(), m3u8url)
 }
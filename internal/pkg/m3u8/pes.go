@@ -0,0 +1,82 @@
+package m3u8
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// PES is a demuxed packetized elementary stream packet, timestamps are in 90kHz clock
+type PES struct {
+	PTS    int64
+	DTS    int64
+	HasPTS bool
+	Data   []byte
+}
+
+// DemuxPES parses decrypted TS data, returns video and audio PES packets in stream order.
+func DemuxPES(data []byte) (videos, audios []PES, err error) {
+	stream := &tsStream{
+		data: data,
+	}
+	if err := stream.parseTS(); err != nil {
+		return nil, nil, err
+	}
+	if videos, err = stream.demux(stream.videos); err != nil {
+		return nil, nil, err
+	}
+	if audios, err = stream.demux(stream.audios); err != nil {
+		return nil, nil, err
+	}
+	return videos, audios, nil
+}
+
+func (stream *tsStream) demux(pesFragments []*tsPesFragment) ([]PES, error) {
+	var result []PES
+	for _, fragment := range pesFragments {
+		if len(fragment.packets) == 0 || !fragment.packets[0].header.isPayloadStart {
+			continue
+		}
+		pes, err := parsePESHeader(stream.data, fragment.packets[0].pesOffset)
+		if err != nil {
+			return nil, err
+		}
+
+		buffer := &bytes.Buffer{}
+		for _, packet := range fragment.packets {
+			if !packet.header.hasPayload || len(packet.payload) == 0 {
+				continue
+			}
+			buffer.Write(packet.payload)
+		}
+		pes.Data = buffer.Bytes()
+		result = append(result, pes)
+	}
+	return result, nil
+}
+
+// parsePESHeader reads PTS and DTS in PES header starts at offset
+func parsePESHeader(data []byte, offset int) (PES, error) {
+	var pes PES
+	if offset+9 > len(data) || data[offset] != 0 || data[offset+1] != 0 || data[offset+2] != 1 {
+		return pes, fmt.Errorf("invalid PES header at offset %d", offset)
+	}
+	ptsDTSFlags := data[offset+7] >> 6
+	if ptsDTSFlags&0x2 != 0 && offset+14 <= len(data) {
+		pes.PTS = readTimestamp(data[offset+9:])
+		pes.DTS = pes.PTS
+		pes.HasPTS = true
+	}
+	if ptsDTSFlags == 0x3 && offset+19 <= len(data) {
+		pes.DTS = readTimestamp(data[offset+14:])
+	}
+	return pes, nil
+}
+
+// readTimestamp reads 33 bits PTS or DTS from 5 bytes
+func readTimestamp(b []byte) int64 {
+	return int64(b[0]>>1&0x07)<<30 |
+		int64(b[1])<<22 |
+		int64(b[2]>>1)<<15 |
+		int64(b[3])<<7 |
+		int64(b[4]>>1)
+}
@@ -0,0 +1,325 @@
+package m3u8
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// EncryptMethod is how segments are encrypted
+type EncryptMethod int
+
+const (
+	// EncryptNone means segments are not encrypted
+	EncryptNone EncryptMethod = iota
+	// EncryptAliyunVod is aliyun private encryption of PES payload inside TS packets,
+	// marked by the misspelt MEATHOD attribute in EXT-X-KEY
+	EncryptAliyunVod
+	// EncryptAES128 is standard HLS AES-128 CBC encryption of whole segment
+	EncryptAES128
+	// EncryptSampleAES is standard HLS SAMPLE-AES encryption, not supported
+	EncryptSampleAES
+)
+
+// ErrInvalidPlaylist means the content is not a HLS playlist
+var ErrInvalidPlaylist = errors.New("invalid m3u8 playlist")
+
+// Key is the EXT-X-KEY applied to segments
+type Key struct {
+	Method EncryptMethod
+	// URI is the absolute key URL
+	URI string
+	// IV is nil if not present, then the media sequence number is used as IV
+	IV        []byte
+	KeyFormat string
+}
+
+// ByteRange is the sub range of resource of a segment
+type ByteRange struct {
+	Length int64
+	Offset int64
+}
+
+// Segment is a media segment in media playlist
+type Segment struct {
+	// URI is the absolute segment URL
+	URI      string
+	Duration float64
+	Title    string
+	// Sequence is the media sequence number of segment
+	Sequence      int64
+	ByteRange     *ByteRange
+	Discontinuity bool
+	// Key is nil if segment is not encrypted
+	Key *Key
+}
+
+// Variant is a variant stream in master playlist
+type Variant struct {
+	// URI is the absolute media playlist URL
+	URI              string
+	Bandwidth        int64
+	AverageBandwidth int64
+	Width, Height    int
+	Codecs           string
+}
+
+// MasterPlaylist lists variant streams of different bitrates
+type MasterPlaylist struct {
+	Variants []Variant
+}
+
+// MediaPlaylist lists media segments
+type MediaPlaylist struct {
+	Version        int
+	TargetDuration float64
+	MediaSequence  int64
+	EndList        bool
+	Segments       []Segment
+}
+
+// Playlist is either a master playlist or a media playlist
+type Playlist struct {
+	Master *MasterPlaylist
+	Media  *MediaPlaylist
+}
+
+// Duration returns total duration of all segments in seconds
+func (p *MediaPlaylist) Duration() float64 {
+	var d float64
+	for _, s := range p.Segments {
+		d += s.Duration
+	}
+	return d
+}
+
+// EncryptMethod returns encryption of segments, the first encrypted segment decides
+func (p *MediaPlaylist) EncryptMethod() EncryptMethod {
+	for _, s := range p.Segments {
+		if s.Key != nil {
+			return s.Key.Method
+		}
+	}
+	return EncryptNone
+}
+
+// BestVariant returns the variant with highest bandwidth
+func (p *MasterPlaylist) BestVariant() (Variant, bool) {
+	if len(p.Variants) == 0 {
+		return Variant{}, false
+	}
+	best := p.Variants[0]
+	for _, v := range p.Variants[1:] {
+		if v.Bandwidth > best.Bandwidth {
+			best = v
+		}
+	}
+	return best, true
+}
+
+// Decode reads playlist from r, relative URIs are resolved against playlistURL
+func Decode(r io.Reader, playlistURL string) (*Playlist, error) {
+	base, err := url.Parse(playlistURL)
+	if err != nil {
+		return nil, err
+	}
+	resolve := func(ref string) (string, error) {
+		u, err := url.Parse(ref)
+		if err != nil {
+			return "", fmt.Errorf("invalid uri %s in m3u8 playlist: %w", ref, err)
+		}
+		return base.ResolveReference(u).String(), nil
+	}
+
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var (
+		master   MasterPlaylist
+		media    MediaPlaylist
+		isMaster bool
+
+		started bool
+		// states apply to the next segment or variant
+		segment        Segment
+		variant        *Variant
+		key            *Key
+		lastRangeEnd   = make(map[string]int64)
+		pendingRange   *ByteRange
+		rangeHasOffset bool
+	)
+	for s.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(s.Text(), "\ufeff"))
+		if line == "" {
+			continue
+		}
+		if !started {
+			if line != "#EXTM3U" {
+				return nil, ErrInvalidPlaylist
+			}
+			started = true
+			continue
+		}
+
+		tag, value, _ := strings.Cut(line, ":")
+		switch {
+		case tag == "#EXT-X-VERSION":
+			media.Version, _ = strconv.Atoi(value)
+		case tag == "#EXT-X-TARGETDURATION":
+			media.TargetDuration, _ = strconv.ParseFloat(value, 64)
+		case tag == "#EXT-X-MEDIA-SEQUENCE":
+			media.MediaSequence, _ = strconv.ParseInt(value, 10, 64)
+		case tag == "#EXT-X-ENDLIST":
+			media.EndList = true
+		case tag == "#EXT-X-DISCONTINUITY":
+			segment.Discontinuity = true
+		case tag == "#EXTINF":
+			duration, title, _ := strings.Cut(value, ",")
+			segment.Duration, err = strconv.ParseFloat(strings.TrimSpace(duration), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid EXTINF %s: %w", value, err)
+			}
+			segment.Title = title
+		case tag == "#EXT-X-BYTERANGE":
+			length, offset, hasOffset := strings.Cut(value, "@")
+			pendingRange = &ByteRange{}
+			if pendingRange.Length, err = strconv.ParseInt(length, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid EXT-X-BYTERANGE %s: %w", value, err)
+			}
+			if hasOffset {
+				if pendingRange.Offset, err = strconv.ParseInt(offset, 10, 64); err != nil {
+					return nil, fmt.Errorf("invalid EXT-X-BYTERANGE %s: %w", value, err)
+				}
+			}
+			rangeHasOffset = hasOffset
+		case tag == "#EXT-X-KEY":
+			if key, err = parseKey(value, resolve); err != nil {
+				return nil, err
+			}
+		case tag == "#EXT-X-STREAM-INF":
+			isMaster = true
+			variant = parseVariant(value)
+		case strings.HasPrefix(line, "#"):
+			// comments and tags not used
+		default:
+			uri, err := resolve(line)
+			if err != nil {
+				return nil, err
+			}
+			if variant != nil {
+				variant.URI = uri
+				master.Variants = append(master.Variants, *variant)
+				variant = nil
+				continue
+			}
+			segment.URI = uri
+			segment.Sequence = media.MediaSequence + int64(len(media.Segments))
+			segment.Key = key
+			if pendingRange != nil {
+				// offset defaults to the end of previous sub range of the same resource
+				if !rangeHasOffset {
+					pendingRange.Offset = lastRangeEnd[uri]
+				}
+				lastRangeEnd[uri] = pendingRange.Offset + pendingRange.Length
+				segment.ByteRange = pendingRange
+			}
+			media.Segments = append(media.Segments, segment)
+			segment, pendingRange = Segment{}, nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if !started {
+		return nil, ErrInvalidPlaylist
+	}
+	if isMaster {
+		return &Playlist{Master: &master}, nil
+	}
+	return &Playlist{Media: &media}, nil
+}
+
+func parseKey(value string, resolve func(string) (string, error)) (*Key, error) {
+	attrs := parseAttributes(value)
+	method, ok := attrs["METHOD"]
+	if !ok {
+		// aliyun vod private encryption
+		if attrs["MEATHOD"] == "AES-128" {
+			return &Key{Method: EncryptAliyunVod}, nil
+		}
+		method = attrs["MEATHOD"]
+	}
+
+	key := &Key{KeyFormat: attrs["KEYFORMAT"]}
+	switch method {
+	case "", "NONE":
+		return nil, nil
+	case "AES-128":
+		key.Method = EncryptAES128
+	case "SAMPLE-AES":
+		key.Method = EncryptSampleAES
+	default:
+		return nil, fmt.Errorf("unknown EXT-X-KEY method %s", method)
+	}
+	if uri, ok := attrs["URI"]; ok {
+		var err error
+		if key.URI, err = resolve(uri); err != nil {
+			return nil, err
+		}
+	}
+	if iv, ok := attrs["IV"]; ok {
+		iv = strings.TrimPrefix(strings.TrimPrefix(iv, "0x"), "0X")
+		b, err := hex.DecodeString(iv)
+		if err != nil || len(b) > 16 {
+			return nil, fmt.Errorf("invalid EXT-X-KEY IV %s", attrs["IV"])
+		}
+		// left pad to 16 bytes
+		key.IV = make([]byte, 16)
+		copy(key.IV[16-len(b):], b)
+	}
+	return key, nil
+}
+
+func parseVariant(value string) *Variant {
+	attrs := parseAttributes(value)
+	v := &Variant{Codecs: attrs["CODECS"]}
+	v.Bandwidth, _ = strconv.ParseInt(attrs["BANDWIDTH"], 10, 64)
+	v.AverageBandwidth, _ = strconv.ParseInt(attrs["AVERAGE-BANDWIDTH"], 10, 64)
+	if w, h, ok := strings.Cut(attrs["RESOLUTION"], "x"); ok {
+		v.Width, _ = strconv.Atoi(w)
+		v.Height, _ = strconv.Atoi(h)
+	}
+	return v
+}
+
+// parseAttributes parses attribute list like KEY=VALUE,KEY="QUOTED,VALUE"
+func parseAttributes(s string) map[string]string {
+	attrs := make(map[string]string)
+	for len(s) > 0 {
+		name, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		name = strings.TrimSpace(name)
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+			_, rest, _ = strings.Cut(rest, ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		attrs[name] = strings.TrimSpace(value)
+		s = rest
+	}
+	return attrs
+}
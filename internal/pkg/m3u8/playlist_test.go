@@ -0,0 +1,104 @@
+package m3u8
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func decodeFixture(t *testing.T, name, playlistURL string) *Playlist {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	p, err := Decode(f, playlistURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestDecodeAliyunPlaylist(t *testing.T) {
+	p := decodeFixture(t, "aliyun.m3u8", "https://media001.geekbang.org/abc/sd/playlist.m3u8?MtsHlsUriToken=t")
+	if p.Media == nil || p.Master != nil {
+		t.Fatal("expected media playlist")
+	}
+	m := p.Media
+	if len(m.Segments) != 3 || !m.EndList || m.TargetDuration != 11 {
+		t.Fatalf("unexpected playlist %+v", m)
+	}
+	if m.EncryptMethod() != EncryptAliyunVod {
+		t.Errorf("expected aliyun vod encryption, got %v", m.EncryptMethod())
+	}
+	if got := m.Segments[0].URI; got != "https://media001.geekbang.org/abc/sd/3c5a1ba4e19bd6b51bd14e1e6ef4c4f6-sd-encrypt-stream-00001.ts" {
+		t.Errorf("unexpected segment uri %s", got)
+	}
+	if d := m.Duration(); d < 24.51 || d > 24.53 {
+		t.Errorf("unexpected duration %f", d)
+	}
+}
+
+func TestDecodeMasterPlaylist(t *testing.T) {
+	p := decodeFixture(t, "master.m3u8", "https://example.com/video/master.m3u8")
+	if p.Master == nil || len(p.Master.Variants) != 3 {
+		t.Fatal("expected master playlist with 3 variants")
+	}
+	v := p.Master.Variants[0]
+	if v.URI != "https://example.com/video/sd/index.m3u8" || v.Width != 960 || v.Height != 540 ||
+		v.AverageBandwidth != 700000 || v.Codecs != "avc1.4d401f,mp4a.40.2" {
+		t.Errorf("unexpected variant %+v", v)
+	}
+	if p.Master.Variants[2].URI != "https://example.com/ld/index.m3u8" {
+		t.Errorf("unexpected absolute path variant %s", p.Master.Variants[2].URI)
+	}
+	best, _ := p.Master.BestVariant()
+	if best.URI != "https://cdn.example.com/hd/index.m3u8?auth_key=abc" {
+		t.Errorf("unexpected best variant %s", best.URI)
+	}
+}
+
+func TestDecodeAES128Playlist(t *testing.T) {
+	p := decodeFixture(t, "aes128.m3u8", "https://example.com/v/index.m3u8")
+	m := p.Media
+	if len(m.Segments) != 3 {
+		t.Fatalf("expected 3 segments, got %d", len(m.Segments))
+	}
+	first, second, third := m.Segments[0], m.Segments[1], m.Segments[2]
+	if first.URI != "https://example.com/v/seg7.ts?sign=xyz" || first.Sequence != 7 || first.Title != "first" {
+		t.Errorf("unexpected first segment %+v", first)
+	}
+	if first.Key == nil || first.Key.Method != EncryptAES128 || first.Key.URI != "https://example.com/v/keys/key.bin?token=a,b" {
+		t.Fatalf("unexpected first key %+v", first.Key)
+	}
+	if !bytes.Equal(first.Key.IV, []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}) {
+		t.Errorf("unexpected iv %x", first.Key.IV)
+	}
+	if second.Key == nil || second.Key.URI != "https://keys.example.com/k2" || second.Key.IV != nil || second.Sequence != 8 {
+		t.Errorf("unexpected second segment %+v", second)
+	}
+	if !third.Discontinuity || third.Key != nil || second.Discontinuity {
+		t.Errorf("unexpected third segment %+v", third)
+	}
+}
+
+func TestDecodeByteRangePlaylist(t *testing.T) {
+	p := decodeFixture(t, "byterange.m3u8", "https://example.com/v/index.m3u8")
+	want := []ByteRange{{1000, 0}, {2000, 1000}, {500, 5000}}
+	for i, s := range p.Media.Segments {
+		if s.ByteRange == nil || *s.ByteRange != want[i] {
+			t.Errorf("segment %d: expected byte range %+v, got %+v", i, want[i], s.ByteRange)
+		}
+	}
+}
+
+func TestDecodeInvalidPlaylist(t *testing.T) {
+	if _, err := Decode(strings.NewReader("<html></html>"), "https://example.com/"); err != ErrInvalidPlaylist {
+		t.Errorf("expected ErrInvalidPlaylist, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+// Package mp3 reads MPEG audio frames and writes ID3v2.3 tags with cover art and chapters,
+// so MP3 files can be tagged and joined into an audiobook without external tools.
+package mp3
+
+import (
+	"bytes"
+	"errors"
+	"time"
+)
+
+// ErrNoFrames means there is no MPEG audio frame in data
+var ErrNoFrames = errors.New("no mp3 frames")
+
+// bitrates in kbps by [version is MPEG1][layer - 1][index]
+var bitrates = [2][3][16]int{
+	// MPEG2 and MPEG2.5
+	{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	},
+	// MPEG1
+	{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+	},
+}
+
+// sample rates by [version bits][index], version bits 1 is reserved
+var sampleRates = [4][3]int{
+	{11025, 12000, 8000},  // MPEG2.5
+	{0, 0, 0},             // reserved
+	{22050, 24000, 16000}, // MPEG2
+	{44100, 48000, 32000}, // MPEG1
+}
+
+// frameHeader is the decoded 4 bytes header of MPEG audio frame
+type frameHeader struct {
+	size       int
+	samples    int
+	sampleRate int
+}
+
+// parseHeader decodes frame header at the start of b, ok is false if it's not a valid header
+func parseHeader(b []byte) (h frameHeader, ok bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return h, false
+	}
+	version := int(b[1]>>3) & 3
+	layer := 4 - int(b[1]>>1)&3
+	bitrateIndex := int(b[2] >> 4)
+	rateIndex := int(b[2]>>2) & 3
+	padding := int(b[2]>>1) & 1
+	if version == 1 || layer == 4 || rateIndex == 3 {
+		return h, false
+	}
+	mpeg1 := 0
+	if version == 3 {
+		mpeg1 = 1
+	}
+	bitrate := bitrates[mpeg1][layer-1][bitrateIndex] * 1000
+	if bitrate == 0 {
+		// free format is not supported
+		return h, false
+	}
+	h.sampleRate = sampleRates[version][rateIndex]
+
+	switch {
+	case layer == 1:
+		h.samples = 384
+		h.size = (12*bitrate/h.sampleRate + padding) * 4
+	case layer == 3 && mpeg1 == 0:
+		h.samples = 576
+		h.size = 72*bitrate/h.sampleRate + padding
+	default:
+		h.samples = 1152
+		h.size = 144*bitrate/h.sampleRate + padding
+	}
+	return h, true
+}
+
+// Audio is the MPEG audio frames of a MP3 file
+type Audio struct {
+	// Frames is the raw frames, tags and VBR header frame are removed
+	Frames   []byte
+	Duration time.Duration
+}
+
+// ReadAudio returns audio frames of MP3 file content. ID3v2 tag, ID3v1 tag, junk between frames
+// and Xing, Info or VBRI header frame are left out, because they don't belong to joined audio.
+func ReadAudio(data []byte) (Audio, error) {
+	data = data[tagSize(data):]
+	if len(data) >= 128 && bytes.Equal(data[len(data)-128:len(data)-125], []byte("TAG")) {
+		data = data[:len(data)-128]
+	}
+
+	var a Audio
+	var samples int64
+	sampleRate := 0
+	first := true
+	for pos := 0; pos+4 <= len(data); {
+		h, ok := parseHeader(data[pos:])
+		// the next header must follow unless it's the last frame, otherwise it's a false sync in junk
+		if ok && pos+h.size < len(data) {
+			_, ok = parseHeader(data[pos+h.size:])
+		}
+		if !ok || pos+h.size > len(data) {
+			pos++
+			continue
+		}
+		frame := data[pos : pos+h.size]
+		pos += h.size
+		if first {
+			first = false
+			if isVBRHeader(frame) {
+				continue
+			}
+		}
+		a.Frames = append(a.Frames, frame...)
+		samples += int64(h.samples)
+		sampleRate = h.sampleRate
+	}
+	if sampleRate == 0 {
+		return Audio{}, ErrNoFrames
+	}
+	a.Duration = time.Duration(samples * int64(time.Second) / int64(sampleRate))
+	return a, nil
+}
+
+// isVBRHeader checks if frame is Xing, Info or VBRI header written by encoders, which has no audio
+func isVBRHeader(frame []byte) bool {
+	end := len(frame)
+	if end > 64 {
+		end = 64
+	}
+	head := frame[4:end]
+	return bytes.Contains(head, []byte("Xing")) || bytes.Contains(head, []byte("Info")) || bytes.Contains(head, []byte("VBRI"))
+}
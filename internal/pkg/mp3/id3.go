@@ -0,0 +1,188 @@
+package mp3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+	"unicode/utf16"
+)
+
+// maxTOCEntries is the most child elements of a CTOC frame, its entry count is one byte
+const maxTOCEntries = 255
+
+// Tag is the ID3v2.3 tag of MP3 file
+type Tag struct {
+	Title  string
+	Album  string
+	Artist string
+	// Track and TrackTotal are written as TRCK like 3/40, zero Track is left out
+	Track      int
+	TrackTotal int
+	// Cover is JPEG or PNG image, other formats are ignored
+	Cover    []byte
+	Chapters []Chapter
+}
+
+// Chapter is a chapter of audiobook written as CHAP frame
+type Chapter struct {
+	Title string
+	Start time.Duration
+	End   time.Duration
+}
+
+// Bytes encodes tag with ID3v2.3 header. Text is written in UTF-16 which is read by more players than UTF-8 of ID3v2.4.
+func (t Tag) Bytes() []byte {
+	var frames bytes.Buffer
+	writeFrame(&frames, "TIT2", textFrame(t.Title))
+	writeFrame(&frames, "TALB", textFrame(t.Album))
+	writeFrame(&frames, "TPE1", textFrame(t.Artist))
+	if t.Track > 0 {
+		track := fmt.Sprint(t.Track)
+		if t.TrackTotal > 0 {
+			track += fmt.Sprintf("/%d", t.TrackTotal)
+		}
+		writeFrame(&frames, "TRCK", append([]byte{0}, track...))
+	}
+	if mime := http.DetectContentType(t.Cover); len(t.Cover) > 0 && (mime == "image/jpeg" || mime == "image/png") {
+		// latin-1 mime type, front cover picture type and empty description
+		var apic bytes.Buffer
+		apic.WriteByte(0)
+		apic.WriteString(mime)
+		apic.Write([]byte{0, 3, 0})
+		apic.Write(t.Cover)
+		writeFrame(&frames, "APIC", apic.Bytes())
+	}
+	writeChapters(&frames, t.Chapters)
+
+	header := []byte{'I', 'D', '3', 3, 0, 0}
+	header = append(header, syncsafe(frames.Len())...)
+	return append(header, frames.Bytes()...)
+}
+
+// writeChapters writes CHAP frames and CTOC frames listing them, more chapters than one CTOC
+// can hold are listed by child CTOCs of the top level one
+func writeChapters(w *bytes.Buffer, chapters []Chapter) {
+	if len(chapters) == 0 {
+		return
+	}
+	ids := make([]string, len(chapters))
+	for i, c := range chapters {
+		ids[i] = fmt.Sprintf("chp%d", i)
+		var chap bytes.Buffer
+		chap.WriteString(ids[i] + "\x00")
+		_ = binary.Write(&chap, binary.BigEndian, []uint32{
+			uint32(c.Start / time.Millisecond),
+			uint32(c.End / time.Millisecond),
+			// byte offsets are not used
+			0xFFFFFFFF,
+			0xFFFFFFFF,
+		})
+		writeFrame(&chap, "TIT2", textFrame(c.Title))
+		writeFrame(w, "CHAP", chap.Bytes())
+	}
+
+	if len(ids) <= maxTOCEntries {
+		writeTOC(w, "toc", true, ids)
+		return
+	}
+	var children []string
+	for i := 0; i < len(ids); i += maxTOCEntries {
+		end := i + maxTOCEntries
+		if end > len(ids) {
+			end = len(ids)
+		}
+		id := fmt.Sprintf("toc%d", len(children))
+		writeTOC(w, id, false, ids[i:end])
+		children = append(children, id)
+	}
+	writeTOC(w, "toc", true, children)
+}
+
+func writeTOC(w *bytes.Buffer, id string, topLevel bool, children []string) {
+	var toc bytes.Buffer
+	toc.WriteString(id + "\x00")
+	// ordered flag, and top level flag
+	flags := byte(1)
+	if topLevel {
+		flags |= 2
+	}
+	toc.WriteByte(flags)
+	toc.WriteByte(byte(len(children)))
+	for _, child := range children {
+		toc.WriteString(child + "\x00")
+	}
+	writeFrame(w, "CTOC", toc.Bytes())
+}
+
+// textFrame returns text frame content in UTF-16 with byte order mark, nil if text is empty
+func textFrame(s string) []byte {
+	if s == "" {
+		return nil
+	}
+	b := []byte{1, 0xFF, 0xFE}
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, u)
+	}
+	return b
+}
+
+// writeFrame writes ID3v2.3 frame, empty content is left out
+func writeFrame(w *bytes.Buffer, id string, content []byte) {
+	if len(content) == 0 {
+		return
+	}
+	w.WriteString(id)
+	_ = binary.Write(w, binary.BigEndian, uint32(len(content)))
+	w.Write([]byte{0, 0})
+	w.Write(content)
+}
+
+// syncsafe encodes n in 4 bytes of 7 bits
+func syncsafe(n int) []byte {
+	return []byte{byte(n >> 21 & 0x7F), byte(n >> 14 & 0x7F), byte(n >> 7 & 0x7F), byte(n & 0x7F)}
+}
+
+// tagSize returns size of ID3v2 tag at the start of data, 0 if there is none
+func tagSize(data []byte) int {
+	if len(data) < 10 || !bytes.HasPrefix(data, []byte("ID3")) {
+		return 0
+	}
+	size := int(data[6])<<21 | int(data[7])<<14 | int(data[8])<<7 | int(data[9])
+	size += 10
+	// ID3v2.4 footer
+	if data[3] == 4 && data[5]&0x10 != 0 {
+		size += 10
+	}
+	if size > len(data) {
+		return len(data)
+	}
+	return size
+}
+
+// WriteTag replaces ID3v2 tag of MP3 file with tag, file is written to temp file then renamed
+func WriteTag(path string, tag Tag) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(tag.Bytes())
+	if err == nil {
+		_, err = f.Write(data[tagSize(data):])
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,70 @@
+package mp3
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// frame returns MPEG1 layer III frame of 128kbps and 44100Hz filled with b
+func frame(b byte) []byte {
+	f := bytes.Repeat([]byte{b}, 417)
+	copy(f, []byte{0xFF, 0xFB, 0x90, 0x00})
+	return f
+}
+
+func TestReadAudioAndWriteTag(t *testing.T) {
+	const frames = 38
+	info := frame(0)
+	copy(info[36:], "Info")
+	var audio []byte
+	for i := 0; i < frames; i++ {
+		audio = append(audio, frame(byte(i+1))...)
+	}
+	old := Tag{Title: "old"}.Bytes()
+	id3v1 := append([]byte("TAG"), make([]byte, 125)...)
+	data := append(append(append(append([]byte{}, old...), info...), audio...), id3v1...)
+
+	a, err := ReadAudio(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a.Frames, audio) {
+		t.Errorf("frames length = %d, want %d", len(a.Frames), len(audio))
+	}
+	if want := time.Duration(frames * 1152 * int64(time.Second) / 44100); a.Duration != want {
+		t.Errorf("duration = %s, want %s", a.Duration, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "a.mp3")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tag := Tag{Title: "第一讲", Album: "专栏", Artist: "作者", Track: 1, TrackTotal: 2}
+	for i := 0; i < 300; i++ {
+		tag.Chapters = append(tag.Chapters, Chapter{Title: "章节", Start: time.Duration(i) * time.Second, End: time.Duration(i+1) * time.Second})
+	}
+	if err := WriteTag(path, tag); err != nil {
+		t.Fatal(err)
+	}
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := tagSize(written)
+	if !bytes.Equal(written[:size], tag.Bytes()) {
+		t.Error("tag is not written at the start of file")
+	}
+	if !bytes.Equal(written[size:], data[len(old):]) {
+		t.Error("old tag is not replaced")
+	}
+	if n := bytes.Count(written[:size], []byte("CHAP")); n != 300 {
+		t.Errorf("CHAP frames = %d, want 300", n)
+	}
+	// 300 chapters need two child tables of contents and the top level one
+	if n := bytes.Count(written[:size], []byte("CTOC")); n != 3 {
+		t.Errorf("CTOC frames = %d, want 3", n)
+	}
+}
@@ -0,0 +1,58 @@
+package mp4
+
+import (
+	"fmt"
+)
+
+// samplesPerAACFrame is the number of PCM samples in one AAC frame
+const samplesPerAACFrame = 1024
+
+var aacSampleRates = []uint32{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// adtsFrame is one AAC frame with its ADTS header parsed
+type adtsFrame struct {
+	profile         uint8
+	sampleRateIndex uint8
+	channels        uint8
+	payload         []byte
+}
+
+// splitADTS splits ADTS byte stream into AAC frames without ADTS headers
+func splitADTS(data []byte) ([]adtsFrame, error) {
+	var frames []adtsFrame
+	for len(data) > 0 {
+		if len(data) < 7 || data[0] != 0xFF || data[1]&0xF0 != 0xF0 {
+			return frames, fmt.Errorf("invalid ADTS header")
+		}
+		protectionAbsent := data[1] & 0x01
+		headerLength := 7
+		if protectionAbsent == 0 {
+			headerLength = 9
+		}
+		frameLength := int(data[3]&0x03)<<11 | int(data[4])<<3 | int(data[5]>>5)
+		if frameLength < headerLength || frameLength > len(data) {
+			return frames, fmt.Errorf("invalid ADTS frame length %d", frameLength)
+		}
+		sampleRateIndex := data[2] >> 2 & 0x0F
+		if int(sampleRateIndex) >= len(aacSampleRates) {
+			return frames, fmt.Errorf("invalid ADTS sample rate index %d", sampleRateIndex)
+		}
+		frames = append(frames, adtsFrame{
+			profile:         data[2] >> 6,
+			sampleRateIndex: sampleRateIndex,
+			channels:        data[2]&0x01<<2 | data[3]>>6,
+			payload:         data[headerLength:frameLength],
+		})
+		data = data[frameLength:]
+	}
+	return frames, nil
+}
+
+// audioSpecificConfig builds AudioSpecificConfig of MPEG-4 audio for esds box
+func (f adtsFrame) audioSpecificConfig() []byte {
+	objectType := f.profile + 1
+	return []byte{
+		objectType<<3 | f.sampleRateIndex>>1,
+		f.sampleRateIndex<<7 | f.channels<<3,
+	}
+}
@@ -0,0 +1,78 @@
+package mp4
+
+import (
+	"encoding/binary"
+)
+
+// box builds ISO BMFF box bytes in memory
+type box struct {
+	buf []byte
+}
+
+func newBox(typ string) *box {
+	b := &box{buf: make([]byte, 8, 64)}
+	copy(b.buf[4:8], typ)
+	return b
+}
+
+func newFullBox(typ string, version uint8, flags uint32) *box {
+	b := newBox(typ)
+	b.u32(uint32(version)<<24 | flags&0xFFFFFF)
+	return b
+}
+
+func (b *box) u8(v uint8) *box {
+	b.buf = append(b.buf, v)
+	return b
+}
+
+func (b *box) u16(v uint16) *box {
+	b.buf = binary.BigEndian.AppendUint16(b.buf, v)
+	return b
+}
+
+func (b *box) u32(v uint32) *box {
+	b.buf = binary.BigEndian.AppendUint32(b.buf, v)
+	return b
+}
+
+func (b *box) u64(v uint64) *box {
+	b.buf = binary.BigEndian.AppendUint64(b.buf, v)
+	return b
+}
+
+func (b *box) bytes(v []byte) *box {
+	b.buf = append(b.buf, v...)
+	return b
+}
+
+func (b *box) zeros(n int) *box {
+	b.buf = append(b.buf, make([]byte, n)...)
+	return b
+}
+
+// add appends child boxes
+func (b *box) add(children ...*box) *box {
+	for _, c := range children {
+		if c != nil {
+			b.buf = append(b.buf, c.build()...)
+		}
+	}
+	return b
+}
+
+// build fills box size and returns box bytes
+func (b *box) build() []byte {
+	binary.BigEndian.PutUint32(b.buf[0:4], uint32(len(b.buf)))
+	return b.buf
+}
+
+// unityMatrix is the identity transformation matrix in mvhd and tkhd
+var unityMatrix = []uint32{0x00010000, 0, 0, 0, 0x00010000, 0, 0, 0, 0x40000000}
+
+func (b *box) matrix() *box {
+	for _, v := range unityMatrix {
+		b.u32(v)
+	}
+	return b
+}
@@ -0,0 +1,285 @@
+package mp4
+
+import (
+	"errors"
+)
+
+// H.264 NAL unit types
+const (
+	nalIDR = 5
+	nalSPS = 7
+	nalPPS = 8
+	nalAUD = 9
+)
+
+var errBitReaderEOF = errors.New("unexpected end of SPS")
+
+// splitAnnexB splits H.264 Annex B byte stream into NAL units without start codes
+func splitAnnexB(data []byte) [][]byte {
+	var nalus [][]byte
+	start := -1
+	for i := 0; i+2 < len(data); {
+		if data[i] == 0 && data[i+1] == 0 && data[i+2] == 1 {
+			if start >= 0 {
+				end := i
+				// 4 bytes start code 00 00 00 01
+				if end > start && data[end-1] == 0 {
+					end--
+				}
+				nalus = append(nalus, data[start:end])
+			}
+			i += 3
+			start = i
+			continue
+		}
+		i++
+	}
+	if start >= 0 && start < len(data) {
+		nalus = append(nalus, data[start:])
+	}
+	return nalus
+}
+
+// bitReader reads exp-golomb coded SPS fields
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) bit() (uint, error) {
+	if r.pos >= len(r.data)*8 {
+		return 0, errBitReaderEOF
+	}
+	b := r.data[r.pos/8] >> (7 - r.pos%8) & 1
+	r.pos++
+	return uint(b), nil
+}
+
+func (r *bitReader) bits(n int) (uint, error) {
+	var v uint
+	for i := 0; i < n; i++ {
+		b, err := r.bit()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<1 | b
+	}
+	return v, nil
+}
+
+func (r *bitReader) ue() (uint, error) {
+	zeros := 0
+	for {
+		b, err := r.bit()
+		if err != nil {
+			return 0, err
+		}
+		if b == 1 {
+			break
+		}
+		zeros++
+	}
+	v, err := r.bits(zeros)
+	return 1<<zeros - 1 + v, err
+}
+
+func (r *bitReader) se() (int, error) {
+	v, err := r.ue()
+	if v%2 == 0 {
+		return -int(v / 2), err
+	}
+	return int(v+1) / 2, err
+}
+
+// removeEmulationPrevention removes 0x03 in 00 00 03 sequences of NAL unit
+func removeEmulationPrevention(nalu []byte) []byte {
+	out := make([]byte, 0, len(nalu))
+	zeros := 0
+	for _, b := range nalu {
+		if zeros >= 2 && b == 3 {
+			zeros = 0
+			continue
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// parseSPSResolution returns picture width and height from H.264 SPS NAL unit
+func parseSPSResolution(sps []byte) (width, height int, err error) {
+	r := &bitReader{data: removeEmulationPrevention(sps)}
+	// nal header
+	if _, err = r.bits(8); err != nil {
+		return
+	}
+	profileIdc, err := r.bits(8)
+	if err != nil {
+		return
+	}
+	// constraint flags and level
+	if _, err = r.bits(16); err != nil {
+		return
+	}
+	if _, err = r.ue(); err != nil {
+		return
+	}
+	chromaFormatIdc := uint(1)
+	switch profileIdc {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		if chromaFormatIdc, err = r.ue(); err != nil {
+			return
+		}
+		if chromaFormatIdc == 3 {
+			// separate_colour_plane_flag
+			if _, err = r.bit(); err != nil {
+				return
+			}
+		}
+		// bit_depth_luma, bit_depth_chroma
+		if _, err = r.ue(); err != nil {
+			return
+		}
+		if _, err = r.ue(); err != nil {
+			return
+		}
+		// qpprime_y_zero_transform_bypass_flag
+		if _, err = r.bit(); err != nil {
+			return
+		}
+		var scalingMatrixPresent uint
+		if scalingMatrixPresent, err = r.bit(); err != nil {
+			return
+		}
+		if scalingMatrixPresent == 1 {
+			count := 8
+			if chromaFormatIdc == 3 {
+				count = 12
+			}
+			for i := 0; i < count; i++ {
+				present, e := r.bit()
+				if e != nil {
+					return 0, 0, e
+				}
+				if present == 0 {
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				if err = skipScalingList(r, size); err != nil {
+					return
+				}
+			}
+		}
+	}
+	// log2_max_frame_num_minus4
+	if _, err = r.ue(); err != nil {
+		return
+	}
+	pocType, err := r.ue()
+	if err != nil {
+		return
+	}
+	switch pocType {
+	case 0:
+		if _, err = r.ue(); err != nil {
+			return
+		}
+	case 1:
+		// delta_pic_order_always_zero_flag
+		if _, err = r.bit(); err != nil {
+			return
+		}
+		if _, err = r.se(); err != nil {
+			return
+		}
+		if _, err = r.se(); err != nil {
+			return
+		}
+		var n uint
+		if n, err = r.ue(); err != nil {
+			return
+		}
+		for i := uint(0); i < n; i++ {
+			if _, err = r.se(); err != nil {
+				return
+			}
+		}
+	}
+	// max_num_ref_frames
+	if _, err = r.ue(); err != nil {
+		return
+	}
+	// gaps_in_frame_num_value_allowed_flag
+	if _, err = r.bit(); err != nil {
+		return
+	}
+	widthInMbs, err := r.ue()
+	if err != nil {
+		return
+	}
+	heightInMapUnits, err := r.ue()
+	if err != nil {
+		return
+	}
+	frameMbsOnly, err := r.bit()
+	if err != nil {
+		return
+	}
+	if frameMbsOnly == 0 {
+		// mb_adaptive_frame_field_flag
+		if _, err = r.bit(); err != nil {
+			return
+		}
+	}
+	// direct_8x8_inference_flag
+	if _, err = r.bit(); err != nil {
+		return
+	}
+	var cropLeft, cropRight, cropTop, cropBottom uint
+	cropping, err := r.bit()
+	if err != nil {
+		return
+	}
+	if cropping == 1 {
+		for _, v := range []*uint{&cropLeft, &cropRight, &cropTop, &cropBottom} {
+			if *v, err = r.ue(); err != nil {
+				return
+			}
+		}
+	}
+
+	cropUnitX, cropUnitY := uint(1), 2-frameMbsOnly
+	switch chromaFormatIdc {
+	case 1:
+		cropUnitX, cropUnitY = 2, 2*(2-frameMbsOnly)
+	case 2:
+		cropUnitX, cropUnitY = 2, 2-frameMbsOnly
+	}
+	width = int((widthInMbs+1)*16 - (cropLeft+cropRight)*cropUnitX)
+	height = int((2-frameMbsOnly)*(heightInMapUnits+1)*16 - (cropTop+cropBottom)*cropUnitY)
+	return width, height, nil
+}
+
+func skipScalingList(r *bitReader, size int) error {
+	last, next := 8, 8
+	for j := 0; j < size; j++ {
+		if next != 0 {
+			delta, err := r.se()
+			if err != nil {
+				return err
+			}
+			next = (last + delta + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+	return nil
+}
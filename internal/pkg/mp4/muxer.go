@@ -0,0 +1,642 @@
+// Package mp4 remuxes H.264 and AAC elementary streams demuxed from TS into a MP4 file,
+// the moov box is written before mdat so that the video can play before fully loaded (faststart).
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// tsTimescale is the 90kHz clock of PTS and DTS in TS
+	tsTimescale = 90000
+	// movieTimescale is the timescale of mvhd, tkhd, elst and text tracks
+	movieTimescale = 1000
+	// defaultFrameDuration is used for the last video sample, 25fps in 90kHz
+	defaultFrameDuration = 3600
+
+	// NoTimestamp means PES has no PTS
+	NoTimestamp = int64(-1)
+)
+
+// ErrNoSamples means there is no video or audio sample to write
+var ErrNoSamples = errors.New("no video or audio samples to remux")
+
+type sample struct {
+	// offset is the sample data offset in mdat payload
+	offset   int64
+	size     uint32
+	duration uint32
+	// cts is the composition time offset, pts - dts
+	cts      uint32
+	keyframe bool
+}
+
+// TextSample is a subtitle cue, times are relative to the start of video
+type TextSample struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+type track struct {
+	id        uint32
+	handler   string
+	timescale uint32
+	samples   []sample
+	// language is ISO 639-2/T code
+	language string
+
+	// unwrapped timestamps in 90kHz
+	firstPTS int64
+	firstDTS int64
+	lastDTS  int64
+	started  bool
+
+	// video
+	sps, pps      []byte
+	width, height int
+
+	// audio
+	asc        []byte
+	sampleRate uint32
+	channels   uint16
+}
+
+// Muxer collects samples into a temp file, then writes ftyp, moov and mdat in order
+type Muxer struct {
+	data     *os.File
+	dataSize int64
+	video    *track
+	audio    *track
+	texts    []*track
+
+	// pending text samples by text track, written into mdat when finishing
+	pendingTexts map[*track][]TextSample
+}
+
+// NewMuxer creates a muxer which keeps sample data in a temp file in tmpDir
+func NewMuxer(tmpDir string) (*Muxer, error) {
+	f, err := os.CreateTemp(tmpDir, "mdat-*")
+	if err != nil {
+		return nil, err
+	}
+	return &Muxer{data: f}, nil
+}
+
+// Close removes the temp sample data file
+func (m *Muxer) Close() error {
+	name := m.data.Name()
+	_ = m.data.Close()
+	return os.Remove(name)
+}
+
+// WriteVideo appends a H.264 access unit in Annex B format, pts and dts are in 90kHz.
+// Pass NoTimestamp as pts if PES has no PTS.
+func (m *Muxer) WriteVideo(pts, dts int64, data []byte) error {
+	if m.video == nil {
+		m.video = &track{handler: "vide", timescale: tsTimescale}
+	}
+	t := m.video
+
+	var payload []byte
+	keyframe := false
+	for _, nalu := range splitAnnexB(data) {
+		if len(nalu) == 0 {
+			continue
+		}
+		switch nalu[0] & 0x1F {
+		case nalSPS:
+			if t.sps == nil {
+				width, height, err := parseSPSResolution(nalu)
+				if err != nil {
+					return err
+				}
+				t.sps, t.width, t.height = append([]byte(nil), nalu...), width, height
+			}
+			continue
+		case nalPPS:
+			if t.pps == nil {
+				t.pps = append([]byte(nil), nalu...)
+			}
+			continue
+		case nalAUD:
+			continue
+		case nalIDR:
+			keyframe = true
+		}
+		payload = append(payload, byte(len(nalu)>>24), byte(len(nalu)>>16), byte(len(nalu)>>8), byte(len(nalu)))
+		payload = append(payload, nalu...)
+	}
+	if len(payload) == 0 {
+		return nil
+	}
+	// samples before the first keyframe can not be decoded
+	if !t.started && !keyframe {
+		return nil
+	}
+
+	if pts == NoTimestamp {
+		if !t.started {
+			return nil
+		}
+		dts = t.lastDTS + int64(t.samples[len(t.samples)-1].duration)
+		pts = dts
+	} else if t.started {
+		dts = unwrap(dts, t.lastDTS)
+		pts = unwrap(pts, dts)
+	}
+	if pts < dts {
+		pts = dts
+	}
+
+	if !t.started {
+		t.started, t.firstPTS, t.firstDTS = true, pts, dts
+	} else {
+		// the duration of previous sample is known now
+		prev := &t.samples[len(t.samples)-1]
+		if d := dts - t.lastDTS; d > 0 {
+			prev.duration = uint32(d)
+		} else {
+			prev.duration = 1
+		}
+	}
+	t.lastDTS = dts
+
+	offset, err := m.writeData(payload)
+	if err != nil {
+		return err
+	}
+	t.samples = append(t.samples, sample{
+		offset:   offset,
+		size:     uint32(len(payload)),
+		duration: defaultFrameDuration,
+		cts:      uint32(pts - dts),
+		keyframe: keyframe,
+	})
+	return nil
+}
+
+// AddTextTrack adds a tx3g subtitle track, lang is language code like zh-CN or eng
+func (m *Muxer) AddTextTrack(lang string, samples []TextSample) {
+	if len(samples) == 0 {
+		return
+	}
+	t := &track{handler: "sbtl", timescale: movieTimescale, language: languageCode(lang)}
+	if m.pendingTexts == nil {
+		m.pendingTexts = make(map[*track][]TextSample)
+	}
+	m.texts = append(m.texts, t)
+	m.pendingTexts[t] = samples
+}
+
+// writeTextSamples writes text samples with empty samples in gaps between them
+func (m *Muxer) writeTextSamples(t *track, samples []TextSample) error {
+	sorted := append([]TextSample(nil), samples...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	var position int64
+	write := func(text string, duration int64) error {
+		if duration <= 0 {
+			return nil
+		}
+		payload := binary.BigEndian.AppendUint16(nil, uint16(len(text)))
+		payload = append(payload, text...)
+		offset, err := m.writeData(payload)
+		if err != nil {
+			return err
+		}
+		t.samples = append(t.samples, sample{
+			offset:   offset,
+			size:     uint32(len(payload)),
+			duration: uint32(duration),
+			keyframe: true,
+		})
+		position += duration
+		return nil
+	}
+	for _, s := range sorted {
+		start, end := s.Start.Milliseconds(), s.End.Milliseconds()
+		// overlapped cue starts after the previous one
+		if start < position {
+			start = position
+		}
+		if err := write("", start-position); err != nil {
+			return err
+		}
+		if err := write(s.Text, end-start); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteAudio appends AAC frames in ADTS format, pts is in 90kHz.
+// Pass NoTimestamp as pts if PES has no PTS.
+func (m *Muxer) WriteAudio(pts int64, data []byte) error {
+	frames, err := splitADTS(data)
+	if err != nil {
+		return err
+	}
+	if len(frames) == 0 {
+		return nil
+	}
+	if m.audio == nil {
+		m.audio = &track{handler: "soun"}
+	}
+	t := m.audio
+	if !t.started {
+		if pts == NoTimestamp {
+			return nil
+		}
+		f := frames[0]
+		t.started, t.firstPTS = true, pts
+		t.asc = f.audioSpecificConfig()
+		t.sampleRate = aacSampleRates[f.sampleRateIndex]
+		t.timescale = t.sampleRate
+		t.channels = uint16(f.channels)
+	}
+
+	// AAC frames are continuous, every frame has fixed duration
+	for _, f := range frames {
+		offset, err := m.writeData(f.payload)
+		if err != nil {
+			return err
+		}
+		t.samples = append(t.samples, sample{
+			offset:   offset,
+			size:     uint32(len(f.payload)),
+			duration: samplesPerAACFrame,
+			keyframe: true,
+		})
+	}
+	return nil
+}
+
+func (m *Muxer) writeData(payload []byte) (int64, error) {
+	offset := m.dataSize
+	n, err := m.data.Write(payload)
+	m.dataSize += int64(n)
+	return offset, err
+}
+
+// Finish writes the MP4 file to dst, with moov box in front of mdat
+func (m *Muxer) Finish(dst string) error {
+	for _, t := range m.texts {
+		if err := m.writeTextSamples(t, m.pendingTexts[t]); err != nil {
+			return err
+		}
+	}
+	m.pendingTexts = nil
+
+	tracks := m.tracks()
+	if len(tracks) == 0 {
+		return ErrNoSamples
+	}
+	if m.video != nil && len(m.video.samples) > 0 && (m.video.sps == nil || m.video.pps == nil) {
+		return fmt.Errorf("no SPS or PPS found in video stream")
+	}
+
+	ftyp := newBox("ftyp").
+		bytes([]byte("isom")).u32(0x200).
+		bytes([]byte("isomiso2avc1mp41")).
+		build()
+	// mdat always uses 64 bits large size
+	const mdatHeaderLength = 16
+
+	// moov size does not depend on chunk offsets, so build it twice to get the mdat offset
+	moov := m.moov(tracks, 0)
+	dataOffset := int64(len(ftyp) + len(moov) + mdatHeaderLength)
+	moov = m.moov(tracks, dataOffset)
+
+	f, err := os.OpenFile(dst, os.O_TRUNC|os.O_WRONLY|os.O_CREATE, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	if _, err := f.Write(ftyp); err != nil {
+		return err
+	}
+	if _, err := f.Write(moov); err != nil {
+		return err
+	}
+	mdat := newBox("mdat").u64(uint64(mdatHeaderLength + m.dataSize)).buf
+	mdat[3] = 1
+	if _, err := f.Write(mdat); err != nil {
+		return err
+	}
+	if _, err := m.data.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, m.data); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func (m *Muxer) tracks() []*track {
+	var tracks []*track
+	for _, t := range append([]*track{m.video, m.audio}, m.texts...) {
+		if t != nil && len(t.samples) > 0 {
+			t.id = uint32(len(tracks) + 1)
+			tracks = append(tracks, t)
+		}
+	}
+	return tracks
+}
+
+// startPTS is the earliest presentation time of video and audio tracks in 90kHz
+func startPTS(tracks []*track) int64 {
+	var start int64
+	found := false
+	for _, t := range tracks {
+		if t.handler == "sbtl" {
+			continue
+		}
+		if !found || t.firstPTS < start {
+			start, found = t.firstPTS, true
+		}
+	}
+	return start
+}
+
+func (m *Muxer) moov(tracks []*track, dataOffset int64) []byte {
+	start := startPTS(tracks)
+	// subtitles start with the movie
+	for _, t := range tracks {
+		if t.handler == "sbtl" {
+			t.firstPTS = start
+		}
+	}
+	var movieDuration uint64
+	traks := make([]*box, 0, len(tracks))
+	for _, t := range tracks {
+		trak, duration := t.trak(start, dataOffset)
+		if duration > movieDuration {
+			movieDuration = duration
+		}
+		traks = append(traks, trak)
+	}
+
+	mvhd := newFullBox("mvhd", 1, 0).
+		u64(0).u64(0).
+		u32(movieTimescale).u64(movieDuration).
+		u32(0x00010000).u16(0x0100).zeros(10).
+		matrix().
+		zeros(24).
+		u32(uint32(len(tracks) + 1))
+	return newBox("moov").add(mvhd).add(traks...).build()
+}
+
+func (t *track) mediaDuration() uint64 {
+	var d uint64
+	for _, s := range t.samples {
+		d += uint64(s.duration)
+	}
+	return d
+}
+
+// trak builds trak box, returns it with track duration in movie timescale
+func (t *track) trak(start, dataOffset int64) (*box, uint64) {
+	mediaDuration := t.mediaDuration()
+	// media time of the first presented sample
+	var mediaTime uint64
+	if t.handler == "vide" {
+		mediaTime = uint64(t.firstPTS - t.firstDTS)
+	}
+	emptyDuration := uint64(t.firstPTS-start) * movieTimescale / tsTimescale
+	presentDuration := (mediaDuration - mediaTime) * movieTimescale / uint64(t.timescale)
+	trackDuration := emptyDuration + presentDuration
+
+	elst := newFullBox("elst", 1, 0)
+	if emptyDuration > 0 {
+		elst.u32(2).u64(emptyDuration).u64(^uint64(0)).u32(0x00010000)
+	} else {
+		elst.u32(1)
+	}
+	elst.u64(presentDuration).u64(mediaTime).u32(0x00010000)
+
+	var width, height, volume uint32
+	switch t.handler {
+	case "vide":
+		width, height = uint32(t.width)<<16, uint32(t.height)<<16
+	case "soun":
+		volume = 0x0100
+	}
+	// flags: track enabled and in movie
+	tkhd := newFullBox("tkhd", 1, 0x3).
+		u64(0).u64(0).
+		u32(t.id).u32(0).u64(trackDuration).
+		zeros(8).u16(0).u16(0).u16(uint16(volume)).u16(0).
+		matrix().
+		u32(width).u32(height)
+
+	mdhd := newFullBox("mdhd", 1, 0).
+		u64(0).u64(0).
+		u32(t.timescale).u64(mediaDuration).
+		u16(packLanguage(t.language)).u16(0)
+
+	var name string
+	var mhd *box
+	switch t.handler {
+	case "vide":
+		name, mhd = "VideoHandler", newFullBox("vmhd", 0, 1).zeros(8)
+	case "soun":
+		name, mhd = "SoundHandler", newFullBox("smhd", 0, 0).zeros(4)
+	default:
+		name, mhd = "SubtitleHandler", newFullBox("nmhd", 0, 0)
+	}
+	hdlr := newFullBox("hdlr", 0, 0).
+		u32(0).bytes([]byte(t.handler)).zeros(12).
+		bytes(append([]byte(name), 0))
+
+	dinf := newBox("dinf").add(
+		newFullBox("dref", 0, 0).u32(1).add(newFullBox("url ", 0, 1)),
+	)
+
+	minf := newBox("minf").add(mhd, dinf, t.stbl(dataOffset))
+	mdia := newBox("mdia").add(mdhd, hdlr, minf)
+	return newBox("trak").add(tkhd, newBox("edts").add(elst), mdia), trackDuration
+}
+
+func (t *track) stbl(dataOffset int64) *box {
+	stsd := newFullBox("stsd", 0, 0).u32(1)
+	switch t.handler {
+	case "vide":
+		stsd.add(t.avc1())
+	case "soun":
+		stsd.add(t.mp4a())
+	default:
+		stsd.add(tx3g())
+	}
+
+	// run length encoded sample durations and composition offsets
+	stts := newFullBox("stts", 0, 0)
+	ctts := newFullBox("ctts", 0, 0)
+	var sttsEntries, cttsEntries uint32
+	hasCTS := false
+	for i := 0; i < len(t.samples); {
+		j := i + 1
+		for j < len(t.samples) && t.samples[j].duration == t.samples[i].duration {
+			j++
+		}
+		stts.u32(uint32(j - i)).u32(t.samples[i].duration)
+		sttsEntries++
+		i = j
+	}
+	for i := 0; i < len(t.samples); {
+		j := i + 1
+		for j < len(t.samples) && t.samples[j].cts == t.samples[i].cts {
+			j++
+		}
+		if t.samples[i].cts != 0 {
+			hasCTS = true
+		}
+		ctts.u32(uint32(j - i)).u32(t.samples[i].cts)
+		cttsEntries++
+		i = j
+	}
+	insertCount(stts, sttsEntries)
+	insertCount(ctts, cttsEntries)
+
+	stbl := newBox("stbl").add(stsd, stts)
+	if hasCTS {
+		stbl.add(ctts)
+	}
+
+	if t.handler == "vide" {
+		stss := newFullBox("stss", 0, 0)
+		var keyframes uint32
+		for i, s := range t.samples {
+			if s.keyframe {
+				stss.u32(uint32(i + 1))
+				keyframes++
+			}
+		}
+		insertCount(stss, keyframes)
+		stbl.add(stss)
+	}
+
+	// every sample is a chunk since video and audio samples are interleaved in mdat
+	stsc := newFullBox("stsc", 0, 0).u32(1).u32(1).u32(1).u32(1)
+	stsz := newFullBox("stsz", 0, 0).u32(0).u32(uint32(len(t.samples)))
+	co64 := newFullBox("co64", 0, 0).u32(uint32(len(t.samples)))
+	for _, s := range t.samples {
+		stsz.u32(s.size)
+		co64.u64(uint64(dataOffset + s.offset))
+	}
+	return stbl.add(stsc, stsz, co64)
+}
+
+// insertCount inserts entry count after full box header
+func insertCount(b *box, count uint32) {
+	const fullBoxHeaderLength = 12
+	rest := append([]byte(nil), b.buf[fullBoxHeaderLength:]...)
+	b.buf = append(b.buf[:fullBoxHeaderLength], byte(count>>24), byte(count>>16), byte(count>>8), byte(count))
+	b.buf = append(b.buf, rest...)
+}
+
+func (t *track) avc1() *box {
+	avcC := newBox("avcC").
+		u8(1).u8(t.sps[1]).u8(t.sps[2]).u8(t.sps[3]).
+		u8(0xFF). // 4 bytes NAL unit length
+		u8(0xE1).u16(uint16(len(t.sps))).bytes(t.sps).
+		u8(1).u16(uint16(len(t.pps))).bytes(t.pps)
+
+	return newBox("avc1").
+		zeros(6).u16(1).
+		zeros(16).
+		u16(uint16(t.width)).u16(uint16(t.height)).
+		u32(0x00480000).u32(0x00480000).
+		u32(0).u16(1).
+		zeros(32).
+		u16(0x0018).u16(0xFFFF).
+		add(avcC)
+}
+
+func (t *track) mp4a() *box {
+	decoderSpecificInfo := descriptor(0x05, t.asc)
+	decoderConfig := descriptor(0x04, append([]byte{
+		0x40,    // MPEG-4 audio
+		0x15,    // audio stream
+		0, 0, 0, // buffer size
+		0, 0, 0, 0, // max bitrate
+		0, 0, 0, 0, // avg bitrate
+	}, decoderSpecificInfo...))
+	slConfig := descriptor(0x06, []byte{0x02})
+	es := descriptor(0x03, append(append([]byte{0, 0, 0}, decoderConfig...), slConfig...))
+
+	return newBox("mp4a").
+		zeros(6).u16(1).
+		zeros(8).
+		u16(t.channels).u16(16).
+		zeros(4).
+		u32(t.sampleRate << 16).
+		add(newFullBox("esds", 0, 0).bytes(es))
+}
+
+// tx3g builds 3GPP timed text sample entry, white text at bottom center
+func tx3g() *box {
+	ftab := newBox("ftab").u16(1).u16(1).u8(uint8(len("Sans-Serif"))).bytes([]byte("Sans-Serif"))
+	return newBox("tx3g").
+		zeros(6).u16(1).
+		u32(0).          // display flags
+		u8(1).u8(0xFF).  // horizontal center, vertical bottom
+		u32(0).          // background color
+		zeros(8).        // default text box
+		u16(0).u16(0).   // style record start and end char
+		u16(1).u8(0).    // font id and face style
+		u8(18).          // font size
+		u32(0xFFFFFFFF). // text color
+		add(ftab)
+}
+
+// languageCode converts language like zh-CN, en or chi into ISO 639-2/T code
+func languageCode(lang string) string {
+	lang = strings.ToLower(lang)
+	switch {
+	case strings.HasPrefix(lang, "zh"), strings.Contains(lang, "中"):
+		return "zho"
+	case strings.HasPrefix(lang, "en"), strings.Contains(lang, "英"):
+		return "eng"
+	case len(lang) == 3 && strings.Trim(lang, "abcdefghijklmnopqrstuvwxyz") == "":
+		return lang
+	default:
+		return "und"
+	}
+}
+
+// packLanguage packs ISO 639-2/T code into 15 bits of mdhd
+func packLanguage(code string) uint16 {
+	if len(code) != 3 {
+		code = "und"
+	}
+	return uint16(code[0]-0x60)<<10 | uint16(code[1]-0x60)<<5 | uint16(code[2]-0x60)
+}
+
+// descriptor builds MPEG-4 descriptor of esds box
+func descriptor(tag byte, payload []byte) []byte {
+	size := len(payload)
+	return append([]byte{tag, 0x80 | byte(size>>21&0x7F), 0x80 | byte(size>>14&0x7F), 0x80 | byte(size>>7&0x7F), byte(size & 0x7F)}, payload...)
+}
+
+// unwrap adjusts 33 bits timestamp which wraps around, to be close to reference
+func unwrap(ts, reference int64) int64 {
+	const wrap = int64(1) << 33
+	for ts+wrap/2 < reference {
+		ts += wrap
+	}
+	for ts-wrap/2 > reference {
+		ts -= wrap
+	}
+	return ts
+}
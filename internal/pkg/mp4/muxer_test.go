@@ -0,0 +1,153 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type bitWriter struct {
+	data  []byte
+	nbits int
+}
+
+func (w *bitWriter) bit(b uint) {
+	if w.nbits%8 == 0 {
+		w.data = append(w.data, 0)
+	}
+	if b != 0 {
+		w.data[len(w.data)-1] |= 1 << (7 - w.nbits%8)
+	}
+	w.nbits++
+}
+
+func (w *bitWriter) bits(v uint, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.bit(v >> i & 1)
+	}
+}
+
+func (w *bitWriter) ue(v uint) {
+	v++
+	n := 0
+	for x := v; x > 1; x >>= 1 {
+		n++
+	}
+	w.bits(0, n)
+	w.bits(v, n+1)
+}
+
+// testSPS is a baseline profile SPS of 640x360 video
+func testSPS() []byte {
+	w := &bitWriter{}
+	w.bits(0x67, 8)
+	w.bits(66, 8) // profile
+	w.bits(0, 8)
+	w.bits(30, 8) // level
+	w.ue(0)       // sps id
+	w.ue(0)       // log2_max_frame_num_minus4
+	w.ue(2)       // pic_order_cnt_type
+	w.ue(1)       // max_num_ref_frames
+	w.bit(0)
+	w.ue(39) // 640 / 16 - 1
+	w.ue(22) // 368 / 16 - 1
+	w.bit(1) // frame_mbs_only_flag
+	w.bit(1)
+	w.bit(1) // frame_cropping_flag
+	w.ue(0)
+	w.ue(0)
+	w.ue(0)
+	w.ue(4)  // crop 8 lines at bottom
+	w.bit(0) // vui
+	w.bit(1) // rbsp stop bit
+	return w.data
+}
+
+func TestParseSPSResolution(t *testing.T) {
+	width, height, err := parseSPSResolution(testSPS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if width != 640 || height != 360 {
+		t.Fatalf("expected 640x360, got %dx%d", width, height)
+	}
+}
+
+func TestMuxerFaststart(t *testing.T) {
+	dir := t.TempDir()
+	m, err := NewMuxer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = m.Close()
+	}()
+
+	startCode := []byte{0, 0, 0, 1}
+	au := func(nalus ...[]byte) []byte {
+		var b []byte
+		for _, n := range nalus {
+			b = append(append(b, startCode...), n...)
+		}
+		return b
+	}
+	idr := []byte{0x65, 0x88, 0x84, 0x00}
+	slice := []byte{0x41, 0x9a, 0x02}
+	if err := m.WriteVideo(9000, 6000, au([]byte{0x09, 0xF0}, testSPS(), []byte{0x68, 0xCE, 0x38, 0x80}, idr)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteVideo(15000, 9600, au(slice)); err != nil {
+		t.Fatal(err)
+	}
+
+	// AAC LC 44100Hz stereo, 2 bytes payload
+	adts := []byte{0xFF, 0xF1, 0x50, 0x80, 0x01, 0x3F, 0xFC, 0xAA, 0xBB}
+	if err := m.WriteAudio(9000, append(adts, adts...)); err != nil {
+		t.Fatal(err)
+	}
+
+	m.AddTextTrack("zh-CN", []TextSample{{Start: 20 * time.Millisecond, End: 60 * time.Millisecond, Text: "你好"}})
+
+	dst := filepath.Join(dir, "out.mp4")
+	if err := m.Finish(dst); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var order []string
+	var mdatOffset int
+	for pos := 0; pos < len(data); {
+		size := int(binary.BigEndian.Uint32(data[pos:]))
+		typ := string(data[pos+4 : pos+8])
+		if size == 1 {
+			size = int(binary.BigEndian.Uint64(data[pos+8:]))
+		}
+		if typ == "mdat" {
+			mdatOffset = pos + 16
+		}
+		order = append(order, typ)
+		pos += size
+	}
+	if len(order) != 3 || order[0] != "ftyp" || order[1] != "moov" || order[2] != "mdat" {
+		t.Fatalf("unexpected box order %v", order)
+	}
+
+	// first chunk offset of video track points to the IDR sample
+	i := bytes.Index(data, []byte("co64"))
+	if i < 0 {
+		t.Fatal("co64 not found")
+	}
+	offset := int(binary.BigEndian.Uint64(data[i+12:]))
+	if offset != mdatOffset || !bytes.Equal(data[offset+4:offset+4+len(idr)], idr) {
+		t.Fatalf("unexpected first sample offset %d, mdat payload at %d", offset, mdatOffset)
+	}
+	if !bytes.Contains(data, []byte("avcC")) || !bytes.Contains(data, []byte("esds")) || !bytes.Contains(data, []byte("tx3g")) {
+		t.Fatal("missing sample descriptions")
+	}
+}
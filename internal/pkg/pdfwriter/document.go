@@ -0,0 +1,344 @@
+// Package pdfwriter writes simple PDF documents with text, rectangles, lines, images and links.
+// Chinese text uses the Adobe standard CJK font STSong-Light which is provided by PDF readers,
+// so no font file is embedded and nothing outside Go is needed to create the document.
+package pdfwriter
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+// A4 page size in points, coordinates of page start from its top left corner
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+// Color is RGB color, each component is between 0 and 1
+type Color struct {
+	R, G, B float64
+}
+
+// RGB returns color of hex value like 0x336699
+func RGB(hex uint32) Color {
+	return Color{
+		R: float64(hex>>16&0xFF) / 255,
+		G: float64(hex>>8&0xFF) / 255,
+		B: float64(hex&0xFF) / 255,
+	}
+}
+
+// Document is a PDF document being built in memory
+type Document struct {
+	Title  string
+	Author string
+
+	pages  []*Page
+	images []*Image
+}
+
+// Page is a page of document
+type Page struct {
+	content bytes.Buffer
+	links   []link
+	images  map[*Image]bool
+}
+
+type link struct {
+	x, y, w, h float64
+	uri        string
+}
+
+// New creates an empty document
+func New() *Document {
+	return &Document{}
+}
+
+// AddPage appends a new page to document
+func (d *Document) AddPage() *Page {
+	p := &Page{images: make(map[*Image]bool)}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// PageCount returns number of pages
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+// Text draws text with its baseline at y, text must be in one line
+func (p *Page) Text(x, y float64, text string, font Font, size float64, color Color) {
+	fmt.Fprintf(&p.content, "BT %s rg ", color)
+	cx := x
+	for len(text) > 0 {
+		// split text into runs of ASCII and others, each run uses its own font
+		ascii := isASCII(firstRune(text))
+		end := len(text)
+		for i, r := range text {
+			if isASCII(r) != ascii && r != '\t' {
+				end = i
+				break
+			}
+		}
+		run := strings.ReplaceAll(text[:end], "\t", " ")
+		text = text[end:]
+
+		fmt.Fprintf(&p.content, "1 0 0 1 %s %s Tm ", num(cx), num(PageHeight-y))
+		if ascii {
+			fmt.Fprintf(&p.content, "/F%d %s Tf %s Tj ", int(font)+1, num(size), literal(run))
+		} else {
+			if font == FontBold {
+				// STSong-Light has no bold face, stroke the outline to make it thicker
+				fmt.Fprintf(&p.content, "2 Tr %s RG %s w ", color, num(size/30))
+			}
+			fmt.Fprintf(&p.content, "/F0 %s Tf %s Tj ", num(size), cjkHex(run))
+			if font == FontBold {
+				p.content.WriteString("0 Tr ")
+			}
+		}
+		cx += TextWidth(run, font, size)
+	}
+	p.content.WriteString("ET\n")
+}
+
+// Rect fills rectangle with color
+func (p *Page) Rect(x, y, w, h float64, color Color) {
+	fmt.Fprintf(&p.content, "%s rg %s %s %s %s re f\n", color, num(x), num(PageHeight-y-h), num(w), num(h))
+}
+
+// Line strokes line from (x1, y1) to (x2, y2)
+func (p *Page) Line(x1, y1, x2, y2, width float64, color Color) {
+	fmt.Fprintf(&p.content, "%s RG %s w %s %s m %s %s l S\n",
+		color, num(width), num(x1), num(PageHeight-y1), num(x2), num(PageHeight-y2))
+}
+
+// Image draws image into rectangle
+func (p *Page) Image(img *Image, x, y, w, h float64) {
+	p.images[img] = true
+	fmt.Fprintf(&p.content, "q %s 0 0 %s %s %s cm /Im%d Do Q\n", num(w), num(h), num(x), num(PageHeight-y-h), img.id)
+}
+
+// Link makes rectangle a link to uri
+func (p *Page) Link(x, y, w, h float64, uri string) {
+	p.links = append(p.links, link{x, y, w, h, uri})
+}
+
+// WriteFile writes document into file
+func (d *Document) WriteFile(name string) error {
+	return writeFile(name, d.Write)
+}
+
+func writeFile(name string, write func(w io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	if err := write(bw); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Write writes document to w
+func (d *Document) Write(w io.Writer) error {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	o := &objectWriter{}
+	catalog, pages, info := o.reserve(), o.reserve(), o.reserve()
+
+	fonts := make([]int, 0, len(baseFonts)+1)
+	cjk := o.add(d.cjkFont(o))
+	fonts = append(fonts, cjk)
+	for f := FontRegular; f <= FontMono; f++ {
+		fonts = append(fonts, o.add(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", baseFonts[f])))
+	}
+	var fontRefs strings.Builder
+	for i, id := range fonts {
+		fmt.Fprintf(&fontRefs, "/F%d %d 0 R ", i, id)
+	}
+
+	imageRefs := make(map[*Image]int, len(d.images))
+	for _, img := range d.images {
+		imageRefs[img] = o.addStream(img.dict, img.data, false)
+	}
+
+	kids := make([]string, len(d.pages))
+	for i, p := range d.pages {
+		contents := o.addStream("", p.content.Bytes(), true)
+
+		var xobjects strings.Builder
+		for _, img := range d.images {
+			if p.images[img] {
+				fmt.Fprintf(&xobjects, "/Im%d %d 0 R ", img.id, imageRefs[img])
+			}
+		}
+		var annots strings.Builder
+		for _, l := range p.links {
+			id := o.add(fmt.Sprintf("<< /Type /Annot /Subtype /Link /Rect [%s %s %s %s] /Border [0 0 0] /A << /S /URI /URI %s >> >>",
+				num(l.x), num(PageHeight-l.y-l.h), num(l.x+l.w), num(PageHeight-l.y), literal(l.uri)))
+			fmt.Fprintf(&annots, "%d 0 R ", id)
+		}
+
+		page := fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Contents %d 0 R /Resources << /Font << %s>> /XObject << %s>> >>",
+			pages, num(PageWidth), num(PageHeight), contents, fontRefs.String(), xobjects.String())
+		if annots.Len() > 0 {
+			page += fmt.Sprintf(" /Annots [%s]", annots.String())
+		}
+		kids[i] = fmt.Sprintf("%d 0 R", o.add(page+" >>"))
+	}
+
+	o.set(pages, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+	o.set(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pages))
+	o.set(info, fmt.Sprintf("<< /Title %s /Author %s /Producer (geektime-downloader) >>", textString(d.Title), textString(d.Author)))
+	return o.writeTo(w, catalog, info)
+}
+
+// cjkFont returns Type0 font dictionary of STSong-Light
+func (d *Document) cjkFont(o *objectWriter) string {
+	descriptor := o.add("<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] " +
+		"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>")
+	cidFont := o.add(fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light "+
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >> /FontDescriptor %d 0 R /DW %d >>", descriptor, cjkWidth))
+	return fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light-UniGB-UCS2-H /Encoding /UniGB-UCS2-H /DescendantFonts [%d 0 R] >>", cidFont)
+}
+
+func (c Color) String() string {
+	return fmt.Sprintf("%s %s %s", num(c.R), num(c.G), num(c.B))
+}
+
+// num formats number with at most 2 decimals
+func num(f float64) string {
+	s := fmt.Sprintf("%.2f", f)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" || s == "" {
+		return "0"
+	}
+	return s
+}
+
+func firstRune(s string) rune {
+	for _, r := range s {
+		return r
+	}
+	return 0
+}
+
+// literal returns PDF literal string of ASCII text
+func literal(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`, "\r", `\r`, "\n", `\n`)
+	return "(" + r.Replace(s) + ")"
+}
+
+// textString returns PDF text string in UTF-16BE with byte order mark, used by metadata
+func textString(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// cjkHex returns hex string of text encoded by UniGB-UCS2-H, runes out of BMP are replaced by a box
+func cjkHex(s string) string {
+	var b strings.Builder
+	b.WriteString("<")
+	for _, r := range s {
+		if r > 0xFFFF {
+			r = '□'
+		}
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// objectWriter collects indirect objects and writes them with cross reference table
+type objectWriter struct {
+	objects [][]byte
+}
+
+func (o *objectWriter) reserve() int {
+	o.objects = append(o.objects, nil)
+	return len(o.objects)
+}
+
+func (o *objectWriter) set(id int, body string) {
+	o.objects[id-1] = []byte(body)
+}
+
+func (o *objectWriter) add(body string) int {
+	id := o.reserve()
+	o.set(id, body)
+	return id
+}
+
+// addStream adds stream object, dict is the entries of stream dictionary except Length
+func (o *objectWriter) addStream(dict string, data []byte, compress bool) int {
+	if compress {
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		_, _ = zw.Write(data)
+		_ = zw.Close()
+		data = buf.Bytes()
+		dict += " /Filter /FlateDecode"
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<< %s /Length %d >>\nstream\n", strings.TrimSpace(dict), len(data))
+	b.Write(data)
+	b.WriteString("\nendstream")
+	id := o.reserve()
+	o.objects[id-1] = b.Bytes()
+	return id
+}
+
+func (o *objectWriter) writeTo(w io.Writer, catalog, info int) error {
+	cw := &countWriter{w: w}
+	// binary comment tells transfer tools the file is binary
+	_, _ = cw.Write([]byte("%PDF-1.7\n%\xE2\xE3\xCF\xD3\n"))
+	offsets := make([]int64, len(o.objects))
+	for i, body := range o.objects {
+		offsets[i] = cw.n
+		fmt.Fprintf(cw, "%d 0 obj\n", i+1)
+		_, _ = cw.Write(body)
+		_, _ = cw.Write([]byte("\nendobj\n"))
+	}
+	xref := cw.n
+	fmt.Fprintf(cw, "xref\n0 %d\n0000000000 65535 f \n", len(o.objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(cw, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(cw, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(o.objects)+1, catalog, info, xref)
+	return cw.err
+}
+
+// countWriter counts written bytes and keeps the first error
+type countWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
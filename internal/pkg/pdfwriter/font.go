@@ -0,0 +1,79 @@
+package pdfwriter
+
+// Font is one of the fonts every document has
+type Font int
+
+const (
+	// FontRegular is Helvetica for ASCII and STSong-Light for others
+	FontRegular Font = iota
+	// FontBold is Helvetica-Bold for ASCII and STSong-Light stroked for others
+	FontBold
+	// FontItalic is Helvetica-Oblique for ASCII and STSong-Light for others
+	FontItalic
+	// FontMono is Courier for ASCII and STSong-Light for others
+	FontMono
+)
+
+// cjkWidth is the width of every glyph in STSong-Light, in 1/1000 em
+const cjkWidth = 1000
+
+// standard 14 fonts used for ASCII, they are never embedded
+var baseFonts = map[Font]string{
+	FontRegular: "Helvetica",
+	FontBold:    "Helvetica-Bold",
+	FontItalic:  "Helvetica-Oblique",
+	FontMono:    "Courier",
+}
+
+// widths of ASCII 0x20 to 0x7E in Adobe font metrics, in 1/1000 em
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
+
+// isASCII reports whether r is drawn by the standard font
+func isASCII(r rune) bool {
+	return r >= 0x20 && r <= 0x7E
+}
+
+// RuneWidth returns width of r in font of size
+func RuneWidth(r rune, font Font, size float64) float64 {
+	if r == '\t' {
+		r = ' '
+	}
+	if !isASCII(r) {
+		return cjkWidth * size / 1000
+	}
+	var w int
+	switch font {
+	case FontMono:
+		w = 600
+	case FontBold:
+		w = helveticaBoldWidths[r-0x20]
+	default:
+		w = helveticaWidths[r-0x20]
+	}
+	return float64(w) * size / 1000
+}
+
+// TextWidth returns width of text in font of size
+func TextWidth(text string, font Font, size float64) float64 {
+	var w float64
+	for _, r := range text {
+		w += RuneWidth(r, font, size)
+	}
+	return w
+}
@@ -0,0 +1,72 @@
+package pdfwriter
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+
+	// decoders of images in article
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// Image is an image added to document, it can be drawn on any page
+type Image struct {
+	Width  int
+	Height int
+
+	id   int
+	dict string
+	data []byte
+}
+
+// AddImage decodes image data in JPEG, PNG or GIF format and adds it to document
+func (d *Document) AddImage(data []byte) (*Image, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	img := &Image{Width: cfg.Width, Height: cfg.Height, id: len(d.images) + 1}
+
+	// baseline JPEG is embedded as it is
+	if format == "jpeg" && (cfg.ColorModel == color.YCbCrModel || cfg.ColorModel == color.GrayModel) {
+		colorSpace := "/DeviceRGB"
+		if cfg.ColorModel == color.GrayModel {
+			colorSpace = "/DeviceGray"
+		}
+		img.dict = fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 /Filter /DCTDecode",
+			cfg.Width, cfg.Height, colorSpace)
+		img.data = data
+		d.images = append(d.images, img)
+		return img, nil
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	// other images are converted to RGB, transparent pixels are drawn on white background
+	b := decoded.Bounds()
+	pixels := make([]byte, 0, b.Dx()*b.Dy()*3)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := decoded.At(x, y).RGBA()
+			white := 0xFFFF - a
+			pixels = append(pixels, byte((r+white)>>8), byte((g+white)>>8), byte((bl+white)>>8))
+		}
+	}
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, _ = zw.Write(pixels)
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	img.dict = fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode",
+		b.Dx(), b.Dy())
+	img.data = buf.Bytes()
+	d.images = append(d.images, img)
+	return img, nil
+}
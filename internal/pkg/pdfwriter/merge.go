@@ -0,0 +1,244 @@
+package pdfwriter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ErrNoPages means nothing is appended to merger
+var ErrNoPages = errors.New("no pages to merge")
+
+// pageNumberFont is the resource name of page number font added to merged pages
+const pageNumberFont = "GkPageNumber"
+
+// Outline is a bookmark of merged document which jumps to a page
+type Outline struct {
+	Title string
+	// Page is zero based page index in merged document
+	Page     int
+	Children []*Outline
+}
+
+// Merger concatenates pages of PDF files into one document
+type Merger struct {
+	Title    string
+	Author   string
+	Outlines []*Outline
+	// PageNumbers adds page number at the bottom of pages, counting from FirstNumberedPage
+	PageNumbers       bool
+	FirstNumberedPage int
+
+	pages []mergedPage
+}
+
+type mergedPage struct {
+	file *file
+	page sourcePage
+}
+
+// NewMerger creates an empty merger
+func NewMerger() *Merger {
+	return &Merger{}
+}
+
+// Append adds all pages of PDF file content, returns the index of its first page in merged document
+func (m *Merger) Append(data []byte) (int, error) {
+	f, err := parseFile(data)
+	if err != nil {
+		return 0, err
+	}
+	pages, err := f.pages()
+	if err != nil {
+		return 0, err
+	}
+	first := len(m.pages)
+	for _, p := range pages {
+		m.pages = append(m.pages, mergedPage{f, p})
+	}
+	return first, nil
+}
+
+// AppendDocument adds all pages of document
+func (m *Merger) AppendDocument(d *Document) (int, error) {
+	var buf bytes.Buffer
+	if err := d.Write(&buf); err != nil {
+		return 0, err
+	}
+	return m.Append(buf.Bytes())
+}
+
+// PageCount returns number of pages appended
+func (m *Merger) PageCount() int {
+	return len(m.pages)
+}
+
+// WriteFile writes merged document into file
+func (m *Merger) WriteFile(name string) error {
+	return writeFile(name, m.Write)
+}
+
+// Write writes merged document to w. Objects used by appended pages are copied with new
+// object numbers, everything else in source files like their outlines is dropped.
+func (m *Merger) Write(w io.Writer) error {
+	if len(m.pages) == 0 {
+		return ErrNoPages
+	}
+	o := &objectWriter{}
+	catalog, pages, info := o.reserve(), o.reserve(), o.reserve()
+
+	// object numbers of source files in merged document, objects are copied when referenced
+	ids := make(map[*file]map[int]int)
+	type pending struct {
+		file    *file
+		num, id int
+	}
+	var queue []pending
+	mapRef := func(f *file) func(ref) int {
+		return func(r ref) int {
+			if id, ok := ids[f][r.num]; ok {
+				return id
+			}
+			id := o.reserve()
+			ids[f][r.num] = id
+			queue = append(queue, pending{f, r.num, id})
+			return id
+		}
+	}
+
+	// page objects are reserved first, so references between pages of same file are kept
+	pageIDs := make([]int, len(m.pages))
+	for i, p := range m.pages {
+		if ids[p.file] == nil {
+			ids[p.file] = make(map[int]int)
+		}
+		pageIDs[i] = o.reserve()
+		if p.page.num != 0 {
+			ids[p.file][p.page.num] = pageIDs[i]
+		}
+	}
+
+	var font int
+	if m.PageNumbers {
+		font = o.add(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", baseFonts[FontRegular]))
+	}
+	kids := make(array, len(m.pages))
+	for i, p := range m.pages {
+		page := dict{}
+		for k, v := range p.page.dict {
+			page[k] = v
+		}
+		page["Type"] = name("Page")
+		page["Parent"] = newRef(pages)
+		if m.PageNumbers && i >= m.FirstNumberedPage {
+			numberPage(o, p.file, page, font, i-m.FirstNumberedPage+1)
+		}
+		var b bytes.Buffer
+		serialize(&b, page, mapRef(p.file))
+		o.objects[pageIDs[i]-1] = b.Bytes()
+		kids[i] = newRef(pageIDs[i])
+	}
+
+	// copying an object may reference more objects
+	for len(queue) > 0 {
+		q := queue[0]
+		queue = queue[1:]
+		var b bytes.Buffer
+		serialize(&b, q.file.objects[q.num], mapRef(q.file))
+		o.objects[q.id-1] = b.Bytes()
+	}
+
+	var b bytes.Buffer
+	serialize(&b, dict{"Type": name("Pages"), "Kids": kids, "Count": number(strconv.Itoa(len(kids)))}, nil)
+	o.set(pages, b.String())
+	if outlines := writeOutlines(o, m.Outlines, pageIDs); outlines != 0 {
+		o.set(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R /Outlines %d 0 R /PageMode /UseOutlines >>", pages, outlines))
+	} else {
+		o.set(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pages))
+	}
+	o.set(info, fmt.Sprintf("<< /Title %s /Author %s /Producer (geektime-downloader) >>", textString(m.Title), textString(m.Author)))
+	return o.writeTo(w, catalog, info)
+}
+
+// numberPage draws page number n at the bottom right corner of page, the center is left for
+// numbers of the article itself. Original contents are wrapped in q and Q, so the number is
+// drawn in default graphics state whatever the page leaves.
+func numberPage(o *objectWriter, f *file, page dict, font, n int) {
+	lly, urx := 0.0, PageWidth
+	if box, ok := f.resolve(page["MediaBox"]).(array); ok && len(box) == 4 {
+		lly, _ = strconv.ParseFloat(string(f.resolveNumber(box[1])), 64)
+		urx, _ = strconv.ParseFloat(string(f.resolveNumber(box[2])), 64)
+	}
+	text := strconv.Itoa(n)
+	size := 9.0
+	x := urx - 36 - TextWidth(text, FontRegular, size)
+
+	resources := dict{}
+	if r, ok := f.resolve(page["Resources"]).(dict); ok {
+		for k, v := range r {
+			resources[k] = v
+		}
+	}
+	fonts := dict{}
+	if r, ok := f.resolve(resources["Font"]).(dict); ok {
+		for k, v := range r {
+			fonts[k] = v
+		}
+	}
+	fonts[pageNumberFont] = newRef(font)
+	resources["Font"] = fonts
+	page["Resources"] = resources
+
+	contents := array{newRef(o.addStream("", []byte("q\n"), false))}
+	switch c := f.resolve(page["Contents"]).(type) {
+	case array:
+		contents = append(contents, c...)
+	case *stream:
+		contents = append(contents, page["Contents"])
+	}
+	footer := fmt.Sprintf("Q\nBT %s rg /%s %s Tf %s %s Td %s Tj ET\n",
+		RGB(0x888888), pageNumberFont, num(size), num(x), num(lly+20), literal(text))
+	contents = append(contents, newRef(o.addStream("", []byte(footer), false)))
+	page["Contents"] = contents
+}
+
+// writeOutlines adds outline tree of items, returns object number of outline dictionary or 0 if there is no item
+func writeOutlines(o *objectWriter, items []*Outline, pageIDs []int) int {
+	if len(items) == 0 {
+		return 0
+	}
+	root := o.reserve()
+	first, last, count := writeOutlineItems(o, items, root, pageIDs)
+	o.set(root, fmt.Sprintf("<< /Type /Outlines /First %d 0 R /Last %d 0 R /Count %d >>", first, last, count))
+	return root
+}
+
+// writeOutlineItems adds items under parent, returns the first and last item and number of visible items
+func writeOutlineItems(o *objectWriter, items []*Outline, parent int, pageIDs []int) (first, last, count int) {
+	ids := make([]int, len(items))
+	for i := range items {
+		ids[i] = o.reserve()
+	}
+	for i, item := range items {
+		page := item.Page
+		if page < 0 || page >= len(pageIDs) {
+			page = 0
+		}
+		body := fmt.Sprintf("<< /Title %s /Parent %d 0 R /Dest [%d 0 R /Fit]", textString(item.Title), parent, pageIDs[page])
+		if i > 0 {
+			body += fmt.Sprintf(" /Prev %d 0 R", ids[i-1])
+		}
+		if i < len(items)-1 {
+			body += fmt.Sprintf(" /Next %d 0 R", ids[i+1])
+		}
+		if len(item.Children) > 0 {
+			f, l, c := writeOutlineItems(o, item.Children, ids[i], pageIDs)
+			body += fmt.Sprintf(" /First %d 0 R /Last %d 0 R /Count %d", f, l, c)
+			count += c
+		}
+		o.set(ids[i], body+" >>")
+	}
+	return ids[0], ids[len(ids)-1], count + len(items)
+}
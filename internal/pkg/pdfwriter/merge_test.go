@@ -0,0 +1,59 @@
+package pdfwriter
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMerger(t *testing.T) {
+	m := NewMerger()
+	m.Title = "专栏"
+	m.PageNumbers = true
+	m.FirstNumberedPage = 1
+	for _, pages := range []int{1, 2} {
+		d := New()
+		for i := 0; i < pages; i++ {
+			d.AddPage().Text(50, 50, "第一页 page", FontRegular, 12, RGB(0))
+		}
+		first, err := m.AppendDocument(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Outlines = append(m.Outlines, &Outline{Title: "文章", Page: first})
+	}
+	m.Outlines[1].Children = []*Outline{{Title: "小节", Page: 2}}
+
+	var buf bytes.Buffer
+	if err := m.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// merged file can be read again
+	f, err := parseFile(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, err := f.pages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 3 {
+		t.Fatalf("got %d pages, want 3", len(pages))
+	}
+	if _, ok := f.resolve(pages[0].dict["Resources"]).(dict)["Font"].(dict)[pageNumberFont]; ok {
+		t.Error("want first page not numbered")
+	}
+	if _, ok := f.resolve(pages[2].dict["Resources"]).(dict)["Font"].(dict)[pageNumberFont]; !ok {
+		t.Error("want last page numbered")
+	}
+
+	root := f.resolve(f.trailer["Root"]).(dict)
+	outlines := f.resolve(root["Outlines"]).(dict)
+	if outlines["Count"] != number("3") {
+		t.Errorf("got %v visible outlines, want 3", outlines["Count"])
+	}
+	last := f.resolve(outlines["Last"]).(dict)
+	if dest := last["Dest"].(array); dest[0] != (ref{num: pages[1].num}) {
+		t.Errorf("outline points to %v, want page %d", dest[0], pages[1].num)
+	}
+}
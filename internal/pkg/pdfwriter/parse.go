@@ -0,0 +1,548 @@
+package pdfwriter
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// ErrEncrypted means the PDF file is encrypted and can not be merged
+var ErrEncrypted = errors.New("encrypted pdf is not supported")
+
+// ErrInvalidPDF means the content is not a PDF file or it's broken
+var ErrInvalidPDF = errors.New("invalid pdf")
+
+// PDF objects read from file
+type (
+	name      string
+	pdfString []byte
+	array     []object
+	dict      map[string]object
+	ref       struct{ num, gen int }
+	// newRef refers to object of the written file, it's not renumbered
+	newRef int
+	// number keeps its text so it's written as it is
+	number string
+	// keyword is true, false or null
+	keyword string
+	stream  struct {
+		dict dict
+		data []byte
+	}
+	object interface{}
+)
+
+// file is a parsed PDF file
+type file struct {
+	objects map[int]object
+	trailer dict
+}
+
+var objectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// parseFile reads all objects of PDF by scanning object headers, so files with broken
+// cross reference table can still be read. Later object of the same number wins like
+// incremental update does.
+func parseFile(data []byte) (*file, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\n\r "), []byte("%PDF-")) {
+		return nil, ErrInvalidPDF
+	}
+	f := &file{objects: make(map[int]object)}
+	var objectStreams []*stream
+	for pos := 0; pos < len(data); {
+		loc := objectHeader.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		num, _ := strconv.Atoi(string(data[pos+loc[2] : pos+loc[3]]))
+		p := &parser{data: data, pos: pos + loc[1]}
+		obj, err := p.parseObject()
+		if err != nil {
+			pos += loc[1]
+			continue
+		}
+		f.objects[num] = obj
+		if s, ok := obj.(*stream); ok {
+			switch s.dict["Type"] {
+			case name("ObjStm"):
+				objectStreams = append(objectStreams, s)
+			case name("XRef"):
+				// cross reference stream has trailer entries
+				f.trailer = s.dict
+			}
+		}
+		pos = p.pos
+	}
+
+	// trailer after the last cross reference table
+	if i := bytes.LastIndex(data, []byte("trailer")); i >= 0 {
+		p := &parser{data: data, pos: i + len("trailer")}
+		if obj, err := p.parseObject(); err == nil {
+			if d, ok := obj.(dict); ok {
+				f.trailer = d
+			}
+		}
+	}
+	if f.trailer == nil {
+		return nil, ErrInvalidPDF
+	}
+	if _, ok := f.trailer["Encrypt"]; ok {
+		return nil, ErrEncrypted
+	}
+
+	// objects in object streams, objects written directly take precedence
+	for _, s := range objectStreams {
+		if err := f.readObjectStream(s); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (f *file) readObjectStream(s *stream) error {
+	if s.dict["Filter"] != nil && s.dict["Filter"] != name("FlateDecode") {
+		return fmt.Errorf("%w: unsupported object stream filter %v", ErrInvalidPDF, s.dict["Filter"])
+	}
+	data := s.data
+	if s.dict["Filter"] != nil {
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return err
+		}
+	}
+	n, _ := strconv.Atoi(string(f.resolveNumber(s.dict["N"])))
+	first, _ := strconv.Atoi(string(f.resolveNumber(s.dict["First"])))
+	p := &parser{data: data}
+	type entry struct{ num, offset int }
+	entries := make([]entry, 0, n)
+	for i := 0; i < n; i++ {
+		num, err1 := p.parseObject()
+		offset, err2 := p.parseObject()
+		if err1 != nil || err2 != nil {
+			return ErrInvalidPDF
+		}
+		a, _ := strconv.Atoi(string(toNumber(num)))
+		b, _ := strconv.Atoi(string(toNumber(offset)))
+		entries = append(entries, entry{a, b})
+	}
+	for _, e := range entries {
+		if _, ok := f.objects[e.num]; ok {
+			continue
+		}
+		p := &parser{data: data, pos: first + e.offset}
+		obj, err := p.parseObject()
+		if err != nil {
+			return err
+		}
+		f.objects[e.num] = obj
+	}
+	return nil
+}
+
+// resolve follows reference to its object
+func (f *file) resolve(o object) object {
+	for i := 0; i < 32; i++ {
+		r, ok := o.(ref)
+		if !ok {
+			return o
+		}
+		o = f.objects[r.num]
+	}
+	return nil
+}
+
+func (f *file) resolveNumber(o object) number {
+	return toNumber(f.resolve(o))
+}
+
+func toNumber(o object) number {
+	n, _ := o.(number)
+	return n
+}
+
+// sourcePage is a page of parsed file, num is its object number or 0 if it's not an indirect object
+type sourcePage struct {
+	num  int
+	dict dict
+}
+
+// pages returns pages in order, inheritable attributes are copied into page dictionaries
+func (f *file) pages() ([]sourcePage, error) {
+	root, ok := f.resolve(f.trailer["Root"]).(dict)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing document catalog", ErrInvalidPDF)
+	}
+	var pages []sourcePage
+	visited := make(map[int]bool)
+	var walk func(o object, inherited dict) error
+	walk = func(o object, inherited dict) error {
+		num := 0
+		if r, ok := o.(ref); ok {
+			if visited[r.num] {
+				return fmt.Errorf("%w: page tree has cycle", ErrInvalidPDF)
+			}
+			visited[r.num] = true
+			num = r.num
+		}
+		node, ok := f.resolve(o).(dict)
+		if !ok {
+			return nil
+		}
+		attrs := dict{}
+		for k, v := range inherited {
+			attrs[k] = v
+		}
+		for _, k := range []string{"Resources", "MediaBox", "CropBox", "Rotate"} {
+			if v, ok := node[k]; ok {
+				attrs[k] = v
+			}
+		}
+		if node["Type"] == name("Pages") || node["Kids"] != nil {
+			kids, _ := f.resolve(node["Kids"]).(array)
+			for _, kid := range kids {
+				if err := walk(kid, attrs); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		page := dict{}
+		for k, v := range node {
+			page[k] = v
+		}
+		for k, v := range attrs {
+			page[k] = v
+		}
+		pages = append(pages, sourcePage{num, page})
+		return nil
+	}
+	if err := walk(root["Pages"], nil); err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("%w: no pages", ErrInvalidPDF)
+	}
+	return pages, nil
+}
+
+// parser parses PDF objects from data
+type parser struct {
+	data []byte
+	pos  int
+}
+
+func isWhitespace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if c == '%' {
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		if !isWhitespace(c) {
+			return
+		}
+		p.pos++
+	}
+}
+
+// regular reads a run of regular characters, like number or keyword
+func (p *parser) regular() string {
+	start := p.pos
+	for p.pos < len(p.data) && !isWhitespace(p.data[p.pos]) && !isDelimiter(p.data[p.pos]) {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+func (p *parser) parseObject() (object, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.data) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	switch c := p.data[p.pos]; {
+	case c == '<' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '<':
+		p.pos += 2
+		d := dict{}
+		for {
+			p.skipSpaces()
+			if p.pos+1 < len(p.data) && p.data[p.pos] == '>' && p.data[p.pos+1] == '>' {
+				p.pos += 2
+				break
+			}
+			key, err := p.parseObject()
+			if err != nil {
+				return nil, err
+			}
+			k, ok := key.(name)
+			if !ok {
+				return nil, fmt.Errorf("%w: dictionary key is not a name", ErrInvalidPDF)
+			}
+			value, err := p.parseObject()
+			if err != nil {
+				return nil, err
+			}
+			d[string(k)] = value
+		}
+		return p.parseStream(d)
+	case c == '<':
+		p.pos++
+		end := bytes.IndexByte(p.data[p.pos:], '>')
+		if end < 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		var hex []byte
+		for _, h := range p.data[p.pos : p.pos+end] {
+			if !isWhitespace(h) {
+				hex = append(hex, h)
+			}
+		}
+		p.pos += end + 1
+		if len(hex)%2 == 1 {
+			hex = append(hex, '0')
+		}
+		s := make([]byte, len(hex)/2)
+		for i := range s {
+			v, err := strconv.ParseUint(string(hex[i*2:i*2+2]), 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("%w: bad hex string", ErrInvalidPDF)
+			}
+			s[i] = byte(v)
+		}
+		return pdfString(s), nil
+	case c == '(':
+		return p.parseLiteral()
+	case c == '/':
+		p.pos++
+		raw := p.regular()
+		// #xx escapes in name
+		var b []byte
+		for i := 0; i < len(raw); i++ {
+			if raw[i] == '#' && i+2 < len(raw) {
+				if v, err := strconv.ParseUint(raw[i+1:i+3], 16, 8); err == nil {
+					b = append(b, byte(v))
+					i += 2
+					continue
+				}
+			}
+			b = append(b, raw[i])
+		}
+		return name(b), nil
+	case c == '[':
+		p.pos++
+		var a array
+		for {
+			p.skipSpaces()
+			if p.pos < len(p.data) && p.data[p.pos] == ']' {
+				p.pos++
+				return a, nil
+			}
+			o, err := p.parseObject()
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, o)
+		}
+	default:
+		token := p.regular()
+		if token == "" {
+			return nil, fmt.Errorf("%w: unexpected character %q", ErrInvalidPDF, c)
+		}
+		switch token {
+		case "true", "false", "null":
+			return keyword(token), nil
+		}
+		if _, err := strconv.ParseFloat(token, 64); err != nil {
+			return nil, fmt.Errorf("%w: unexpected token %s", ErrInvalidPDF, token)
+		}
+		// integer followed by generation and R is a reference
+		if num, err := strconv.Atoi(token); err == nil {
+			save := p.pos
+			p.skipSpaces()
+			if gen, err := strconv.Atoi(p.regular()); err == nil {
+				p.skipSpaces()
+				if p.regular() == "R" {
+					return ref{num, gen}, nil
+				}
+			}
+			p.pos = save
+		}
+		return number(token), nil
+	}
+}
+
+func (p *parser) parseLiteral() (object, error) {
+	p.pos++
+	var s []byte
+	depth := 1
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return pdfString(s), nil
+			}
+		case '\\':
+			if p.pos >= len(p.data) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			e := p.data[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// line continuation
+				if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+						v = v*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		s = append(s, c)
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+// parseStream reads stream data after dictionary d if there is one
+func (p *parser) parseStream(d dict) (object, error) {
+	save := p.pos
+	p.skipSpaces()
+	if !bytes.HasPrefix(p.data[p.pos:], []byte("stream")) {
+		p.pos = save
+		return d, nil
+	}
+	p.pos += len("stream")
+	if p.pos < len(p.data) && p.data[p.pos] == '\r' {
+		p.pos++
+	}
+	if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+		p.pos++
+	}
+	start := p.pos
+
+	// trust Length only if endstream follows, otherwise search endstream
+	if n, ok := d["Length"].(number); ok {
+		if length, err := strconv.Atoi(string(n)); err == nil && length >= 0 && start+length <= len(p.data) {
+			rest := bytes.TrimLeft(p.data[start+length:], "\r\n ")
+			if bytes.HasPrefix(rest, []byte("endstream")) {
+				p.pos = start + length
+				p.skipSpaces()
+				p.pos += len("endstream")
+				return &stream{dict: d, data: p.data[start : start+length]}, nil
+			}
+		}
+	}
+	end := bytes.Index(p.data[start:], []byte("endstream"))
+	if end < 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	data := p.data[start : start+end]
+	// the end of line before endstream is not data
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	data = bytes.TrimSuffix(data, []byte("\r"))
+	p.pos = start + end + len("endstream")
+	return &stream{dict: d, data: data}, nil
+}
+
+// serialize writes object in PDF syntax, references are renumbered by mapRef
+func serialize(b *bytes.Buffer, o object, mapRef func(ref) int) {
+	switch v := o.(type) {
+	case nil:
+		b.WriteString("null")
+	case keyword:
+		b.WriteString(string(v))
+	case number:
+		b.WriteString(string(v))
+	case name:
+		b.WriteByte('/')
+		for i := 0; i < len(v); i++ {
+			c := v[i]
+			if c <= ' ' || c >= 0x7F || c == '#' || isDelimiter(c) {
+				fmt.Fprintf(b, "#%02X", c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	case pdfString:
+		fmt.Fprintf(b, "<%X>", []byte(v))
+	case array:
+		b.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			serialize(b, e, mapRef)
+		}
+		b.WriteByte(']')
+	case dict:
+		b.WriteString("<<")
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			serialize(b, name(k), mapRef)
+			b.WriteByte(' ')
+			serialize(b, v[k], mapRef)
+		}
+		b.WriteString(">>")
+	case ref:
+		fmt.Fprintf(b, "%d 0 R", mapRef(v))
+	case newRef:
+		fmt.Fprintf(b, "%d 0 R", int(v))
+	case *stream:
+		d := dict{}
+		for k, e := range v.dict {
+			d[k] = e
+		}
+		d["Length"] = number(strconv.Itoa(len(v.data)))
+		serialize(b, d, mapRef)
+		b.WriteString("\nstream\n")
+		b.Write(v.data)
+		b.WriteString("\nendstream")
+	}
+}
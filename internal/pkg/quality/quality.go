@@ -0,0 +1,165 @@
+package quality
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// quality modes which select by video attribute instead of definition name
+const (
+	qualityBest     = "best"
+	qualitySmallest = "smallest"
+)
+
+// attributes to compare in best and smallest mode
+const (
+	byHeight  = "height"
+	byBitrate = "bitrate"
+	bySize    = "size"
+)
+
+// definitionRank orders aliyun vod definitions from low to high
+var definitionRank = map[string]int{
+	"fd": 0,
+	"ld": 1,
+	"sd": 2,
+	"hd": 3,
+	"2k": 4,
+	"4k": 5,
+	"od": 6,
+}
+
+// ErrNotAvailable means none of the definitions in quality policy is available
+var ErrNotAvailable = errors.New("video quality not available")
+
+// Candidate is one available definition of a video
+type Candidate struct {
+	Definition string
+	Height     int64
+	Bitrate    float64
+	Size       int64
+}
+
+// Policy selects one from all available definitions of a video
+type Policy struct {
+	// Definitions are tried in order
+	Definitions []string
+	// Mode is best or smallest, used when none of Definitions matches
+	Mode string
+	// By is the attribute compared in Mode, height, bitrate or size
+	By string
+}
+
+// Parse parses quality setting, which is one of
+//
+//	sd              exact match, then the nearest definition, lower one first
+//	hd>sd>ld        try definitions in order
+//	best[:by]       the highest by height, bitrate or size, default by height
+//	smallest[:by]   the lowest by height, bitrate or size, default by height
+//	hd>sd>smallest  try definitions in order, then fall back to the mode
+func Parse(s string) (Policy, error) {
+	var policy Policy
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(s)), ">")
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		mode, by, hasBy := strings.Cut(part, ":")
+		_, isDefinition := definitionRank[part]
+		switch {
+		case mode == qualityBest || mode == qualitySmallest:
+			if i != len(parts)-1 {
+				return policy, fmt.Errorf("%s must be the last one in quality %s", mode, s)
+			}
+			if !hasBy {
+				by = byHeight
+			}
+			if by != byHeight && by != byBitrate && by != bySize {
+				return policy, fmt.Errorf("unknown attribute %s in quality %s, must be one of height, bitrate, size", by, s)
+			}
+			policy.Mode, policy.By = mode, by
+		case isDefinition:
+			policy.Definitions = append(policy.Definitions, part)
+		default:
+			return policy, fmt.Errorf("unknown definition %s in quality %s", part, s)
+		}
+	}
+	// single definition falls back to the nearest one
+	if len(policy.Definitions) == 1 && policy.Mode == "" {
+		policy.Definitions = nearestDefinitions(policy.Definitions[0])
+	}
+	return policy, nil
+}
+
+// nearestDefinitions returns all definitions ordered by distance to definition, lower one first
+func nearestDefinitions(definition string) []string {
+	rank := definitionRank[definition]
+	definitions := make([]string, 0, len(definitionRank))
+	for d := range definitionRank {
+		definitions = append(definitions, d)
+	}
+	sort.Slice(definitions, func(i, j int) bool {
+		di, dj := distance(definitionRank[definitions[i]], rank), distance(definitionRank[definitions[j]], rank)
+		if di != dj {
+			return di < dj
+		}
+		return definitionRank[definitions[i]] < definitionRank[definitions[j]]
+	})
+	return definitions
+}
+
+func distance(a, b int) int {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// Select returns index of the candidate chosen by policy, and whether it's the first choice
+func (p Policy) Select(candidates []Candidate) (int, bool, error) {
+	for i, d := range p.Definitions {
+		// the last matched one wins if there are duplicated definitions
+		matched := -1
+		for j := range candidates {
+			if strings.EqualFold(candidates[j].Definition, d) {
+				matched = j
+			}
+		}
+		if matched >= 0 {
+			return matched, i == 0, nil
+		}
+	}
+
+	if p.Mode != "" && len(candidates) > 0 {
+		chosen := 0
+		for j := range candidates[1:] {
+			candidate := j + 1
+			better := p.value(candidates[candidate]) > p.value(candidates[chosen])
+			if p.Mode == qualitySmallest {
+				better = p.value(candidates[candidate]) < p.value(candidates[chosen])
+			}
+			if better {
+				chosen = candidate
+			}
+		}
+		return chosen, len(p.Definitions) == 0, nil
+	}
+
+	available := make([]string, len(candidates))
+	for i, c := range candidates {
+		available[i] = c.Definition
+	}
+	return -1, false, fmt.Errorf("%w, wanted: %s, available: %s",
+		ErrNotAvailable, strings.Join(p.Definitions, ">"), strings.Join(available, ", "))
+}
+
+func (p Policy) value(c Candidate) float64 {
+	switch p.By {
+	case byBitrate:
+		return c.Bitrate
+	case bySize:
+		return float64(c.Size)
+	default:
+		return float64(c.Height)
+	}
+}
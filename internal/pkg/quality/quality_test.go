@@ -0,0 +1,52 @@
+package quality
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPolicySelect(t *testing.T) {
+	list := []Candidate{
+		{Definition: "ld", Height: 360, Bitrate: 300.5, Size: 30},
+		{Definition: "sd", Height: 540, Bitrate: 800, Size: 50},
+		{Definition: "od", Height: 720, Bitrate: 500, Size: 90},
+	}
+	tests := []struct {
+		quality    string
+		definition string
+		first      bool
+	}{
+		{"sd", "sd", true},
+		{"hd", "sd", false},
+		{"fd", "ld", false},
+		{"hd>ld>sd", "ld", false},
+		{"best", "od", true},
+		{"best:bitrate", "sd", true},
+		{"smallest:size", "ld", true},
+		{"hd>smallest", "ld", false},
+	}
+	for _, tt := range tests {
+		policy, err := Parse(tt.quality)
+		if err != nil {
+			t.Fatalf("parse %s: %v", tt.quality, err)
+		}
+		i, first, err := policy.Select(list)
+		if err != nil {
+			t.Fatalf("select %s: %v", tt.quality, err)
+		}
+		if list[i].Definition != tt.definition || first != tt.first {
+			t.Errorf("quality %s: expected %s (first choice %v), got %s (first choice %v)",
+				tt.quality, tt.definition, tt.first, list[i].Definition, first)
+		}
+	}
+
+	policy, _ := Parse("hd>2k")
+	if _, _, err := policy.Select(list); !errors.Is(err, ErrNotAvailable) {
+		t.Errorf("expected ErrNotAvailable, got %v", err)
+	}
+	for _, invalid := range []string{"", "uhd", "best>hd", "best:fps"} {
+		if _, err := Parse(invalid); err == nil {
+			t.Errorf("expected error for quality %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Package ratelimit throttles requests with token buckets, and backs off when server limits the rate anyway.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Limiter is a token bucket limiter, nil Limiter doesn't limit
+type Limiter struct {
+	l *rate.Limiter
+}
+
+// PerMinute returns limiter allowing n requests per minute, with a burst of one second's requests.
+// It returns nil if n is not positive.
+func PerMinute(n int) *Limiter {
+	if n <= 0 {
+		return nil
+	}
+	burst := n / 60
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{l: rate.NewLimiter(rate.Limit(float64(n)/60), burst)}
+}
+
+// Wait blocks until a request is allowed or ctx is done
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	return l.l.Wait(ctx)
+}
+
+// Backoff retries rate limited calls after exponentially growing pause. The pause is shared by
+// all calls of Backoff, so other calls don't keep hitting the limit while one is waiting.
+// Nil Backoff doesn't retry.
+type Backoff struct {
+	// Initial is the pause after first rate limited call, it doubles for each retry up to Max
+	Initial time.Duration
+	Max     time.Duration
+	// Retries is the most retries of one call
+	Retries int
+	// OnWait is called before pause if it's not nil
+	OnWait func(wait time.Duration, retry int)
+
+	mu    sync.Mutex
+	until time.Time
+}
+
+// NewBackoff returns backoff with retries, pause starts from one minute up to 16 minutes
+func NewBackoff(retries int) *Backoff {
+	return &Backoff{Initial: time.Minute, Max: 16 * time.Minute, Retries: retries}
+}
+
+// Do calls fn, and calls it again after pause while it returns error matching limited
+// until retries are used up, the last error is returned then.
+func (b *Backoff) Do(ctx context.Context, limited error, fn func() error) error {
+	if b == nil {
+		return fn()
+	}
+	for retry := 0; ; retry++ {
+		if err := b.pause(ctx); err != nil {
+			return err
+		}
+		err := fn()
+		if !errors.Is(err, limited) || retry >= b.Retries {
+			return err
+		}
+		wait := b.Initial << retry
+		if wait > b.Max || wait <= 0 {
+			wait = b.Max
+		}
+		if b.OnWait != nil {
+			b.OnWait(wait, retry+1)
+		}
+		b.mu.Lock()
+		if until := time.Now().Add(wait); until.After(b.until) {
+			b.until = until
+		}
+		b.mu.Unlock()
+	}
+}
+
+// pause waits until shared pause is over
+func (b *Backoff) pause(ctx context.Context) error {
+	b.mu.Lock()
+	wait := time.Until(b.until)
+	b.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
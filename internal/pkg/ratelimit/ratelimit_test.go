@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errLimited = errors.New("limited")
+
+func TestBackoff_Do(t *testing.T) {
+	b := &Backoff{Initial: time.Millisecond, Max: 3 * time.Millisecond, Retries: 3}
+	var waits []time.Duration
+	b.OnWait = func(wait time.Duration, retry int) {
+		waits = append(waits, wait)
+	}
+
+	calls := 0
+	err := b.Do(context.Background(), errLimited, func() error {
+		calls++
+		if calls < 3 {
+			return errLimited
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("Do() = %v after %d calls, want nil after 3 calls", err, calls)
+	}
+	if len(waits) != 2 || waits[0] != time.Millisecond || waits[1] != 2*time.Millisecond {
+		t.Errorf("waits = %v, want [1ms 2ms]", waits)
+	}
+
+	calls = 0
+	err = b.Do(context.Background(), errLimited, func() error {
+		calls++
+		return errLimited
+	})
+	if !errors.Is(err, errLimited) || calls != 4 {
+		t.Errorf("Do() = %v after %d calls, want limited after 4 calls", err, calls)
+	}
+	if waits[len(waits)-1] != b.Max {
+		t.Errorf("last wait = %s, want max %s", waits[len(waits)-1], b.Max)
+	}
+
+	other := errors.New("other")
+	calls = 0
+	if err := b.Do(context.Background(), errLimited, func() error { calls++; return other }); err != other || calls != 1 {
+		t.Errorf("Do() = %v after %d calls, want other error without retry", err, calls)
+	}
+}
+
+func TestBackoff_DoCanceled(t *testing.T) {
+	b := &Backoff{Initial: time.Hour, Max: time.Hour, Retries: 1}
+	ctx, cancel := context.WithCancel(context.Background())
+	b.OnWait = func(time.Duration, int) { cancel() }
+	err := b.Do(ctx, errLimited, func() error { return errLimited })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Do() = %v, want context canceled", err)
+	}
+}
+
+func TestNilLimiterAndBackoff(t *testing.T) {
+	var l *Limiter
+	if err := l.Wait(context.Background()); err != nil {
+		t.Errorf("nil limiter Wait() = %v", err)
+	}
+	if PerMinute(0) != nil {
+		t.Error("PerMinute(0) should not limit")
+	}
+	var b *Backoff
+	calls := 0
+	_ = b.Do(context.Background(), errLimited, func() error { calls++; return errLimited })
+	if calls != 1 {
+		t.Errorf("nil backoff calls = %d, want 1", calls)
+	}
+}
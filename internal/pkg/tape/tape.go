@@ -0,0 +1,374 @@
+// Package tape records http responses into a dir and replays them later without network,
+// so a download can be reproduced from a bug report or run offline in tests.
+//
+// Every response is saved as a json file of request and status, and a body file next to it.
+// Cookies and other headers are not saved, volatile or secret query parameters are left out
+// of saved url, and personal fields of json body are masked. GET and HEAD requests always
+// record the whole body, range requests are served from it, so files are replayed no matter
+// how they were split into ranges when recording.
+package tape
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrNotRecorded means the request is not found in tape when replaying
+var ErrNotRecorded = errors.New("response is not recorded")
+
+// ignoredParams are query parameters which change in every request, or are credentials
+var ignoredParams = []string{"t", "v_t", "Signature", "SignatureNonce", "Rand", "AccessKeyId", "SecurityToken", "AuthInfo"}
+
+// maskedFields are json fields of personal information in response body
+var maskedFields = map[string]bool{
+	"cellphone": true,
+	"phone":     true,
+	"mobile":    true,
+	"email":     true,
+	"nickname":  true,
+	"uid":       true,
+}
+
+// entry is the saved request and response, response body is saved in Body file
+type entry struct {
+	Method      string `json:"method"`
+	URL         string `json:"url"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Location    string `json:"location,omitempty"`
+	Body        string `json:"body"`
+}
+
+type transport struct {
+	dir string
+	// next sends requests when recording, nil when replaying
+	next http.RoundTripper
+
+	mu sync.Mutex
+	// keys locks requests of the same key, concurrent range requests of a file are recorded once
+	keys map[string]*sync.Mutex
+	// recorded are keys recorded in this run, they are served from tape instead of sent again
+	recorded map[string]bool
+}
+
+// Record returns transport which sends requests by next and saves responses into dir
+func Record(dir string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{dir: dir, next: next, keys: make(map[string]*sync.Mutex), recorded: make(map[string]bool)}
+}
+
+// Replay returns transport which serves responses saved by Record in dir, no request is sent
+func Replay(dir string) http.RoundTripper {
+	return &transport{dir: dir, keys: make(map[string]*sync.Mutex), recorded: make(map[string]bool)}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		_ = req.Body.Close()
+	}
+	method := req.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	u := sanitizeURL(req.URL)
+	sum := sha256.Sum256([]byte(method + " " + u + "\n" + string(reqBody)))
+	key := hex.EncodeToString(sum[:8])
+	name := filepath.Join(t.dir, filenameOf(req.URL.Host), key)
+
+	lock := t.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	t.mu.Lock()
+	recorded := t.recorded[key]
+	t.mu.Unlock()
+	// cookies are not saved, but the recording run still needs them, e.g. to login
+	var cookies []string
+	if t.next != nil && !recorded {
+		var err error
+		if cookies, err = t.record(req, method, u, reqBody, name); err != nil {
+			return nil, err
+		}
+		t.mu.Lock()
+		t.recorded[key] = true
+		t.mu.Unlock()
+	}
+
+	e, err := readEntry(name)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s %s", ErrNotRecorded, req.Method, u)
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp, err := serve(req, e, filepath.Join(filepath.Dir(name), e.Body))
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range cookies {
+		resp.Header.Add("Set-Cookie", c)
+	}
+	return resp, nil
+}
+
+func (t *transport) lock(key string) *sync.Mutex {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if l, ok := t.keys[key]; ok {
+		return l
+	}
+	l := &sync.Mutex{}
+	t.keys[key] = l
+	return l
+}
+
+// record sends request without range and saves the whole response, it returns cookies set by response
+func (t *transport) record(req *http.Request, method, u string, reqBody []byte, name string) ([]string, error) {
+	out := req.Clone(req.Context())
+	out.Method = method
+	out.Header.Del("Range")
+	out.Body = io.NopCloser(bytes.NewReader(reqBody))
+	out.ContentLength = int64(len(reqBody))
+	resp, err := t.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if err := os.MkdirAll(filepath.Dir(name), os.ModePerm); err != nil {
+		return nil, err
+	}
+	e := entry{
+		Method:      method,
+		URL:         u,
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Location:    resp.Header.Get("Location"),
+		Body:        filepath.Base(name) + ".body",
+	}
+	bodyPath := name + ".body"
+	if strings.Contains(e.ContentType, "json") {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeFile(bodyPath, maskJSON(body)); err != nil {
+			return nil, err
+		}
+	} else if err := copyFile(bodyPath, resp.Body); err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Header.Values("Set-Cookie"), writeFile(name+".json", data)
+}
+
+func readEntry(name string) (entry, error) {
+	var e entry
+	data, err := os.ReadFile(name + ".json")
+	if err != nil {
+		return e, err
+	}
+	err = json.Unmarshal(data, &e)
+	return e, err
+}
+
+// serve returns saved response, range of body is returned for range request
+func serve(req *http.Request, e entry, bodyPath string) (*http.Response, error) {
+	f, err := os.Open(bodyPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	size := info.Size()
+
+	resp := &http.Response{
+		Status:     fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+		StatusCode: e.Status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Request:    req,
+	}
+	if e.ContentType != "" {
+		resp.Header.Set("Content-Type", e.ContentType)
+	}
+	if e.Location != "" {
+		resp.Header.Set("Location", e.Location)
+	}
+	resp.Header.Set("Accept-Ranges", "bytes")
+
+	start, end := int64(0), size-1
+	if r := req.Header.Get("Range"); r != "" && e.Status == http.StatusOK {
+		var ok bool
+		if start, end, ok = parseRange(r, size); !ok {
+			_ = f.Close()
+			resp.StatusCode = http.StatusRequestedRangeNotSatisfiable
+			resp.Status = fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+			resp.Body = http.NoBody
+			return resp, nil
+		}
+		resp.StatusCode = http.StatusPartialContent
+		resp.Status = fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+		resp.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+	}
+	resp.ContentLength = end - start + 1
+	resp.Header.Set("Content-Length", strconv.FormatInt(resp.ContentLength, 10))
+	if req.Method == http.MethodHead {
+		_ = f.Close()
+		resp.Body = http.NoBody
+		return resp, nil
+	}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(f, start, resp.ContentLength), f}
+	return resp, nil
+}
+
+// parseRange parses single range header like bytes=0-99 or bytes=100-, end is inclusive
+func parseRange(r string, size int64) (start, end int64, ok bool) {
+	spec, found := strings.CutPrefix(r, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	first, last, found := strings.Cut(spec, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start >= size {
+		return 0, 0, false
+	}
+	end = size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return 0, 0, false
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end, true
+}
+
+// sanitizeURL returns url without ignored query parameters, parameters are sorted
+func sanitizeURL(u *url.URL) string {
+	s := *u
+	q := s.Query()
+	for _, p := range ignoredParams {
+		q.Del(p)
+	}
+	s.RawQuery = q.Encode()
+	s.User = nil
+	s.Fragment = ""
+	return s.String()
+}
+
+// maskJSON masks personal fields of json body, body is returned as is if it's not json or has no such field
+func maskJSON(body []byte) []byte {
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return body
+	}
+	if !mask(v) {
+		return body
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return body
+	}
+	return buf.Bytes()
+}
+
+// mask replaces values of masked fields in v, keeping their json type
+func mask(v interface{}) bool {
+	masked := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, field := range v {
+			if !maskedFields[strings.ToLower(k)] {
+				masked = mask(field) || masked
+				continue
+			}
+			switch field.(type) {
+			case string:
+				v[k] = "***"
+				masked = true
+			case json.Number:
+				v[k] = json.Number("0")
+				masked = true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			masked = mask(item) || masked
+		}
+	}
+	return masked
+}
+
+// filenameOf returns dir name of host, port separator is not allowed on windows
+func filenameOf(host string) string {
+	return strings.ReplaceAll(host, ":", "_")
+}
+
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func copyFile(path string, r io.Reader) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
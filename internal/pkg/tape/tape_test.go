@@ -0,0 +1,91 @@
+package tape
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/api":
+			body, _ := io.ReadAll(r.Body)
+			http.SetCookie(w, &http.Cookie{Name: "GCID", Value: "secret"})
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, `{"code":0,"data":{"uid":123,"nickname":"me","title":"`+string(body)+`"}}`)
+		case "/file":
+			if r.Header.Get("Range") != "" {
+				t.Error("range request is sent when recording")
+			}
+			_, _ = io.WriteString(w, "0123456789")
+		}
+	}))
+	dir := t.TempDir()
+
+	recorder := &http.Client{Transport: Record(dir, nil)}
+	resp, err := recorder.Post(server.URL+"/api?t=1", "application/json", strings.NewReader("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Cookies()) != 1 {
+		t.Error("cookies are not passed through when recording")
+	}
+	_ = resp.Body.Close()
+	for _, r := range []string{"bytes=0-4", "bytes=5-"} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/file", nil)
+		req.Header.Set("Range", r)
+		resp, err := recorder.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	if requests != 2 {
+		t.Errorf("requests = %d, want 2, file is recorded once", requests)
+	}
+	server.Close()
+
+	replayer := &http.Client{Transport: Replay(dir)}
+	resp, err = replayer.Post(server.URL+"/api?t=2", "application/json", strings.NewReader("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if got := strings.TrimSpace(string(body)); got != `{"code":0,"data":{"nickname":"***","title":"a","uid":0}}` {
+		t.Errorf("replayed body = %s", got)
+	}
+	if len(resp.Cookies()) != 0 {
+		t.Error("cookies are replayed")
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/file", nil)
+	req.Header.Set("Range", "bytes=3-5")
+	resp, err = replayer.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "345" {
+		t.Errorf("replayed range = %d %q, want 206 \"345\"", resp.StatusCode, body)
+	}
+
+	resp, err = replayer.Head(server.URL + "/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.ContentLength != 10 {
+		t.Errorf("replayed HEAD content length = %d, want 10", resp.ContentLength)
+	}
+
+	if _, err := replayer.Post(server.URL+"/api", "application/json", strings.NewReader("b")); err == nil || !strings.Contains(err.Error(), ErrNotRecorded.Error()) {
+		t.Errorf("replay of other body = %v, want not recorded", err)
+	}
+}
@@ -0,0 +1,176 @@
+package site
+
+import "html/template"
+
+var templates = template.Must(template.New("site").Parse(`
+{{- define "head" -}}
+<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{if ne .Title .Column}}{{.Title}} - {{end}}{{.Column}}</title>
+<link rel="stylesheet" href="style.css">
+</head>
+<body>
+<aside class="sidebar">
+<a class="home" href="index.html">{{.Column}}</a>
+<input id="search" type="search" placeholder="搜索文章" autocomplete="off">
+<ol id="results" hidden></ol>
+<nav id="toc"></nav>
+</aside>
+{{- end}}
+
+{{- define "foot" -}}
+<script src="search-index.js"></script>
+<script src="site.js"></script>
+</body>
+</html>
+{{end}}
+
+{{- define "article" -}}
+{{template "head" .}}
+<main>
+<article>
+<h1>{{.Title}}</h1>
+{{.Content}}
+</article>
+<nav class="pager">
+{{- with .Prev}}
+<a class="prev" href="{{.Href}}">← {{.Title}}</a>
+{{- end}}
+{{- with .Next}}
+<a class="next" href="{{.Href}}">{{.Title}} →</a>
+{{- end}}
+</nav>
+</main>
+{{template "foot" .}}
+{{- end}}
+
+{{- define "index" -}}
+{{template "head" .}}
+<main>
+<h1>{{.Title}}</h1>
+{{- range .Sections}}
+{{- if .Title}}
+<h2>{{.Title}}</h2>
+{{- end}}
+<ol class="contents">
+{{- range .Articles}}
+<li><a href="{{.Href}}">{{.Title}}</a></li>
+{{- end}}
+</ol>
+{{- end}}
+</main>
+{{template "foot" .}}
+{{- end}}
+`))
+
+const styleCSS = `* { box-sizing: border-box; }
+body { margin: 0; color: #353535; font: 16px/1.75 -apple-system, "PingFang SC", "Microsoft YaHei", sans-serif; }
+a { color: #1a73e8; text-decoration: none; }
+a:hover { text-decoration: underline; }
+.sidebar { position: fixed; top: 0; bottom: 0; left: 0; width: 300px; overflow-y: auto; padding: 20px 16px; background: #f7f8fa; border-right: 1px solid #e5e5e5; font-size: 14px; line-height: 1.6; }
+.sidebar .home { display: block; margin-bottom: 12px; color: #222; font-size: 17px; font-weight: bold; }
+.sidebar input { width: 100%; padding: 6px 10px; margin-bottom: 12px; border: 1px solid #ccc; border-radius: 4px; font-size: 14px; }
+.sidebar h3 { margin: 16px 0 4px; color: #888; font-size: 13px; }
+.sidebar ol { margin: 0; padding-left: 20px; }
+.sidebar li { margin: 4px 0; }
+.sidebar a.current { color: #222; font-weight: bold; }
+#results p { margin: 2px 0 8px; color: #888; font-size: 12px; }
+main { max-width: 860px; margin-left: 300px; padding: 32px 48px 64px; }
+main img { max-width: 100%; }
+pre { overflow-x: auto; padding: 12px 16px; background: #f6f8fa; border-radius: 4px; font-size: 13px; line-height: 1.5; }
+code { font-family: Menlo, Consolas, monospace; }
+:not(pre) > code { padding: 2px 4px; color: #c7254e; background: #f6f8fa; border-radius: 3px; }
+blockquote { margin: 0 0 16px; padding: 0 16px; color: #666; border-left: 4px solid #ddd; }
+table { border-collapse: collapse; }
+th, td { padding: 6px 12px; border: 1px solid #ccc; }
+.pager { display: flex; justify-content: space-between; gap: 16px; margin-top: 48px; padding-top: 16px; border-top: 1px solid #e5e5e5; }
+.pager .next { margin-left: auto; text-align: right; }
+@media (max-width: 900px) {
+  .sidebar { position: static; width: auto; border-right: none; border-bottom: 1px solid #e5e5e5; }
+  main { margin-left: 0; padding: 24px 16px; }
+}
+@media print {
+  body { color: #000; font-size: 12pt; }
+  .sidebar, .pager { display: none; }
+  main { max-width: none; margin: 0; padding: 0; }
+  a { color: inherit; }
+  pre { white-space: pre-wrap; word-break: break-all; }
+  pre, blockquote, table, img { page-break-inside: avoid; }
+  h1, h2, h3 { page-break-after: avoid; }
+}
+`
+
+const siteJS = `// builds table of contents and search of sidebar from searchIndex in search-index.js
+(function () {
+  var index = window.searchIndex || [];
+  var current = decodeURIComponent(location.pathname.split("/").pop());
+  var toc = document.getElementById("toc");
+  var input = document.getElementById("search");
+  var results = document.getElementById("results");
+
+  function link(item) {
+    var a = document.createElement("a");
+    a.href = encodeURIComponent(item.url);
+    a.textContent = item.title;
+    return a;
+  }
+
+  var section = null, list = null;
+  index.forEach(function (item) {
+    if (!list || item.section !== section) {
+      section = item.section;
+      if (section) {
+        var h = document.createElement("h3");
+        h.textContent = section;
+        toc.appendChild(h);
+      }
+      list = document.createElement("ol");
+      toc.appendChild(list);
+    }
+    var li = document.createElement("li");
+    var a = link(item);
+    if (item.url === current) {
+      a.className = "current";
+    }
+    li.appendChild(a);
+    list.appendChild(li);
+  });
+  var active = toc.querySelector(".current");
+  if (active) {
+    active.scrollIntoView({ block: "center" });
+  }
+
+  input.addEventListener("input", function () {
+    var q = input.value.trim().toLowerCase();
+    results.innerHTML = "";
+    results.hidden = !q;
+    toc.hidden = !!q;
+    if (!q) {
+      return;
+    }
+    index.forEach(function (item) {
+      var i = item.text.toLowerCase().indexOf(q);
+      if (item.title.toLowerCase().indexOf(q) < 0 && i < 0) {
+        return;
+      }
+      var li = document.createElement("li");
+      li.appendChild(link(item));
+      if (i >= 0) {
+        var start = Math.max(0, i - 30);
+        var p = document.createElement("p");
+        p.textContent = (start > 0 ? "…" : "") + item.text.substr(start, 90) + "…";
+        li.appendChild(p);
+      }
+      results.appendChild(li);
+    });
+    if (!results.firstChild) {
+      var none = document.createElement("li");
+      none.textContent = "没有找到相关文章";
+      results.appendChild(none);
+    }
+  });
+})();
+`
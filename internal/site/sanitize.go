@@ -0,0 +1,151 @@
+package site
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/nicoxiang/geektime-downloader/internal/markdown"
+)
+
+// removedElements are elements which run code or submit data, they are dropped with their children
+var removedElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Link:     true,
+	atom.Meta:     true,
+	atom.Base:     true,
+	atom.Iframe:   true,
+	atom.Frame:    true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Form:     true,
+	atom.Input:    true,
+	atom.Button:   true,
+	atom.Textarea: true,
+	atom.Select:   true,
+}
+
+// sanitize removes active content from article content html, images are downloaded into
+// images/aid of siteDir and referenced by relative path, so pages work offline.
+func sanitize(ctx context.Context, origin, content, siteDir string, aid int) (string, error) {
+	nodes, err := html.ParseFragment(strings.NewReader(content), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var imageURLs []string
+	var images []*html.Node
+	var kept []*html.Node
+	for _, n := range nodes {
+		if removedElements[n.DataAtom] {
+			continue
+		}
+		kept = append(kept, n)
+		walk(n, func(n *html.Node) {
+			if n.DataAtom == atom.Img {
+				if src := attr(n, "src"); markdown.IsImageURL(src) {
+					imageURLs = append(imageURLs, src)
+					images = append(images, n)
+				}
+			}
+		})
+	}
+
+	imagesFolder := filepath.Join(siteDir, "images", strconv.Itoa(aid))
+	localPaths, err := markdown.DownloadImages(ctx, origin, imageURLs, imagesFolder)
+	if err != nil {
+		return "", err
+	}
+	for i, n := range images {
+		rel, err := filepath.Rel(siteDir, localPaths[imageURLs[i]])
+		if err != nil {
+			return "", err
+		}
+		setAttr(n, "src", filepath.ToSlash(rel))
+	}
+
+	var buf bytes.Buffer
+	for _, n := range kept {
+		if err := html.Render(&buf, n); err != nil {
+			return "", err
+		}
+	}
+	return buf.String(), nil
+}
+
+// walk cleans n and all its descendants, removed elements are taken out of tree
+func walk(n *html.Node, visit func(*html.Node)) {
+	cleanAttrs(n)
+	visit(n)
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.ElementNode && removedElements[c.DataAtom] {
+			n.RemoveChild(c)
+		} else {
+			walk(c, visit)
+		}
+		c = next
+	}
+}
+
+// cleanAttrs removes event handlers, srcset and javascript urls of element
+func cleanAttrs(n *html.Node) {
+	attrs := n.Attr[:0]
+	for _, a := range n.Attr {
+		key := strings.ToLower(a.Key)
+		if strings.HasPrefix(key, "on") || key == "srcset" {
+			continue
+		}
+		if (key == "href" || key == "src") && strings.HasPrefix(strings.ToLower(strings.TrimSpace(a.Val)), "javascript:") {
+			continue
+		}
+		attrs = append(attrs, a)
+	}
+	n.Attr = attrs
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func setAttr(n *html.Node, key, val string) {
+	for i := range n.Attr {
+		if n.Attr[i].Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: val})
+}
+
+// textContent returns text of n with whitespace collapsed
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
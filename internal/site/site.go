@@ -0,0 +1,183 @@
+// Package site exports text column as a static HTML site, with table of contents, previous and
+// next article navigation and client side search. Every file of site is in one folder, so it can
+// be opened locally or served by any static file server.
+package site
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"html/template"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/filenamify"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/logger"
+)
+
+// DirName is the site folder name in column dir
+const DirName = "site"
+
+// HTMLExtension ...
+const HTMLExtension = ".html"
+
+// indexFileName is the home page of site
+const indexFileName = "index.html"
+
+// link links to an article page of site
+type link struct {
+	Title string
+	Href  string
+}
+
+// page is the data of page template
+type page struct {
+	Column  string
+	Title   string
+	Content template.HTML
+	Prev    *link
+	Next    *link
+	// Sections are only used by index page
+	Sections []section
+}
+
+type section struct {
+	Title    string
+	Articles []link
+}
+
+// searchEntry is an article in search index, toc in sidebar is also built from it
+type searchEntry struct {
+	Title   string `json:"title"`
+	Section string `json:"section"`
+	URL     string `json:"url"`
+	Text    string `json:"text"`
+}
+
+// PageName returns the file name of article page in site
+func PageName(title string) string {
+	return filenamify.Filenamify(title) + HTMLExtension
+}
+
+// WriteArticle writes article page into siteDir. prev and next are titles of neighbor articles, empty if there is none.
+// origin is the Origin header of image requests.
+func WriteArticle(ctx context.Context, origin, siteDir, column string, article geektime.Article, content, prev, next string) error {
+	logger.Infof("Begin write article html, articleID: %d, title: %s", article.AID, article.Title)
+	if err := os.MkdirAll(siteDir, os.ModePerm); err != nil {
+		return err
+	}
+	body, err := sanitize(ctx, origin, content, siteDir, article.AID)
+	if err != nil {
+		logger.Errorf(err, "Failed to sanitize article html, articleID: %d, title: %s", article.AID, article.Title)
+		return err
+	}
+
+	p := page{Column: column, Title: article.Title, Content: template.HTML(body)}
+	if prev != "" {
+		p.Prev = &link{Title: prev, Href: PageName(prev)}
+	}
+	if next != "" {
+		p.Next = &link{Title: next, Href: PageName(next)}
+	}
+	if err := writeTemplate(filepath.Join(siteDir, PageName(article.Title)), "article", p); err != nil {
+		return err
+	}
+	logger.Infof("Finish write article html, articleID: %d, title: %s", article.AID, article.Title)
+	return nil
+}
+
+// Build writes index page, assets and search index of site, articles without page are left out
+func Build(course geektime.Course, siteDir string) error {
+	if err := os.MkdirAll(siteDir, os.ModePerm); err != nil {
+		return err
+	}
+	p := page{Column: course.Title, Title: course.Title}
+	var entries []searchEntry
+	for _, article := range course.Articles {
+		name := PageName(article.Title)
+		text, err := articleText(filepath.Join(siteDir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		entries = append(entries, searchEntry{Title: article.Title, Section: article.SectionTitle, URL: name, Text: text})
+
+		if len(p.Sections) == 0 || p.Sections[len(p.Sections)-1].Title != article.SectionTitle {
+			p.Sections = append(p.Sections, section{Title: article.SectionTitle})
+		}
+		s := &p.Sections[len(p.Sections)-1]
+		s.Articles = append(s.Articles, link{Title: article.Title, Href: name})
+	}
+
+	// search index is a script instead of json file, browsers don't allow fetching local files
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	assets := map[string][]byte{
+		"style.css":       []byte(styleCSS),
+		"site.js":         []byte(siteJS),
+		"search-index.js": append(append([]byte("var searchIndex = "), data...), ";\n"...),
+	}
+	for name, content := range assets {
+		if err := writeFile(filepath.Join(siteDir, name), content); err != nil {
+			return err
+		}
+	}
+	return writeTemplate(filepath.Join(siteDir, indexFileName), "index", p)
+}
+
+// articleText returns text of article element in page file
+func articleText(pagePath string) (string, error) {
+	f, err := os.Open(pagePath)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	doc, err := html.Parse(f)
+	if err != nil {
+		return "", err
+	}
+	var article *html.Node
+	var find func(*html.Node)
+	find = func(n *html.Node) {
+		for c := n.FirstChild; c != nil && article == nil; c = c.NextSibling {
+			if c.DataAtom == atom.Article {
+				article = c
+				return
+			}
+			find(c)
+		}
+	}
+	find(doc)
+	if article == nil {
+		return "", nil
+	}
+	return textContent(article), nil
+}
+
+func writeTemplate(path, name string, p page) error {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, p); err != nil {
+		return err
+	}
+	return writeFile(path, buf.Bytes())
+}
+
+// writeFile writes to temp file first so that a broken file never has the final name
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,61 @@
+package site
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+)
+
+func TestWriteArticleAndBuild(t *testing.T) {
+	dir := t.TempDir()
+	course := geektime.Course{
+		Title: "专栏",
+		Articles: []geektime.Article{
+			{AID: 1, Title: "开篇词", SectionTitle: "基础篇"},
+			{AID: 2, Title: "第二讲 (下)", SectionTitle: "基础篇"},
+			{AID: 3, Title: "未下载"},
+		},
+	}
+	content := `<p onclick="alert(1)">正文<a href="javascript:alert(1)">链接</a></p><script>alert(1)</script><pre><code>代码</code></pre>`
+	if err := WriteArticle(context.Background(), geektime.DefaultBaseURL, dir, course.Title, course.Articles[0], content, "", course.Articles[1].Title); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteArticle(context.Background(), geektime.DefaultBaseURL, dir, course.Title, course.Articles[1], "<p>第二讲正文</p>", course.Articles[0].Title, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := Build(course, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := os.ReadFile(filepath.Join(dir, PageName("开篇词")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"<script>alert", "onclick", "javascript:"} {
+		if strings.Contains(string(page), s) {
+			t.Errorf("want %q removed from page", s)
+		}
+	}
+	if !strings.Contains(string(page), `class="next"`) || strings.Contains(string(page), `class="prev"`) {
+		t.Error("want only next link on first page")
+	}
+
+	index, err := os.ReadFile(filepath.Join(dir, "search-index.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(index), "第二讲正文") || strings.Contains(string(index), "未下载") {
+		t.Errorf("unexpected search index %s", index)
+	}
+	home, err := os.ReadFile(filepath.Join(dir, indexFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(home), "<h2>基础篇</h2>") {
+		t.Errorf("want section heading in index page, got %s", home)
+	}
+}
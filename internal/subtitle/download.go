@@ -0,0 +1,140 @@
+package subtitle
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/filenamify"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/logger"
+)
+
+// Track is a subtitle file of a video
+type Track struct {
+	Lang string
+	URL  string
+}
+
+// Subtitle is a downloaded subtitle track
+type Subtitle struct {
+	Lang string
+	Cues []Cue
+}
+
+// keys which hold language of subtitle track in api response
+var langKeys = []string{"lang", "language", "locale", "name", "title"}
+
+// FindTracks walks subtitles block of api response, which has different structure in
+// different api, and collects subtitle URLs with their language.
+func FindTracks(v interface{}) []Track {
+	var tracks []Track
+	seen := make(map[string]bool)
+	var walk func(v interface{}, lang string)
+	walk = func(v interface{}, lang string) {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			for _, k := range langKeys {
+				if s, ok := t[k].(string); ok && s != "" && !isURL(s) {
+					lang = s
+					break
+				}
+			}
+			keys := make([]string, 0, len(t))
+			for k := range t {
+				keys = append(keys, k)
+			}
+			// map order is random, keep tracks order stable
+			sort.Strings(keys)
+			for _, k := range keys {
+				if s, ok := t[k].(string); ok {
+					if isURL(s) && (strings.Contains(strings.ToLower(k), "url") || isSubtitleURL(s)) && !seen[s] {
+						seen[s] = true
+						tracks = append(tracks, Track{Lang: lang, URL: s})
+					}
+					continue
+				}
+				walk(t[k], lang)
+			}
+		case []interface{}:
+			for _, e := range t {
+				walk(e, lang)
+			}
+		case string:
+			if isURL(t) && !seen[t] {
+				seen[t] = true
+				tracks = append(tracks, Track{Lang: lang, URL: t})
+			}
+		}
+	}
+	walk(v, "")
+	return tracks
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+func isSubtitleURL(s string) bool {
+	path, _, _ := strings.Cut(strings.ToLower(s), "?")
+	return strings.HasSuffix(path, SRTExtension) || strings.HasSuffix(path, VTTExtension)
+}
+
+// Download fetches and parses subtitle tracks, tracks which can not be parsed are skipped
+func Download(ctx context.Context, client *geektime.Client, tracks []Track) ([]Subtitle, error) {
+	var subtitles []Subtitle
+	for _, track := range tracks {
+		resp, err := client.RestyClient.R().
+			SetContext(ctx).
+			SetHeader(geektime.Origin, client.BaseURL()).
+			Get(track.URL)
+		if err != nil {
+			return nil, err
+		}
+		if resp.IsError() {
+			logger.Warnf("Failed to download subtitle, url: %s, status: %s", track.URL, resp.Status())
+			continue
+		}
+		cues, err := Parse(resp.Body())
+		if err != nil {
+			logger.Warnf("Unsupported subtitle format, url: %s, err: %v", track.URL, err)
+			continue
+		}
+		subtitles = append(subtitles, Subtitle{Lang: track.Lang, Cues: cues})
+	}
+	return subtitles, nil
+}
+
+// Save writes every subtitle as SRT and WebVTT file next to video named by title.
+// The language is added to file name if there are multiple subtitles.
+func Save(dir, title string, subtitles []Subtitle) error {
+	for i, s := range subtitles {
+		name := filenamify.Filenamify(title)
+		if len(subtitles) > 1 {
+			lang := filenamify.Filenamify(s.Lang)
+			if lang == "" {
+				lang = strconv.Itoa(i + 1)
+			}
+			name += "." + lang
+		}
+		srt, vtt := &bytes.Buffer{}, &bytes.Buffer{}
+		if err := WriteSRT(srt, s.Cues); err != nil {
+			return err
+		}
+		if err := WriteVTT(vtt, s.Cues); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+SRTExtension), srt.Bytes(), 0o644); err != nil {
+			return fmt.Errorf("save subtitle failed: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+VTTExtension), vtt.Bytes(), 0o644); err != nil {
+			return fmt.Errorf("save subtitle failed: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+// Package subtitle parses, converts and saves subtitles of video lessons in SRT and WebVTT format
+package subtitle
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SRTExtension ...
+	SRTExtension = ".srt"
+	// VTTExtension ...
+	VTTExtension = ".vtt"
+)
+
+// ErrNoCues means the content has no subtitle cue, it may not be SRT or WebVTT
+var ErrNoCues = errors.New("no subtitle cues found")
+
+// Cue is one caption shown from Start to End
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// Parse parses SRT or WebVTT content into cues
+func Parse(data []byte) ([]Cue, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+
+	var cues []Cue
+	for _, block := range strings.Split(string(data), "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		// the timing line follows an optional cue identifier
+		i := 0
+		for i < len(lines) && !strings.Contains(lines[i], "-->") {
+			i++
+		}
+		if i == len(lines) {
+			// WEBVTT header, NOTE, STYLE and REGION blocks
+			continue
+		}
+		start, end, err := parseTiming(lines[i])
+		if err != nil {
+			return nil, err
+		}
+		text := strings.TrimSpace(strings.Join(lines[i+1:], "\n"))
+		if text == "" {
+			continue
+		}
+		cues = append(cues, Cue{Start: start, End: end, Text: text})
+	}
+	if len(cues) == 0 {
+		return nil, ErrNoCues
+	}
+	return cues, nil
+}
+
+// parseTiming parses timing line like 00:01:02,345 --> 00:01:04,000 or 01:02.345 --> 01:04.000 align:start
+func parseTiming(line string) (time.Duration, time.Duration, error) {
+	from, to, _ := strings.Cut(line, "-->")
+	// WebVTT cue settings follow the end time
+	if fields := strings.Fields(to); len(fields) > 0 {
+		to = fields[0]
+	}
+	start, err := parseTimestamp(strings.TrimSpace(from))
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseTimestamp(strings.TrimSpace(to))
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// parseTimestamp parses [hh:]mm:ss[.,]mmm
+func parseTimestamp(s string) (time.Duration, error) {
+	s = strings.Replace(s, ",", ".", 1)
+	clock, fraction, _ := strings.Cut(s, ".")
+	parts := strings.Split(clock, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid subtitle timestamp %s", s)
+	}
+	var d time.Duration
+	for _, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return 0, fmt.Errorf("invalid subtitle timestamp %s", s)
+		}
+		d = d*60 + time.Duration(n)
+	}
+	d *= time.Second
+	if fraction != "" {
+		// fraction may have less or more than 3 digits
+		fraction = (fraction + "000")[:3]
+		ms, err := strconv.Atoi(fraction)
+		if err != nil {
+			return 0, fmt.Errorf("invalid subtitle timestamp %s", s)
+		}
+		d += time.Duration(ms) * time.Millisecond
+	}
+	return d, nil
+}
+
+// WriteSRT writes cues in SRT format
+func WriteSRT(w io.Writer, cues []Cue) error {
+	bw := bufio.NewWriter(w)
+	for i, c := range cues {
+		_, _ = fmt.Fprintf(bw, "%d\n%s --> %s\n%s\n\n", i+1, formatTimestamp(c.Start, ","), formatTimestamp(c.End, ","), c.Text)
+	}
+	return bw.Flush()
+}
+
+// WriteVTT writes cues in WebVTT format
+func WriteVTT(w io.Writer, cues []Cue) error {
+	bw := bufio.NewWriter(w)
+	_, _ = bw.WriteString("WEBVTT\n\n")
+	for _, c := range cues {
+		_, _ = fmt.Fprintf(bw, "%s --> %s\n%s\n\n", formatTimestamp(c.Start, "."), formatTimestamp(c.End, "."), c.Text)
+	}
+	return bw.Flush()
+}
+
+func formatTimestamp(d time.Duration, sep string) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
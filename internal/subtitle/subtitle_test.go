@@ -0,0 +1,63 @@
+package subtitle
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestParseAndWrite(t *testing.T) {
+	vtt := "\ufeffWEBVTT\r\n\r\nNOTE comment\r\n\r\n1\r\n00:01.5 --> 00:03.000 align:start\r\n第一行\r\n第二行\r\n\r\n01:00:00.000 --> 01:00:01.250\r\nend\r\n"
+	cues, err := Parse([]byte(vtt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Cue{
+		{Start: 1500 * time.Millisecond, End: 3 * time.Second, Text: "第一行\n第二行"},
+		{Start: time.Hour, End: time.Hour + 1250*time.Millisecond, Text: "end"},
+	}
+	if len(cues) != len(want) {
+		t.Fatalf("got %d cues, want %d", len(cues), len(want))
+	}
+	for i := range want {
+		if cues[i] != want[i] {
+			t.Errorf("cue %d: got %+v, want %+v", i, cues[i], want[i])
+		}
+	}
+
+	var srt bytes.Buffer
+	if err := WriteSRT(&srt, cues); err != nil {
+		t.Fatal(err)
+	}
+	wantSRT := "1\n00:00:01,500 --> 00:00:03,000\n第一行\n第二行\n\n2\n01:00:00,000 --> 01:00:01,250\nend\n\n"
+	if srt.String() != wantSRT {
+		t.Errorf("got srt %q, want %q", srt.String(), wantSRT)
+	}
+	// SRT output can be parsed back
+	again, err := Parse(srt.Bytes())
+	if err != nil || len(again) != len(cues) || again[0] != cues[0] {
+		t.Errorf("round trip failed, got %+v, err %v", again, err)
+	}
+
+	if _, err := Parse([]byte("<html></html>")); err != ErrNoCues {
+		t.Errorf("got err %v, want %v", err, ErrNoCues)
+	}
+}
+
+func TestFindTracks(t *testing.T) {
+	source := []interface{}{
+		map[string]interface{}{"language": "zh-CN", "url": "https://example.com/zh.vtt", "cover": "https://example.com/c.png"},
+		map[string]interface{}{"name": "en", "file": "https://example.com/en.srt?auth=1"},
+		map[string]interface{}{"language": "zh-CN", "url": "https://example.com/zh.vtt"},
+	}
+	tracks := FindTracks(source)
+	want := []Track{{Lang: "zh-CN", URL: "https://example.com/zh.vtt"}, {Lang: "en", URL: "https://example.com/en.srt?auth=1"}}
+	if len(tracks) != len(want) {
+		t.Fatalf("got %+v, want %+v", tracks, want)
+	}
+	for i := range want {
+		if tracks[i] != want[i] {
+			t.Errorf("track %d: got %+v, want %+v", i, tracks[i], want[i])
+		}
+	}
+}
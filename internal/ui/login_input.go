@@ -0,0 +1,43 @@
+package ui
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/manifoldco/promptui"
+)
+
+// PhoneInput asks user to input login phone number
+func PhoneInput() (string, error) {
+	prompt := promptui.Prompt{
+		Label: "请输入极客时间登录手机号",
+		Validate: func(s string) error {
+			if strings.TrimSpace(s) == "" {
+				return errors.New("手机号不能为空")
+			}
+			return nil
+		},
+		HideEntered: true,
+	}
+	s, err := prompt.Run()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(s), nil
+}
+
+// PasswordInput asks user to input login password
+func PasswordInput() (string, error) {
+	prompt := promptui.Prompt{
+		Label: "请输入极客时间登录密码",
+		Validate: func(s string) error {
+			if s == "" {
+				return errors.New("密码不能为空")
+			}
+			return nil
+		},
+		Mask:        '*',
+		HideEntered: true,
+	}
+	return prompt.Run()
+}
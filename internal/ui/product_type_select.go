@@ -11,22 +11,47 @@ type ProductTypeSelectOption struct {
 	AcceptProductTypes []string
 	NeedSelectArticle  bool
 	IsEnterpriseMode   bool
+	Name               string
 }
 
-func ProductTypeSelect(isEnterprise bool) (ProductTypeSelectOption, error) {
-
+// ProductTypeOptions returns all product type options available in current mode
+func ProductTypeOptions(isEnterprise bool) []ProductTypeSelectOption {
 	productTypeOptions := []ProductTypeSelectOption{}
 
 	if isEnterprise {
-		productTypeOptions = append(productTypeOptions, ProductTypeSelectOption{0, "训练营", 5, []string{"c44"}, true, true}) //custom source type, not use
+		productTypeOptions = append(productTypeOptions, ProductTypeSelectOption{0, "训练营", 5, []string{"c44"}, true, true, "university"}) //custom source type, not use
 	} else {
-		productTypeOptions = append(productTypeOptions, ProductTypeSelectOption{0, "普通课程", 1, []string{"c1", "c3"}, true, false})
-		productTypeOptions = append(productTypeOptions, ProductTypeSelectOption{1, "每日一课", 2, []string{"d"}, false, false})
-		productTypeOptions = append(productTypeOptions, ProductTypeSelectOption{2, "公开课", 1, []string{"p35", "p29", "p30"}, true, false})
-		productTypeOptions = append(productTypeOptions, ProductTypeSelectOption{3, "大厂案例", 4, []string{"q"}, false, false})
-		productTypeOptions = append(productTypeOptions, ProductTypeSelectOption{4, "训练营", 5, []string{""}, true, false}) //custom source type, not use
-		productTypeOptions = append(productTypeOptions, ProductTypeSelectOption{5, "其他", 1, []string{"x", "c6"}, true, false})
+		productTypeOptions = append(productTypeOptions, ProductTypeSelectOption{0, "普通课程", 1, []string{"c1", "c3"}, true, false, "column"})
+		productTypeOptions = append(productTypeOptions, ProductTypeSelectOption{1, "每日一课", 2, []string{"d"}, false, false, "daily"})
+		productTypeOptions = append(productTypeOptions, ProductTypeSelectOption{2, "公开课", 1, []string{"p35", "p29", "p30"}, true, false, "opencourse"})
+		productTypeOptions = append(productTypeOptions, ProductTypeSelectOption{3, "大厂案例", 4, []string{"q"}, false, false, "qconplus"})
+		productTypeOptions = append(productTypeOptions, ProductTypeSelectOption{4, "训练营", 5, []string{""}, true, false, "university"}) //custom source type, not use
+		productTypeOptions = append(productTypeOptions, ProductTypeSelectOption{5, "其他", 1, []string{"x", "c6"}, true, false, "other"})
+	}
+	return productTypeOptions
+}
+
+// FindProductTypeOption finds product type option by its name or text, used in non-interactive mode
+func FindProductTypeOption(isEnterprise bool, name string) (ProductTypeSelectOption, bool) {
+	for _, o := range ProductTypeOptions(isEnterprise) {
+		if o.Name == name || o.Text == name {
+			return o, true
+		}
 	}
+	return ProductTypeSelectOption{}, false
+}
+
+// ProductTypeNames returns names of all product type options available in current mode
+func ProductTypeNames(isEnterprise bool) []string {
+	var names []string
+	for _, o := range ProductTypeOptions(isEnterprise) {
+		names = append(names, o.Name)
+	}
+	return names
+}
+
+func ProductTypeSelect(isEnterprise bool) (ProductTypeSelectOption, error) {
+	productTypeOptions := ProductTypeOptions(isEnterprise)
 
 	templates := &promptui.SelectTemplates{
 		Label:    "{{ . }}",
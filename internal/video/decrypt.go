@@ -0,0 +1,96 @@
+package video
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/crypto"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/m3u8"
+	"github.com/nicoxiang/geektime-downloader/internal/video/vod"
+)
+
+// decrypter decrypts downloaded segments by the EXT-X-KEY of each segment in playlist
+type decrypter struct {
+	// aliyunVodKey is the hex key of aliyun vod private encryption
+	aliyunVodKey string
+	// keys are standard HLS AES-128 keys by key URI
+	keys map[string][]byte
+}
+
+// newDecrypter prepares keys of all segments in playlist before downloading,
+// aliyun vod key is derived from play info, standard AES-128 keys are fetched with client cookies.
+func newDecrypter(client *geektime.Client, playlist *m3u8.MediaPlaylist, clientRand string, playInfo vod.PlayInfo) (*decrypter, error) {
+	d := &decrypter{keys: make(map[string][]byte)}
+	for _, segment := range playlist.Segments {
+		if segment.Key == nil {
+			continue
+		}
+		switch segment.Key.Method {
+		case m3u8.EncryptAliyunVod:
+			if d.aliyunVodKey == "" {
+				d.aliyunVodKey = crypto.GetAESDecryptKey(clientRand, playInfo.Rand, playInfo.Plaintext)
+			}
+		case m3u8.EncryptAES128:
+			if _, ok := d.keys[segment.Key.URI]; ok {
+				continue
+			}
+			key, err := fetchKey(client, segment.Key.URI)
+			if err != nil {
+				return nil, err
+			}
+			d.keys[segment.Key.URI] = key
+		default:
+			return nil, fmt.Errorf("unsupported video encryption method %d", segment.Key.Method)
+		}
+	}
+	return d, nil
+}
+
+func fetchKey(client *geektime.Client, keyURI string) ([]byte, error) {
+	if keyURI == "" {
+		return nil, fmt.Errorf("AES-128 key URI is missing in m3u8 playlist")
+	}
+	resp, err := client.RestyClient.R().Get(keyURI)
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("fetch AES-128 key failed, status: %s", resp.Status())
+	}
+	if len(resp.Body()) != 16 {
+		return nil, fmt.Errorf("invalid AES-128 key length %d", len(resp.Body()))
+	}
+	return resp.Body(), nil
+}
+
+// decrypt returns decrypted TS data of segment
+func (d *decrypter) decrypt(segment m3u8.Segment, data []byte) ([]byte, error) {
+	if segment.Key == nil {
+		return data, nil
+	}
+	switch segment.Key.Method {
+	case m3u8.EncryptAliyunVod:
+		tsParser, err := m3u8.NewTSParser(data, d.aliyunVodKey)
+		if err != nil {
+			return nil, err
+		}
+		return tsParser.Decrypt()
+	case m3u8.EncryptAES128:
+		iv := segment.Key.IV
+		if iv == nil {
+			iv = sequenceIV(segment.Sequence)
+		}
+		return crypto.AESDecryptHLS(data, d.keys[segment.Key.URI], iv)
+	default:
+		return nil, fmt.Errorf("unsupported video encryption method %d", segment.Key.Method)
+	}
+}
+
+// sequenceIV is the IV used when EXT-X-KEY has no IV attribute,
+// the media sequence number as a 128 bits big endian integer
+func sequenceIV(sequence int64) []byte {
+	iv := make([]byte, 16)
+	binary.BigEndian.PutUint64(iv[8:], uint64(sequence))
+	return iv
+}
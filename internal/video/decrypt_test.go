@@ -0,0 +1,51 @@
+package video
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/m3u8"
+)
+
+func encryptHLS(t *testing.T, plain, key, iv []byte) []byte {
+	t.Helper()
+	padding := aes.BlockSize - len(plain)%aes.BlockSize
+	padded := append(append([]byte(nil), plain...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, padded)
+	return encrypted
+}
+
+func TestDecryptAES128Segment(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	plain := bytes.Repeat([]byte{0x47, 1, 2, 3}, 47)
+	d := &decrypter{keys: map[string][]byte{"https://example.com/key": key}}
+
+	// IV derived from media sequence number
+	segment := m3u8.Segment{Sequence: 258, Key: &m3u8.Key{Method: m3u8.EncryptAES128, URI: "https://example.com/key"}}
+	iv := make([]byte, 16)
+	iv[14], iv[15] = 1, 2
+	got, err := d.decrypt(segment, encryptHLS(t, plain, key, iv))
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("decrypt with sequence IV failed: %v", err)
+	}
+
+	// explicit IV
+	segment.Key.IV = []byte("fedcba9876543210")
+	got, err = d.decrypt(segment, encryptHLS(t, plain, key, segment.Key.IV))
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("decrypt with explicit IV failed: %v", err)
+	}
+
+	// segment without key is not changed
+	got, err = d.decrypt(m3u8.Segment{}, plain)
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("unencrypted segment changed: %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package video
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/logger"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/mp4"
+)
+
+const (
+	// PIDs of video and audio stream in Geektime TS, same as m3u8.DemuxPES reads
+	videoPID = 0x100
+	audioPID = 0x101
+
+	// remuxChunkSize is the least TS data demuxed at a time when remuxing TS file
+	remuxChunkSize = 8 << 20
+)
+
+// RemuxTS remuxes merged TS file downloaded before into MP4 file, so video is not downloaded again
+// only because MP4 format is wanted later. TS file is demuxed in chunks split at start of video PES,
+// packets of audio PES which is not finished at the split are moved to next chunk.
+func RemuxTS(tsPath, mp4Path string) (err error) {
+	logger.Infof("Begin remux ts video, ts: %s, mp4: %s", tsPath, mp4Path)
+	f, err := os.Open(tsPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	muxer, err := mp4.NewMuxer(filepath.Dir(mp4Path))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = muxer.Close()
+		// remove half written file so it's never taken as downloaded
+		if err != nil {
+			_ = os.Remove(mp4Path)
+		}
+	}()
+
+	var chunk []byte
+	// audioStart is the offset in chunk of the last audio PES start, -1 if there is none
+	audioStart := -1
+	flush := func(last bool) error {
+		data := chunk
+		var carry []byte
+		if !last {
+			data, carry = splitChunk(chunk, audioStart)
+		}
+		if err := remuxSegment(muxer, data); err != nil {
+			return err
+		}
+		chunk, audioStart = carry, -1
+		if len(carry) > 0 {
+			audioStart = 0
+		}
+		return nil
+	}
+
+	r := bufio.NewReaderSize(f, 1<<20)
+	packet := make([]byte, packetLength)
+	for {
+		if _, err := io.ReadFull(r, packet); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("read ts file %s failed: %w", tsPath, err)
+		}
+		if packet[0] != syncByte {
+			return fmt.Errorf("invalid ts packet in %s", tsPath)
+		}
+		if packet[1]&0x40 != 0 {
+			switch packetPID(packet) {
+			case videoPID:
+				if len(chunk) >= remuxChunkSize {
+					if err := flush(false); err != nil {
+						return err
+					}
+				}
+			case audioPID:
+				audioStart = len(chunk)
+			}
+		}
+		chunk = append(chunk, packet...)
+	}
+	if len(chunk) > 0 {
+		if err := flush(true); err != nil {
+			return err
+		}
+	}
+	if err := muxer.Finish(mp4Path); err != nil {
+		return err
+	}
+	logger.Infof("Finish remux ts video, mp4: %s", mp4Path)
+	return nil
+}
+
+// splitChunk splits packets of audio PES starts at audioStart out of chunk, they are carried to next chunk
+func splitChunk(chunk []byte, audioStart int) (data, carry []byte) {
+	if audioStart < 0 {
+		return chunk, nil
+	}
+	data = append([]byte(nil), chunk[:audioStart]...)
+	for i := audioStart; i < len(chunk); i += packetLength {
+		packet := chunk[i : i+packetLength]
+		if packetPID(packet) == audioPID {
+			carry = append(carry, packet...)
+		} else {
+			data = append(data, packet...)
+		}
+	}
+	return data, carry
+}
+
+// packetPID returns PID of TS packet
+func packetPID(packet []byte) int {
+	return int(packet[1]&0x1f)<<8 | int(packet[2])
+}
@@ -2,6 +2,8 @@ package video
 
 import (
 	"context"
+	"crypto/aes"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
@@ -9,37 +11,65 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cheggaaa/pb/v3"
 	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/nicoxiang/geektime-downloader/internal/geektime"
-	"github.com/nicoxiang/geektime-downloader/internal/pkg/crypto"
 	"github.com/nicoxiang/geektime-downloader/internal/pkg/downloader"
 	"github.com/nicoxiang/geektime-downloader/internal/pkg/filenamify"
 	"github.com/nicoxiang/geektime-downloader/internal/pkg/files"
 	"github.com/nicoxiang/geektime-downloader/internal/pkg/logger"
 	"github.com/nicoxiang/geektime-downloader/internal/pkg/m3u8"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/mp4"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/quality"
+	"github.com/nicoxiang/geektime-downloader/internal/subtitle"
 	"github.com/nicoxiang/geektime-downloader/internal/video/vod"
 )
 
 const (
-	// syncByte = uint8(71) //0x47
+	syncByte     = uint8(71) //0x47
+	packetLength = 188
 
 	// TSExtension ...
 	TSExtension = ".ts"
+	// MP4Extension ...
+	MP4Extension = ".mp4"
+	// partExtension is the extension of segment file which is still downloading
+	partExtension = ".part"
 )
 
-// EncryptType enum
-type EncryptType int
+// ErrNoVideo means article has no video
+var ErrNoVideo = errors.New("article has no video")
 
-// const (
-// 	// AliyunVodEncrypt ...
-// 	AliyunVodEncrypt EncryptType = iota
-// 	// HLSStandardEncrypt ...
-// 	HLSStandardEncrypt
-// )
+// video output formats
+const (
+	// FormatTS keeps merged TS file
+	FormatTS = "ts"
+	// FormatMP4 remuxes merged TS into MP4 file
+	FormatMP4 = "mp4"
+	// FormatBoth keeps both TS and MP4 file
+	FormatBoth = "both"
+)
+
+// Options controls how video is downloaded and saved
+type Options struct {
+	// Quality is the video definition, ld, sd or hd
+	Quality string
+	// Format is the output format, ts, mp4 or both
+	Format string
+	// SegmentConcurrency is the number of segments downloaded at the same time
+	SegmentConcurrency int
+	// RangeConcurrency is the concurrent range requests of each segment
+	RangeConcurrency int
+	// Subtitles saves subtitles as SRT and WebVTT files next to video
+	Subtitles bool
+	// EmbedSubtitles adds subtitles as text tracks of MP4 output
+	EmbedSubtitles bool
+}
 
 // GetPlayInfoResponse is the response struct for api GetPlayInfo
 type GetPlayInfoResponse struct {
@@ -48,149 +78,188 @@ type GetPlayInfoResponse struct {
 	PlayInfoList vod.PlayInfoListInGetPlayInfo `json:"PlayInfoList" xml:"PlayInfoList"`
 }
 
-// DownloadArticleVideo download normal video cource ...
+// DownloadArticleVideo download normal video cource, returns the definition actually downloaded,
+// or ErrNoVideo if article has no video. Video file is named by title, or article title of api if it's empty.
 // sourceType: normal video cource 1
 func DownloadArticleVideo(ctx context.Context,
 	client *geektime.Client,
 	articleID int,
 	sourceType int,
+	title,
 	projectDir string,
-	quality string,
-	concurrency int,
-) error {
+	opts Options,
+) (string, error) {
 	logger.Infof("Begin download normal article video, articleID: %d, sourceType: %d", articleID, sourceType)
 	articleInfo, err := client.V3ArticleInfo(articleID)
 	if err != nil {
-		return err
+		return "", err
 	}
 	if articleInfo.Data.Info.Video.ID == "" {
-		return nil
+		return "", ErrNoVideo
 	}
 	playAuth, err := client.VideoPlayAuth(articleInfo.Data.Info.ID, sourceType, articleInfo.Data.Info.Video.ID)
 	if err != nil {
-		return err
+		return "", err
 	}
-	err = downloadAliyunVodEncryptVideo(ctx,
+	definition, err := downloadVodVideo(ctx,
 		client,
+		client.BaseURL(),
 		playAuth,
-		articleInfo.Data.Info.Title,
+		videoFileTitle(title, articleInfo.Data.Info.Title),
 		projectDir,
-		quality,
 		articleInfo.Data.Info.Video.ID,
-		concurrency)
+		articleInfo.Data.Info.Video.Subtitles,
+		opts)
 	if err != nil {
 		logger.Errorf(err, "Download normal article video failed, articleID: %d", articleID)
-		return err
+		return "", err
 	} else {
-		logger.Infof("Finish download normal article video, articleID: %d", articleID)
-		return nil
+		logger.Infof("Finish download normal article video, articleID: %d, definition: %s", articleID, definition)
+		return definition, nil
 	}
 }
 
-// DownloadEnterpriseArticleVideo download enterprise video
+// DownloadEnterpriseArticleVideo download enterprise video, returns the definition actually downloaded,
+// or ErrNoVideo if article has no video. Video file is named by title, or article title of api if it's empty.
 func DownloadEnterpriseArticleVideo(ctx context.Context,
 	client *geektime.Client,
 	articleID int,
+	title,
 	projectDir string,
-	quality string,
-	concurrency int,
-) error {
+	opts Options,
+) (string, error) {
 	logger.Infof("Begin download enterprise article video, articleID: %d", articleID)
 	articleInfo, err := client.V1EnterpriseArticleDetail(strconv.Itoa(articleID))
 	if err != nil {
-		return err
+		return "", err
 	}
 	if articleInfo.Data.Video.ID == "" {
-		return nil
+		return "", ErrNoVideo
 	}
 	playAuth, err := client.EnterpriseVideoPlayAuth(strconv.Itoa(articleID), articleInfo.Data.Video.ID)
 	if err != nil {
-		return err
+		return "", err
 	}
-	err = downloadAliyunVodEncryptVideo(ctx,
+	definition, err := downloadVodVideo(ctx,
 		client,
+		client.BaseURL(),
 		playAuth,
-		articleInfo.Data.Article.Title,
+		videoFileTitle(title, articleInfo.Data.Article.Title),
 		projectDir,
-		quality,
 		articleInfo.Data.Video.ID,
-		concurrency)
+		articleInfo.Data.Video.Subtitles.Rights,
+		opts)
 	if err != nil {
 		logger.Errorf(err, "Download enterprise article video failed, articleID: %d", articleID)
-		return err
+		return "", err
 	} else {
-		logger.Infof("Finish download enterprise article video, articleID: %d", articleID)
-		return nil
+		logger.Infof("Finish download enterprise article video, articleID: %d, definition: %s", articleID, definition)
+		return definition, nil
 	}
 }
 
-// DownloadUniversityVideo ...
+// DownloadUniversityVideo download university video, returns the definition actually downloaded.
+// Video file is named by title, or article title in course if it's empty.
 func DownloadUniversityVideo(ctx context.Context,
 	client *geektime.Client,
 	articleID int,
 	currentProduct geektime.Course,
+	title,
 	projectDir string,
-	quality string,
-	concurrency int,
-) error {
+	opts Options,
+) (string, error) {
 	logger.Infof("Begin download university article video, articleID: %d", articleID)
 	playAuthInfo, err := client.UniversityVideoPlayAuth(articleID, currentProduct.ID)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	videoTitle := getUniversityVideoTitle(articleID, currentProduct)
-	err = downloadAliyunVodEncryptVideo(ctx,
+	definition, err := downloadVodVideo(ctx,
 		client,
+		client.BaseURL(),
 		playAuthInfo.Data.PlayAuth,
-		videoTitle,
+		videoFileTitle(title, getUniversityVideoTitle(articleID, currentProduct)),
 		projectDir,
-		quality,
 		playAuthInfo.Data.VID,
-		concurrency)
+		nil,
+		opts)
 	if err != nil {
 		logger.Errorf(err, "Download university article video failed, articleID: %d", articleID)
-		return err
+		return "", err
 	} else {
-		logger.Infof("Finish download university article video, articleID: %d", articleID)
-		return nil
+		logger.Infof("Finish download university article video, articleID: %d, definition: %s", articleID, definition)
+		return definition, nil
 	}
 }
 
-func downloadAliyunVodEncryptVideo(ctx context.Context,
+// downloadVodVideo downloads aliyun vod video, segments are decrypted by the encryption method in playlist.
+// subtitleSource is the subtitles block of article api response, nil if there is none.
+func downloadVodVideo(ctx context.Context,
 	client *geektime.Client,
+	origin,
 	playAuth,
 	videoTitle,
 	projectDir,
-	quality,
 	videoID string,
-	concurrency int,
-) error {
+	subtitleSource interface{},
+	opts Options,
+) (string, error) {
 	clientRand := uuid.NewString()
-	playInfoURL, err := vod.BuildVodGetPlayInfoURL(playAuth, videoID, clientRand)
+	playInfoURL, err := vod.BuildVodGetPlayInfoURL(client.VODBaseURL(), playAuth, videoID, clientRand)
 	if err != nil {
-		return err
+		return "", err
 	}
-	playInfo, err := getPlayInfo(client, playInfoURL, quality)
+	playInfo, err := getPlayInfo(client, playInfoURL, opts.Quality)
 	if err != nil {
-		return err
+		return "", err
+	}
+	playlist, err := m3u8.Parse(client, playInfo.PlayURL)
+	if err != nil {
+		return "", err
 	}
-	tsURLPrefix := extractTSURLPrefix(playInfo.PlayURL)
+	d, err := newDecrypter(client, playlist, clientRand, playInfo)
+	if err != nil {
+		return "", err
+	}
+	subtitles := downloadSubtitles(ctx, client, subtitleSource, opts)
+	err = download(ctx, origin, videoTitle, projectDir, playlist.Segments, d, playInfo.Size, subtitles, opts)
+	if err != nil {
+		return "", err
+	}
+	return playInfo.Definition, nil
+}
 
-	tsFileNames, isVodEncryptVideo, err := m3u8.Parse(client, playInfo.PlayURL)
+// downloadSubtitles downloads subtitles of video, video is still downloaded without them if failed
+func downloadSubtitles(ctx context.Context, client *geektime.Client, subtitleSource interface{}, opts Options) []subtitle.Subtitle {
+	if !opts.Subtitles && !opts.EmbedSubtitles {
+		return nil
+	}
+	tracks := subtitle.FindTracks(subtitleSource)
+	if len(tracks) == 0 {
+		return nil
+	}
+	subtitles, err := subtitle.Download(ctx, client, tracks)
 	if err != nil {
-		return err
+		logger.Warnf("Failed to download video subtitles, err: %v", err)
+		return nil
 	}
+	return subtitles
+}
 
-	decryptKey := ""
-	if isVodEncryptVideo {
-		decryptKey = crypto.GetAESDecryptKey(clientRand, playInfo.Rand, playInfo.Plaintext)
+// DownloadMP4Subtitle saves subtitle of article mp4 video next to it, named by mp4 file name
+func DownloadMP4Subtitle(ctx context.Context, client *geektime.Client, title, projectDir, mp4URL, subtitleURL string) error {
+	subtitles, err := subtitle.Download(ctx, client, []subtitle.Track{{URL: subtitleURL}})
+	if err != nil || len(subtitles) == 0 {
+		return err
 	}
-	return download(ctx, tsURLPrefix, videoTitle, projectDir, tsFileNames, []byte(decryptKey), playInfo.Size, isVodEncryptVideo, concurrency)
+	u, _ := url.Parse(mp4URL)
+	name := strings.TrimSuffix(path.Base(u.Path), path.Ext(u.Path))
+	videoDir := filepath.Join(projectDir, "videos", filenamify.Filenamify(title))
+	return subtitle.Save(videoDir, name, subtitles)
 }
 
-// DownloadMP4 download MP4 resources in article
-func DownloadMP4(ctx context.Context, title, projectDir string, mp4URLs []string, overwrite bool) (err error) {
+// DownloadMP4 download MP4 resources in article, origin is the Origin header of requests
+func DownloadMP4(ctx context.Context, origin, title, projectDir string, mp4URLs []string, overwrite bool) (err error) {
 	logger.Infof("Begin download article mp4 videos, title: %s, mp4URLs: %v", title, mp4URLs)
 	filenamifyTitle := filenamify.Filenamify(title)
 	videoDir := filepath.Join(projectDir, "videos", filenamifyTitle)
@@ -207,13 +276,13 @@ func DownloadMP4(ctx context.Context, title, projectDir string, mp4URLs []string
 		}
 
 		headers := make(map[string]string, 2)
-		headers[geektime.Origin] = geektime.DefaultBaseURL
+		headers[geektime.Origin] = origin
 		headers[geektime.UserAgent] = geektime.DefaultUserAgent
 		logger.Infof("Begin download single article mp4 video, title: %s, mp4URL: %s", title, mp4URL)
 		_, err := downloader.DownloadFileConcurrently(ctx, dst, mp4URL, headers, 5)
 		if err != nil {
 			logger.Errorf(err, "Failed to download single article mp4 video, title: %s, mp4URL: %s", title, mp4URL)
-			return nil
+			return err
 		}
 		logger.Infof("Finish download single article mp4 video, title: %s, mp4URL: %s", title, mp4URL)
 	}
@@ -222,103 +291,235 @@ func DownloadMP4(ctx context.Context, title, projectDir string, mp4URLs []string
 }
 
 func download(ctx context.Context,
-	tsURLPrefix,
+	origin,
 	title,
 	projectDir string,
-	tsFileNames []string,
-	decryptKey []byte,
+	segments []m3u8.Segment,
+	d *decrypter,
 	size int64,
-	isVodEncryptVideo bool,
-	concurrency int,
+	subtitles []subtitle.Subtitle,
+	opts Options,
 ) (err error) {
-	// Make temp ts folder and download temp ts files
+	// Make temp ts folder and download temp ts files.
+	// The folder is kept if download failed or canceled, so that next attempt
+	// only need to fetch the missing segments.
 	filenamifyTitle := filenamify.Filenamify(title)
 	tempVideoDir := filepath.Join(projectDir, filenamifyTitle)
 	if err = os.MkdirAll(tempVideoDir, os.ModePerm); err != nil {
 		return
 	}
-	// temp folder cleanup
-	defer func() {
-		_ = os.RemoveAll(tempVideoDir)
-	}()
 
 	bar := newBar(size, fmt.Sprintf("[正在下载 %s] ", filenamifyTitle))
 	bar.Start()
 
 	defer bar.Finish()
 
-	for _, tsFileName := range tsFileNames {
-		u := tsURLPrefix + tsFileName
-		dst := filepath.Join(tempVideoDir, tsFileName)
-
-		headers := make(map[string]string, 2)
-		headers[geektime.Origin] = geektime.DefaultBaseURL
-		headers[geektime.UserAgent] = geektime.DefaultUserAgent
+	// segments are downloaded in parallel, bar is updated by multiple goroutines
+	var barMu sync.Mutex
+	addBar := func(written int64) {
+		barMu.Lock()
+		defer barMu.Unlock()
+		addBarValue(bar, written)
+	}
 
-		fileSize, err := downloader.DownloadFileConcurrently(ctx, dst, u, headers, concurrency)
-		if err != nil {
-			return err
+	segmentConcurrency := opts.SegmentConcurrency
+	if segmentConcurrency <= 0 {
+		segmentConcurrency = 1
+	}
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(segmentConcurrency)
+	for i, segment := range segments {
+		// stop scheduling segments once any segment failed or ctx is canceled
+		if gctx.Err() != nil {
+			break
 		}
+		g.Go(func() error {
+			dst := filepath.Join(tempVideoDir, segmentFileName(i))
 
-		addBarValue(bar, fileSize)
+			if fileSize, ok := verifySegment(dst, segment); ok {
+				logger.Infof("Segment already downloaded, skip it, segment: %s", dst)
+				addBar(fileSize)
+				return nil
+			}
+
+			headers := make(map[string]string, 2)
+			headers[geektime.Origin] = origin
+			headers[geektime.UserAgent] = geektime.DefaultUserAgent
+
+			// download to part file first, so that a segment file always has full content
+			var err error
+			if r := segment.ByteRange; r != nil {
+				_, err = downloader.DownloadRange(gctx, dst+partExtension, segment.URI, headers, r.Offset, r.Length, addBar)
+			} else {
+				_, err = downloader.DownloadFile(gctx, dst+partExtension, segment.URI, headers, opts.RangeConcurrency, addBar)
+			}
+			if err != nil {
+				return err
+			}
+			return os.Rename(dst+partExtension, dst)
+		})
+	}
+	if err = g.Wait(); err != nil {
+		return
+	}
+	if err = ctx.Err(); err != nil {
+		return
 	}
 
-	// Read temp ts files, decrypt and merge into the one final video file
-	err = mergeTSFiles(tempVideoDir, filenamifyTitle, projectDir, decryptKey, isVodEncryptVideo)
+	// Read temp ts files in playlist order, decrypt and merge into the one final video file
+	var embedded []subtitle.Subtitle
+	if opts.EmbedSubtitles {
+		embedded = subtitles
+	}
+	err = mergeTSFiles(tempVideoDir, segments, filenamifyTitle, projectDir, d, opts.Format, embedded)
+	if err != nil {
+		return
+	}
+	if opts.Subtitles {
+		if err = subtitle.Save(projectDir, title, subtitles); err != nil {
+			return
+		}
+	}
 
+	// temp folder cleanup, only after final video file is merged
+	_ = os.RemoveAll(tempVideoDir)
 	return
 }
 
-func mergeTSFiles(tempVideoDir, filenamifyTitle, projectDir string, key []byte, isVodEncryptVideo bool) error {
-	tempTSFiles, err := os.ReadDir(tempVideoDir)
-	if err != nil {
-		return err
+// segmentFileName returns temp file name of the i-th segment in playlist
+func segmentFileName(i int) string {
+	return fmt.Sprintf("%05d%s", i, TSExtension)
+}
+
+// verifySegment checks if segment file is already downloaded completely,
+// a complete TS segment consists of whole 188 bytes packets each starts with sync byte.
+// Segment encrypted by standard AES-128 can only be checked by its size.
+func verifySegment(segmentPath string, segment m3u8.Segment) (int64, bool) {
+	data, err := os.ReadFile(segmentPath)
+	if err != nil || len(data) == 0 {
+		return 0, false
 	}
-	fullPath := filepath.Join(projectDir, filenamifyTitle+TSExtension)
-	finalVideoFile, err := os.OpenFile(fullPath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, os.ModePerm)
-	if err != nil {
-		return err
+	if segment.ByteRange != nil && int64(len(data)) != segment.ByteRange.Length {
+		return 0, false
+	}
+	if segment.Key != nil && segment.Key.Method == m3u8.EncryptAES128 {
+		return int64(len(data)), len(data)%aes.BlockSize == 0
 	}
-	removeOnError := false
+	if len(data)%packetLength != 0 {
+		return 0, false
+	}
+	for i := 0; i < len(data); i += packetLength {
+		if data[i] != syncByte {
+			return 0, false
+		}
+	}
+	return int64(len(data)), true
+}
+
+// mergeTSFiles decrypts temp ts files, merges them into one TS file and/or remuxes them into one MP4 file,
+// subtitles are added to MP4 file as text tracks.
+func mergeTSFiles(tempVideoDir string,
+	segments []m3u8.Segment,
+	filenamifyTitle,
+	projectDir string,
+	d *decrypter,
+	format string,
+	subtitles []subtitle.Subtitle,
+) (err error) {
+	var outputs []string
 	defer func() {
-		_ = finalVideoFile.Close()
-		if removeOnError {
-			_ = os.Remove(fullPath)
+		// remove half written files so they are never taken as downloaded
+		if err != nil {
+			for _, o := range outputs {
+				_ = os.Remove(o)
+			}
 		}
 	}()
-	for _, tempTSFile := range tempTSFiles {
-		f, err := os.ReadFile(filepath.Join(tempVideoDir, tempTSFile.Name()))
+
+	var finalVideoFile *os.File
+	if format != FormatMP4 {
+		fullPath := filepath.Join(projectDir, filenamifyTitle+TSExtension)
+		// truncate final video file in case a previous merge was interrupted
+		finalVideoFile, err = os.OpenFile(fullPath, os.O_TRUNC|os.O_WRONLY|os.O_CREATE, os.ModePerm)
 		if err != nil {
-			removeOnError = true
 			return err
 		}
+		outputs = append(outputs, fullPath)
+		defer func() {
+			_ = finalVideoFile.Close()
+		}()
+	}
 
-		if isVodEncryptVideo {
-			tsParser, err := m3u8.NewTSParser(f, string(key))
-			if err != nil {
-				removeOnError = true
+	var muxer *mp4.Muxer
+	if format != FormatTS {
+		muxer, err = mp4.NewMuxer(tempVideoDir)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = muxer.Close()
+		}()
+	}
+
+	for i, segment := range segments {
+		tsFileName := segmentFileName(i)
+		f, err := os.ReadFile(filepath.Join(tempVideoDir, tsFileName))
+		if err != nil {
+			return err
+		}
+
+		if f, err = d.decrypt(segment, f); err != nil {
+			return fmt.Errorf("decrypt segment %s failed: %w", tsFileName, err)
+		}
+
+		if finalVideoFile != nil {
+			if _, err := finalVideoFile.Write(f); err != nil {
 				return err
 			}
-			f, err = tsParser.Decrypt()
-			if err != nil {
-				removeOnError = true
-				return err
+		}
+		if muxer != nil {
+			if err := remuxSegment(muxer, f); err != nil {
+				return fmt.Errorf("remux segment %s failed: %w", tsFileName, err)
+			}
+		}
+	}
+
+	if muxer != nil {
+		for _, s := range subtitles {
+			samples := make([]mp4.TextSample, len(s.Cues))
+			for i, c := range s.Cues {
+				samples[i] = mp4.TextSample{Start: c.Start, End: c.End, Text: c.Text}
 			}
+			muxer.AddTextTrack(s.Lang, samples)
 		}
+		fullPath := filepath.Join(projectDir, filenamifyTitle+MP4Extension)
+		outputs = append(outputs, fullPath)
+		return muxer.Finish(fullPath)
+	}
+	return nil
+}
 
-		// case HLSStandardEncrypt:
-		// 	aes128 := crypto.AESDecryptCBC(f, key, make([]byte, 16))
-		// 	// https://en.wikipedia.org/wiki/MPEG_transport_stream
-		// 	for j := 0; j < len(aes128); j++ {
-		// 	if aes128[j] == syncByte {
-		// 		aes128 = aes128[j:]
-		// 		break
-		// 	}
-		// }
-		// f = aes128
-
-		if _, err := finalVideoFile.Write(f); err != nil {
-			removeOnError = true
+// remuxSegment demuxes decrypted TS segment and appends its samples to muxer
+func remuxSegment(muxer *mp4.Muxer, data []byte) error {
+	videos, audios, err := m3u8.DemuxPES(data)
+	if err != nil {
+		return err
+	}
+	for _, pes := range videos {
+		pts := pes.PTS
+		if !pes.HasPTS {
+			pts = mp4.NoTimestamp
+		}
+		if err := muxer.WriteVideo(pts, pes.DTS, pes.Data); err != nil {
+			return err
+		}
+	}
+	for _, pes := range audios {
+		pts := pes.PTS
+		if !pes.HasPTS {
+			pts = mp4.NoTimestamp
+		}
+		if err := muxer.WriteAudio(pts, pes.Data); err != nil {
 			return err
 		}
 	}
@@ -344,6 +545,14 @@ func addBarValue(bar *pb.ProgressBar, written int64) {
 	}
 }
 
+// videoFileTitle returns the title video file is named by, title given by caller comes first
+func videoFileTitle(title, fallback string) string {
+	if title != "" {
+		return title
+	}
+	return fallback
+}
+
 func getUniversityVideoTitle(articleID int, currentProduct geektime.Course) string {
 	for _, v := range currentProduct.Articles {
 		if v.AID == articleID {
@@ -353,15 +562,15 @@ func getUniversityVideoTitle(articleID int, currentProduct geektime.Course) stri
 	return ""
 }
 
-func extractTSURLPrefix(m3u8url string) string {
-	i := strings.LastIndex(m3u8url, "/")
-	return m3u8url[:i+1]
-}
-
-func getPlayInfo(client *geektime.Client, playInfoURL, quality string) (vod.PlayInfo, error) {
+// getPlayInfo returns the play info selected by quality policy
+func getPlayInfo(client *geektime.Client, playInfoURL, qualitySetting string) (vod.PlayInfo, error) {
 	var getPlayInfoResp GetPlayInfoResponse
 	var playInfo vod.PlayInfo
-	_, err := client.RestyClient.R().
+	policy, err := quality.Parse(qualitySetting)
+	if err != nil {
+		return playInfo, err
+	}
+	_, err = client.RestyClient.R().
 		SetResult(&getPlayInfoResp).
 		Get(playInfoURL)
 	if err != nil {
@@ -369,10 +578,20 @@ func getPlayInfo(client *geektime.Client, playInfoURL, quality string) (vod.Play
 	}
 
 	playInfoList := getPlayInfoResp.PlayInfoList.PlayInfo
-	for _, p := range playInfoList {
-		if strings.EqualFold(p.Definition, quality) {
-			playInfo = p
-		}
+	candidates := make([]quality.Candidate, len(playInfoList))
+	for i, p := range playInfoList {
+		bitrate, _ := strconv.ParseFloat(p.Bitrate, 64)
+		candidates[i] = quality.Candidate{Definition: p.Definition, Height: p.Height, Bitrate: bitrate, Size: p.Size}
+	}
+	i, firstChoice, err := policy.Select(candidates)
+	if err != nil {
+		return playInfo, err
+	}
+	playInfo = playInfoList[i]
+	if firstChoice {
+		logger.Infof("Video definition selected, quality: %s, definition: %s", qualitySetting, playInfo.Definition)
+	} else {
+		logger.Warnf("Video definition fell back, quality: %s, definition: %s, height: %d", qualitySetting, playInfo.Definition, playInfo.Height)
 	}
 	return playInfo, nil
 }
@@ -0,0 +1,188 @@
+package video
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nicoxiang/geektime-downloader/internal/geektime"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/files"
+	"github.com/nicoxiang/geektime-downloader/internal/pkg/m3u8"
+)
+
+// tsPackets returns n TS packets, every packet starts with sync byte
+func tsPackets(n int) []byte {
+	packet := append([]byte{syncByte}, bytes.Repeat([]byte{0xff}, packetLength-1)...)
+	return bytes.Repeat(packet, n)
+}
+
+func TestVerifySegment(t *testing.T) {
+	badSync := tsPackets(3)
+	badSync[packetLength] = 0
+
+	aes128 := &m3u8.Key{Method: m3u8.EncryptAES128, URI: "https://example.com/key"}
+	tests := []struct {
+		name    string
+		data    []byte
+		segment m3u8.Segment
+		ok      bool
+	}{
+		{"complete", tsPackets(3), m3u8.Segment{}, true},
+		{"empty", nil, m3u8.Segment{}, false},
+		{"truncated", tsPackets(3)[:packetLength*2+100], m3u8.Segment{}, false},
+		{"bad sync byte", badSync, m3u8.Segment{}, false},
+		{"byte range", tsPackets(2), m3u8.Segment{ByteRange: &m3u8.ByteRange{Length: packetLength * 2}}, true},
+		{"byte range truncated", tsPackets(2), m3u8.Segment{ByteRange: &m3u8.ByteRange{Length: packetLength * 3}}, false},
+		{"aes-128 blocks", bytes.Repeat([]byte{1}, 32*16), m3u8.Segment{Key: aes128}, true},
+		{"aes-128 truncated", bytes.Repeat([]byte{1}, 32*16-5), m3u8.Segment{Key: aes128}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segmentPath := filepath.Join(t.TempDir(), "0.ts")
+			if tt.data != nil {
+				if err := os.WriteFile(segmentPath, tt.data, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			size, ok := verifySegment(segmentPath, tt.segment)
+			if ok != tt.ok {
+				t.Fatalf("verifySegment = %v, want %v", ok, tt.ok)
+			}
+			if ok && size != int64(len(tt.data)) {
+				t.Errorf("size = %d, want %d", size, len(tt.data))
+			}
+		})
+	}
+}
+
+func TestSplitChunk(t *testing.T) {
+	packet := func(pid int, start bool, n byte) []byte {
+		p := make([]byte, packetLength)
+		p[0], p[1], p[2], p[4] = syncByte, byte(pid>>8), byte(pid), n
+		if start {
+			p[1] |= 0x40
+		}
+		return p
+	}
+	var chunk []byte
+	for _, p := range [][]byte{
+		packet(videoPID, true, 1),
+		packet(audioPID, true, 2),
+		packet(videoPID, false, 3),
+		packet(audioPID, true, 4),
+		packet(videoPID, false, 5),
+		packet(audioPID, false, 6),
+	} {
+		chunk = append(chunk, p...)
+	}
+
+	data, carry := splitChunk(chunk, 3*packetLength)
+	order := func(b []byte) []byte {
+		var ns []byte
+		for i := 0; i < len(b); i += packetLength {
+			ns = append(ns, b[i+4])
+		}
+		return ns
+	}
+	if got := order(data); !bytes.Equal(got, []byte{1, 2, 3, 5}) {
+		t.Errorf("data packets = %v", got)
+	}
+	if got := order(carry); !bytes.Equal(got, []byte{4, 6}) {
+		t.Errorf("carried packets = %v", got)
+	}
+	if data, carry := splitChunk(chunk, -1); len(data) != len(chunk) || carry != nil {
+		t.Error("chunk without audio start is split")
+	}
+}
+
+// segmentServer serves the i-th segment at /i.ts, requests of later segments are answered earlier
+func segmentServer(t *testing.T, n int, requests *int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), TSExtension))
+		if err != nil || i >= n {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			atomic.AddInt32(requests, 1)
+		}
+		select {
+		case <-time.After(time.Duration(n-i) * 5 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		data := tsPackets(2)
+		data[packetLength-1] = byte(i)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func testSegments(server *httptest.Server, n int) []m3u8.Segment {
+	segments := make([]m3u8.Segment, n)
+	for i := range segments {
+		segments[i] = m3u8.Segment{URI: fmt.Sprintf("%s/%d%s", server.URL, i, TSExtension), Sequence: int64(i)}
+	}
+	return segments
+}
+
+func TestDownload_MergeInPlaylistOrder(t *testing.T) {
+	const n = 8
+	var requests int32
+	segments := testSegments(segmentServer(t, n, &requests), n)
+	projectDir := t.TempDir()
+
+	opts := Options{Format: FormatTS, SegmentConcurrency: 4}
+	if err := download(context.Background(), geektime.DefaultBaseURL, "v", projectDir, segments, &decrypter{}, 0, nil, opts); err != nil {
+		t.Fatal(err)
+	}
+	merged, err := os.ReadFile(filepath.Join(projectDir, "v"+TSExtension))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged) != n*2*packetLength {
+		t.Fatalf("merged size = %d", len(merged))
+	}
+	for i := 0; i < n; i++ {
+		if got := merged[i*2*packetLength+packetLength-1]; got != byte(i) {
+			t.Fatalf("segment at %d is %d", i, got)
+		}
+	}
+	if files.CheckFileExists(filepath.Join(projectDir, "v")) {
+		t.Error("temp segment dir is not removed")
+	}
+}
+
+func TestDownload_FailedSegmentCancelsPool(t *testing.T) {
+	const n = 20
+	var requests int32
+	segments := testSegments(segmentServer(t, n, &requests), n)
+	projectDir := t.TempDir()
+
+	// part file of segment 1 can't be created, so it fails at once
+	if err := os.MkdirAll(filepath.Join(projectDir, "v", segmentFileName(1)+partExtension), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{Format: FormatTS, SegmentConcurrency: 2}
+	err := download(context.Background(), geektime.DefaultBaseURL, "v", projectDir, segments, &decrypter{}, 0, nil, opts)
+	if err == nil || errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want error of segment 1", err)
+	}
+	if got := atomic.LoadInt32(&requests); got >= n-1 {
+		t.Errorf("%d segments requested after failure, want pool canceled", got)
+	}
+	if files.CheckFileExists(filepath.Join(projectDir, "v"+TSExtension)) {
+		t.Error("video is merged after failure")
+	}
+}
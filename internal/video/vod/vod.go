@@ -27,8 +27,8 @@ type PlayAuthData struct {
 	AccessKeySecret string `json:"AccessKeySecret"`
 }
 
-// BuildVodGetPlayInfoURL ...
-func BuildVodGetPlayInfoURL(playAuth, videoID, clientRand string) (string, error) {
+// BuildVodGetPlayInfoURL returns signed GetPlayInfo url of vod API at baseURL
+func BuildVodGetPlayInfoURL(baseURL, playAuth, videoID, clientRand string) (string, error) {
 	decodedPlayAuth := decodePlayAuth(playAuth)
 	var playAuthData PlayAuthData
 	err := json.Unmarshal([]byte(decodedPlayAuth), &playAuthData)
@@ -68,7 +68,7 @@ func BuildVodGetPlayInfoURL(playAuth, videoID, clientRand string) (string, error
 	accessKeySecret := playAuthData.AccessKeySecret
 	signature := pc.HmacSHA1Signature(accessKeySecret, stringToSign)
 	queryString := cqs + "&Signature=" + percentEncode(signature)
-	return "https://vod.cn-shanghai.aliyuncs.com/?" + queryString, nil
+	return baseURL + "/?" + queryString, nil
 }
 
 func decodePlayAuth(playAuth string) string {